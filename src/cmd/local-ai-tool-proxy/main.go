@@ -2,17 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/tobilg/local-ai-tool-proxy/src/internal/config"
 	"github.com/tobilg/local-ai-tool-proxy/src/internal/handler"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/health"
 	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/webhook"
 )
 
 var (
@@ -26,6 +32,7 @@ func main() {
 		fmt.Printf("local-ai-tool-proxy %s (commit: %s, built: %s)\n", Version, Commit, BuildDate)
 		os.Exit(0)
 	}
+	mcpStdio := len(os.Args) > 1 && os.Args[1] == "--mcp"
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -41,26 +48,143 @@ func main() {
 		"opencode": provider.NewOpenCodeClient(),
 	}
 
+	// Add providers declared in the providers file
+	for _, spec := range cfg.CommandProviders {
+		if _, exists := providers[spec.Name]; exists {
+			log.Fatalf("Provider %s in %s conflicts with a built-in provider", spec.Name, cfg.ProvidersFile)
+		}
+		providers[spec.Name] = provider.NewCommandClient(spec)
+	}
+
+	providerNames := make([]string, 0, len(providers))
+	for name := range providers {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
 	// Validate configured provider exists
 	if _, ok := providers[cfg.Provider]; !ok {
-		log.Fatalf("Unknown provider: %s (valid options: claude, gemini, codex, continue, opencode)", cfg.Provider)
+		log.Fatalf("Unknown provider: %s (valid options: %s)", cfg.Provider, strings.Join(providerNames, ", "))
+	}
+
+	// Validate fallback chains only reference known providers
+	for name, chain := range cfg.Fallbacks {
+		for _, fallback := range append([]string{name}, chain...) {
+			if _, ok := providers[fallback]; !ok {
+				log.Fatalf("Unknown provider in fallback chain for %s: %s (valid options: %s)", name, fallback, strings.Join(providerNames, ", "))
+			}
+		}
+	}
+
+	// Check which provider CLIs are installed
+	monitor := health.NewMonitor(providers)
+	monitor.Check(context.Background())
+
+	if status, ok := monitor.Status(cfg.Provider); ok && !status.Installed {
+		log.Fatalf("Default provider %s is not available: %s not found in PATH", cfg.Provider, status.Binary)
+	}
+	for _, name := range providerNames {
+		if status, ok := monitor.Status(name); ok && !status.Installed {
+			log.Printf("[WARN] Provider %s is not available: %s not found in PATH", name, status.Binary)
+		}
+	}
+
+	probeCtx, stopProbes := context.WithCancel(context.Background())
+	defer stopProbes()
+	if cfg.ProbeInterval > 0 {
+		go monitor.Run(probeCtx, cfg.ProbeInterval)
+	}
+
+	// Reload system prompts when their files change and on SIGHUP
+	if cfg.PromptReloadInterval > 0 {
+		go cfg.Prompts.Run(probeCtx, cfg.PromptReloadInterval)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := cfg.Prompts.Reload(); err != nil {
+				log.Printf("[ERROR] Failed to reload system prompts, keeping previous version: %v", err)
+				continue
+			}
+			log.Printf("[INFO] Reloaded system prompts on SIGHUP")
+		}
+	}()
+
+	// Bound concurrent CLI runs and their duration per provider
+	queues := make(map[string]*queue.Limiter, len(providers))
+	timeouts := make(map[string]time.Duration, len(providers))
+	for name := range providers {
+		queues[name] = queue.NewLimiter(cfg.ConcurrencyFor(name), cfg.QueueSize)
+		timeouts[name] = cfg.TimeoutFor(name)
+	}
+
+	opts := []handler.Option{
+		handler.WithModels(cfg.Models),
+		handler.WithHealth(monitor),
+		handler.WithQueues(queues),
+		handler.WithFallbacks(cfg.Fallbacks),
+		handler.WithFallbackCodes(cfg.FallbackCodes),
+		handler.WithTimeouts(timeouts),
+		handler.WithJobRetention(cfg.JobRetention),
+		handler.WithSessionTTL(cfg.SessionTTL),
+		handler.WithBatchConcurrency(cfg.BatchConcurrency),
+		handler.WithSchemaRetries(cfg.SchemaRetries),
+		handler.WithPrompts(cfg.Prompts),
+		handler.WithSystemOverride(cfg.SystemOverride),
 	}
+	if cfg.WebhookSecret != "" {
+		opts = append(opts, handler.WithWebhooks(webhook.NewSender(cfg.WebhookSecret, webhook.WithAttempts(cfg.WebhookAttempts))))
+	}
+	h := handler.New(providers, cfg.Provider, cfg.AllowedOrigin, cfg.SystemPrompt, opts...)
+
+	mcpServer := h.MCPServer(Version)
+	if mcpStdio {
+		// stdout carries the protocol, logs go to stderr
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
-	h := handler.New(providers, cfg.Provider, cfg.AllowedOrigin, cfg.SystemPrompt)
+		log.Printf("[INFO] Serving MCP over stdio with providers: %s", strings.Join(providerNames, ", "))
+		if err := mcpServer.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("[ERROR] MCP server failed: %v", err)
+		}
+		return
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/prompt", h.HandlePrompt)
+	mux.HandleFunc("/prompt/batch", h.HandleBatch)
+	mux.HandleFunc("/compare", h.HandleCompare)
+	mux.HandleFunc("/sessions", h.HandleSessions)
+	mux.HandleFunc("/sessions/{id}", h.HandleSession)
+	mux.HandleFunc("/sessions/{id}/messages", h.HandleSessionMessages)
+	mux.HandleFunc("/jobs", h.HandleJobs)
+	mux.HandleFunc("/jobs/{id}", h.HandleJob)
+	mux.HandleFunc("/ws", h.HandleWebSocket)
+	mux.HandleFunc("/mcp", h.HandleMCP(mcpServer))
 	mux.HandleFunc("/providers", h.HandleProviders)
+	mux.HandleFunc("/models", h.HandleModels)
+	mux.HandleFunc("/prompts", h.HandlePrompts)
+	mux.HandleFunc("/v1/chat/completions", h.HandleChatCompletions)
+	mux.HandleFunc("/v1/models", h.HandleOpenAIModels)
+	mux.HandleFunc("/v1/messages", h.HandleMessages)
+	mux.HandleFunc("/v1beta/models/", h.HandleGemini)
+	if cfg.OllamaAPI {
+		mux.HandleFunc("/api/generate", h.HandleOllamaGenerate)
+		mux.HandleFunc("/api/chat", h.HandleOllamaChat)
+		mux.HandleFunc("/api/tags", h.HandleOllamaTags)
+	}
 	mux.HandleFunc("/health", h.HandleHealth)
 	mux.HandleFunc("/openapi.json", h.HandleOpenAPI)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      mux,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
+	server.RegisterOnShutdown(h.CloseSockets)
 
 	// Channel to listen for shutdown signals
 	done := make(chan os.Signal, 1)
@@ -75,11 +199,24 @@ func main() {
 		fmt.Printf("Local AI Tool Proxy active at %s://localhost:%d\n", protocol, cfg.Port)
 		fmt.Printf("Default provider: %s\n", cfg.Provider)
 		fmt.Printf("System prompt: %s\n", cfg.SystemPromptPath)
+		if cfg.PromptsDir != "" {
+			fmt.Printf("Prompts directory: %s (%d prompts)\n", cfg.PromptsDir, len(cfg.Prompts.List()))
+		}
+		fmt.Printf("System prompt override: %s\n", cfg.SystemOverride.Default)
 		fmt.Printf("Allowed origin: %s\n", cfg.AllowedOrigin)
 		if cfg.TLSEnabled() {
 			fmt.Printf("TLS enabled: cert=%s, key=%s\n", cfg.TLSCert, cfg.TLSKey)
 		}
-		fmt.Println("Available providers: claude, gemini, codex, continue, opencode")
+		fmt.Printf("Available providers: %s\n", strings.Join(providerNames, ", "))
+		for _, name := range providerNames {
+			if chain := cfg.Fallbacks[name]; len(chain) > 0 {
+				fmt.Printf("Fallbacks for %s: %s\n", name, strings.Join(chain, " -> "))
+			}
+		}
+		if cfg.OllamaAPI {
+			fmt.Printf("Ollama API: %s://localhost:%d/api\n", protocol, cfg.Port)
+		}
+		fmt.Printf("MCP endpoint: %s://localhost:%d/mcp\n", protocol, cfg.Port)
 		fmt.Printf("API docs: %s://localhost:%d/openapi.json\n", protocol, cfg.Port)
 		fmt.Println("Press Ctrl+C to stop")
 
@@ -96,6 +233,7 @@ func main() {
 
 	<-done
 	fmt.Println("\nShutting down...")
+	stopProbes()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -103,6 +241,7 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server shutdown failed: %v", err)
 	}
+	h.CancelJobs()
 
 	fmt.Println("Server stopped")
 }
@@ -72,3 +72,62 @@ func TestUnknownProvider(t *testing.T) {
 		t.Errorf("expected error to mention 'invalid-provider', got %q", outputStr)
 	}
 }
+
+func TestDefaultProviderNotInstalled(t *testing.T) {
+	dir := t.TempDir()
+	promptPath := filepath.Join(dir, "system-prompt.txt")
+	if err := os.WriteFile(promptPath, []byte("You are a helpful assistant."), 0644); err != nil {
+		t.Fatalf("failed to create temp system prompt: %v", err)
+	}
+	providersPath := filepath.Join(dir, "providers.json")
+	providers := `{"providers": [{"name": "ghost", "command": "ghost-cli-not-installed", "args": ["{{user}}"]}]}`
+	if err := os.WriteFile(providersPath, []byte(providers), 0644); err != nil {
+		t.Fatalf("failed to create temp providers file: %v", err)
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Env = append(os.Environ(),
+		"LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT="+promptPath,
+		"LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE="+providersPath,
+		"LOCAL_AI_TOOL_PROXY_PROVIDER=ghost",
+	)
+	output, err := cmd.CombinedOutput()
+
+	// Should exit with error
+	if err == nil {
+		t.Fatal("expected command to fail when the default provider is not installed")
+	}
+
+	outputStr := string(output)
+	if !strings.Contains(outputStr, "Default provider ghost is not available") {
+		t.Errorf("expected error about the missing default provider, got %q", outputStr)
+	}
+	if !strings.Contains(outputStr, "ghost-cli-not-installed") {
+		t.Errorf("expected error to mention the binary, got %q", outputStr)
+	}
+}
+
+func TestUnknownFallbackProvider(t *testing.T) {
+	dir := t.TempDir()
+	promptPath := filepath.Join(dir, "system-prompt.txt")
+	if err := os.WriteFile(promptPath, []byte("You are a helpful assistant."), 0644); err != nil {
+		t.Fatalf("failed to create temp system prompt: %v", err)
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Env = append(os.Environ(),
+		"LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT="+promptPath,
+		"LOCAL_AI_TOOL_PROXY_FALLBACKS=claude=gemini,invalid-provider",
+	)
+	output, err := cmd.CombinedOutput()
+
+	// Should exit with error
+	if err == nil {
+		t.Fatal("expected command to fail with unknown fallback provider")
+	}
+
+	outputStr := string(output)
+	if !strings.Contains(outputStr, "Unknown provider in fallback chain for claude: invalid-provider") {
+		t.Errorf("expected error about the unknown fallback provider, got %q", outputStr)
+	}
+}
@@ -1,16 +1,40 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
 )
 
 const (
 	defaultPort          = 4000
 	defaultAllowedOrigin = "http://localhost:3000"
 	defaultProvider      = "claude"
+	defaultProbeInterval = 5 * time.Minute
+
+	defaultPromptReloadInterval = 2 * time.Second
+
+	defaultMaxConcurrency   = 2
+	defaultQueueSize        = 10
+	defaultTimeout          = 5 * time.Minute
+	defaultReadTimeout      = 30 * time.Second
+	defaultIdleTimeout      = 120 * time.Second
+	defaultJobRetention     = time.Hour
+	defaultSessionTTL       = 24 * time.Hour
+	defaultBatchConcurrency = 4
+	defaultSchemaRetries    = 2
+	defaultWebhookAttempts  = 5
+
+	// writeTimeoutMargin is added to the longest provider timeout to derive
+	// the default server write timeout, leaving time to send the response.
+	writeTimeoutMargin = 10 * time.Second
 )
 
 // Config holds the application configuration.
@@ -22,6 +46,81 @@ type Config struct {
 	SystemPrompt     string
 	TLSCert          string
 	TLSKey           string
+
+	// PromptsDir is the directory of named system prompts requests may
+	// select instead of the default system prompt.
+	PromptsDir string
+	// Prompts holds the system prompt and the named system prompts read
+	// from PromptsDir. SystemPrompt is its system prompt at startup.
+	Prompts *prompt.Library
+	// PromptReloadInterval is how often the prompt files are checked for
+	// changes. Zero disables reloading on change.
+	PromptReloadInterval time.Duration
+	// SystemOverride governs system prompts sent with requests, per API key
+	// and origin.
+	SystemOverride prompt.OverridePolicy
+
+	// Models maps provider names to the models clients may select.
+	Models map[string][]string
+
+	// Fallbacks maps provider names to the providers tried in order when
+	// the provider fails with a retryable error.
+	Fallbacks map[string][]string
+	// FallbackCodes are provider error codes that fall back in addition to
+	// the retryable ones.
+	FallbackCodes []string
+
+	// ProvidersFile is the path of the file declaring command providers.
+	ProvidersFile string
+	// CommandProviders are additional providers declared in ProvidersFile.
+	CommandProviders []provider.CommandSpec
+
+	// MaxConcurrency is the number of concurrent CLI runs allowed per
+	// provider, unless overridden in ProviderConcurrency.
+	MaxConcurrency int
+	// ProviderConcurrency overrides MaxConcurrency per provider.
+	ProviderConcurrency map[string]int
+	// QueueSize is the number of requests per provider that may wait for a
+	// free slot before further requests are rejected.
+	QueueSize int
+
+	// Timeout bounds each CLI run, unless overridden in ProviderTimeouts.
+	Timeout time.Duration
+	// ProviderTimeouts overrides Timeout per provider.
+	ProviderTimeouts map[string]time.Duration
+
+	// ReadTimeout, WriteTimeout and IdleTimeout configure the HTTP server.
+	// WriteTimeout defaults to the longest provider timeout plus a margin.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// ProbeInterval is how often provider installations are re-checked.
+	// Zero disables periodic checks.
+	ProbeInterval time.Duration
+
+	// BatchConcurrency is the number of items of a batch that run
+	// concurrently.
+	BatchConcurrency int
+
+	// SchemaRetries is the number of times a response that does not match
+	// the request's JSON Schema is sent back to the provider for repair.
+	SchemaRetries int
+
+	// JobRetention is how long finished jobs are kept for polling.
+	JobRetention time.Duration
+
+	// SessionTTL is how long sessions are kept without a new turn.
+	SessionTTL time.Duration
+
+	// WebhookSecret signs webhook deliveries. Webhooks are disabled
+	// without a secret.
+	WebhookSecret string
+	// WebhookAttempts is the number of attempts to deliver a webhook.
+	WebhookAttempts int
+
+	// OllamaAPI enables the Ollama-compatible routes under /api.
+	OllamaAPI bool
 }
 
 // TLSEnabled returns true if both TLS cert and key are configured.
@@ -32,9 +131,22 @@ func (c Config) TLSEnabled() bool {
 // Load loads configuration from environment variables with sensible defaults.
 func Load() (Config, error) {
 	cfg := Config{
-		Port:          defaultPort,
-		AllowedOrigin: defaultAllowedOrigin,
-		Provider:      defaultProvider,
+		Port:             defaultPort,
+		AllowedOrigin:    defaultAllowedOrigin,
+		Provider:         defaultProvider,
+		MaxConcurrency:   defaultMaxConcurrency,
+		QueueSize:        defaultQueueSize,
+		Timeout:          defaultTimeout,
+		ReadTimeout:      defaultReadTimeout,
+		IdleTimeout:      defaultIdleTimeout,
+		JobRetention:     defaultJobRetention,
+		SessionTTL:       defaultSessionTTL,
+		BatchConcurrency: defaultBatchConcurrency,
+		SchemaRetries:    defaultSchemaRetries,
+		WebhookAttempts:  defaultWebhookAttempts,
+		ProbeInterval:    defaultProbeInterval,
+
+		PromptReloadInterval: defaultPromptReloadInterval,
 	}
 
 	if portStr := os.Getenv("LOCAL_AI_TOOL_PROXY_PORT"); portStr != "" {
@@ -54,21 +166,368 @@ func Load() (Config, error) {
 	cfg.TLSCert = os.Getenv("LOCAL_AI_TOOL_PROXY_TLS_CERT")
 	cfg.TLSKey = os.Getenv("LOCAL_AI_TOOL_PROXY_TLS_KEY")
 
+	if intervalStr := os.Getenv("LOCAL_AI_TOOL_PROXY_PROBE_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval < 0 {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_PROBE_INTERVAL: %q", intervalStr)
+		}
+		cfg.ProbeInterval = interval
+	}
+
+	ollamaAPI, err := parseBoolEnv("LOCAL_AI_TOOL_PROXY_OLLAMA_API")
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.OllamaAPI = ollamaAPI
+
+	maxConcurrency, err := parseIntEnv("LOCAL_AI_TOOL_PROXY_MAX_CONCURRENCY", cfg.MaxConcurrency, 1)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.MaxConcurrency = maxConcurrency
+
+	queueSize, err := parseIntEnv("LOCAL_AI_TOOL_PROXY_QUEUE_SIZE", cfg.QueueSize, 0)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.QueueSize = queueSize
+
+	batchConcurrency, err := parseIntEnv("LOCAL_AI_TOOL_PROXY_BATCH_CONCURRENCY", cfg.BatchConcurrency, 1)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.BatchConcurrency = batchConcurrency
+
+	schemaRetries, err := parseIntEnv("LOCAL_AI_TOOL_PROXY_SCHEMA_RETRIES", cfg.SchemaRetries, 0)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.SchemaRetries = schemaRetries
+
+	cfg.WebhookSecret = os.Getenv("LOCAL_AI_TOOL_PROXY_WEBHOOK_SECRET")
+	webhookAttempts, err := parseIntEnv("LOCAL_AI_TOOL_PROXY_WEBHOOK_ATTEMPTS", cfg.WebhookAttempts, 1)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.WebhookAttempts = webhookAttempts
+
+	concurrency, err := parseProviderMap(os.Getenv("LOCAL_AI_TOOL_PROXY_PROVIDER_CONCURRENCY"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_PROVIDER_CONCURRENCY: %w", err)
+	}
+	cfg.ProviderConcurrency = make(map[string]int, len(concurrency))
+	for name, value := range concurrency {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_PROVIDER_CONCURRENCY: %s must be a positive number, got %q", name, value)
+		}
+		cfg.ProviderConcurrency[name] = n
+	}
+
+	timeout, err := parseDurationEnv("LOCAL_AI_TOOL_PROXY_TIMEOUT", cfg.Timeout)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Timeout = timeout
+
+	timeouts, err := parseProviderMap(os.Getenv("LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS: %w", err)
+	}
+	cfg.ProviderTimeouts = make(map[string]time.Duration, len(timeouts))
+	for name, value := range timeouts {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS: %s must be a positive duration, got %q", name, value)
+		}
+		cfg.ProviderTimeouts[name] = d
+	}
+
+	readTimeout, err := parseDurationEnv("LOCAL_AI_TOOL_PROXY_READ_TIMEOUT", cfg.ReadTimeout)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.ReadTimeout = readTimeout
+
+	idleTimeout, err := parseDurationEnv("LOCAL_AI_TOOL_PROXY_IDLE_TIMEOUT", cfg.IdleTimeout)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.IdleTimeout = idleTimeout
+
+	// The write timeout must leave room for the slowest provider
+	writeTimeout, err := parseDurationEnv("LOCAL_AI_TOOL_PROXY_WRITE_TIMEOUT", cfg.MaxTimeout()+writeTimeoutMargin)
+	if err != nil {
+		return Config{}, err
+	}
+	if writeTimeout < cfg.MaxTimeout() {
+		return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_WRITE_TIMEOUT: %s is shorter than the longest provider timeout of %s", writeTimeout, cfg.MaxTimeout())
+	}
+	cfg.WriteTimeout = writeTimeout
+
+	jobRetention, err := parseDurationEnv("LOCAL_AI_TOOL_PROXY_JOB_RETENTION", cfg.JobRetention)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.JobRetention = jobRetention
+
+	sessionTTL, err := parseDurationEnv("LOCAL_AI_TOOL_PROXY_SESSION_TTL", cfg.SessionTTL)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.SessionTTL = sessionTTL
+
+	models, err := parseProviderMap(os.Getenv("LOCAL_AI_TOOL_PROXY_MODELS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_MODELS: %w", err)
+	}
+	cfg.Models = make(map[string][]string, len(models))
+	for name, value := range models {
+		cfg.Models[name] = splitList(value)
+	}
+
+	fallbacks, err := parseProviderMap(os.Getenv("LOCAL_AI_TOOL_PROXY_FALLBACKS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_FALLBACKS: %w", err)
+	}
+	cfg.Fallbacks = make(map[string][]string, len(fallbacks))
+	for name, value := range fallbacks {
+		chain := splitList(value)
+		if slices.Contains(chain, name) {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_FALLBACKS: %s falls back to itself", name)
+		}
+		cfg.Fallbacks[name] = chain
+	}
+
+	cfg.FallbackCodes = splitList(os.Getenv("LOCAL_AI_TOOL_PROXY_FALLBACK_CODES"))
+	for _, code := range cfg.FallbackCodes {
+		if !slices.Contains(provider.ErrorCodes, provider.ErrorCode(code)) {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_FALLBACK_CODES: unknown error code %q", code)
+		}
+	}
+
+	cfg.ProvidersFile = os.Getenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE")
+	if cfg.ProvidersFile != "" {
+		specs, err := loadCommandProviders(cfg.ProvidersFile)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.CommandProviders = specs
+	}
+	for _, spec := range cfg.CommandProviders {
+		if len(spec.ModelArgs) == 0 && len(cfg.Models[spec.Name]) > 0 {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_MODELS: provider %s has no model_args and cannot select models", spec.Name)
+		}
+	}
+
+	if intervalStr := os.Getenv("LOCAL_AI_TOOL_PROXY_PROMPT_RELOAD_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval < 0 {
+			return Config{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_PROMPT_RELOAD_INTERVAL: %q", intervalStr)
+		}
+		cfg.PromptReloadInterval = interval
+	}
+
+	systemOverride, err := loadOverridePolicy()
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.SystemOverride = systemOverride
+
 	// System prompt file is required
 	cfg.SystemPromptPath = os.Getenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
 	if cfg.SystemPromptPath == "" {
 		return Config{}, fmt.Errorf("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT environment variable is required")
 	}
+	cfg.PromptsDir = os.Getenv("LOCAL_AI_TOOL_PROXY_PROMPTS_DIR")
+
+	prompts, err := prompt.Load(cfg.SystemPromptPath, cfg.PromptsDir)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Prompts = prompts
+	cfg.SystemPrompt = prompts.System().Text
 
-	data, err := os.ReadFile(cfg.SystemPromptPath)
+	return cfg, nil
+}
+
+// loadCommandProviders reads and validates command provider declarations
+// from a JSON file of the form {"providers": [...]}.
+func loadCommandProviders(path string) ([]provider.CommandSpec, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to read system prompt file: %w", err)
+		return nil, fmt.Errorf("failed to read providers file: %w", err)
 	}
 
-	cfg.SystemPrompt = strings.TrimSpace(string(data))
-	if cfg.SystemPrompt == "" {
-		return Config{}, fmt.Errorf("system prompt file is empty: %s", cfg.SystemPromptPath)
+	var file struct {
+		Providers []provider.CommandSpec `json:"providers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse providers file: %w", err)
 	}
 
-	return cfg, nil
+	seen := make(map[string]bool, len(file.Providers))
+	for i := range file.Providers {
+		spec := &file.Providers[i]
+		if err := spec.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid providers file: %w", err)
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("invalid providers file: duplicate provider %s", spec.Name)
+		}
+		seen[spec.Name] = true
+	}
+
+	return file.Providers, nil
+}
+
+// loadOverridePolicy reads the policy for system prompts sent with requests:
+// a default, and overrides per origin and per API key.
+func loadOverridePolicy() (prompt.OverridePolicy, error) {
+	policy := prompt.OverridePolicy{Default: prompt.OverrideDisabled}
+
+	if value := os.Getenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE"); value != "" {
+		o, err := prompt.ParseOverride(value)
+		if err != nil {
+			return prompt.OverridePolicy{}, fmt.Errorf("invalid LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE: %w", err)
+		}
+		policy.Default = o
+	}
+
+	origins, err := parseOverrideMap("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_ORIGINS")
+	if err != nil {
+		return prompt.OverridePolicy{}, err
+	}
+	policy.Origins = origins
+
+	keys, err := parseOverrideMap("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_KEYS")
+	if err != nil {
+		return prompt.OverridePolicy{}, err
+	}
+	policy.APIKeys = keys
+
+	return policy, nil
+}
+
+// parseOverrideMap reads overrides of the form "name=append;name=replace"
+// from the named environment variable.
+func parseOverrideMap(name string) (map[string]prompt.Override, error) {
+	entries, err := parseProviderMap(os.Getenv(name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	overrides := make(map[string]prompt.Override, len(entries))
+	for key, value := range entries {
+		// The error omits the key, which may be an API key
+		o, err := prompt.ParseOverride(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		overrides[key] = o
+	}
+	return overrides, nil
+}
+
+// ConcurrencyFor returns the number of concurrent CLI runs allowed for the
+// named provider.
+func (c Config) ConcurrencyFor(name string) int {
+	if n, ok := c.ProviderConcurrency[name]; ok {
+		return n
+	}
+	return c.MaxConcurrency
+}
+
+// TimeoutFor returns the CLI run timeout of the named provider.
+func (c Config) TimeoutFor(name string) time.Duration {
+	if d, ok := c.ProviderTimeouts[name]; ok {
+		return d
+	}
+	return c.Timeout
+}
+
+// MaxTimeout returns the longest CLI run timeout of any provider.
+func (c Config) MaxTimeout() time.Duration {
+	longest := c.Timeout
+	for _, d := range c.ProviderTimeouts {
+		longest = max(longest, d)
+	}
+	return longest
+}
+
+// parseIntEnv reads an integer environment variable that must be at least
+// minValue, returning fallback if it is not set.
+func parseIntEnv(name string, fallback, minValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minValue {
+		return 0, fmt.Errorf("invalid %s: must be a number of at least %d, got %q", name, minValue, value)
+	}
+	return n, nil
+}
+
+// parseBoolEnv reads a boolean environment variable, returning false if it
+// is not set.
+func parseBoolEnv(name string) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: must be true or false, got %q", name, value)
+	}
+	return b, nil
+}
+
+// parseDurationEnv reads a positive duration from the named environment
+// variable, returning fallback if it is unset.
+func parseDurationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be a positive duration, got %q", name, value)
+	}
+	return d, nil
+}
+
+// parseProviderMap parses per-provider settings of the form
+// "claude=value;gemini=value" into a map keyed by provider name.
+func parseProviderMap(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("expected name=value, got %q", entry)
+		}
+
+		name := strings.TrimSpace(entry[:i])
+		result[name] = strings.TrimSpace(entry[i+1:])
+	}
+	return result, nil
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -3,7 +3,12 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
 )
 
 // createTempSystemPrompt creates a temp file with the given content and returns its path.
@@ -274,3 +279,656 @@ func TestLoad_SystemPromptFileSuccess(t *testing.T) {
 		t.Errorf("expected system prompt path %s, got %s", path, cfg.SystemPromptPath)
 	}
 }
+
+func TestLoad_Models(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_MODELS", "claude=sonnet, opus; gemini=gemini-2.5-pro")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_MODELS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cfg.Models) != 2 {
+		t.Fatalf("expected models for 2 providers, got %v", cfg.Models)
+	}
+	if got := cfg.Models["claude"]; len(got) != 2 || got[0] != "sonnet" || got[1] != "opus" {
+		t.Errorf("unexpected claude models: %q", got)
+	}
+	if got := cfg.Models["gemini"]; len(got) != 1 || got[0] != "gemini-2.5-pro" {
+		t.Errorf("unexpected gemini models: %q", got)
+	}
+}
+
+func TestLoad_InvalidModels(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_MODELS", "sonnet,opus")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_MODELS")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for models without provider name")
+	}
+}
+
+func TestParseProviderMap(t *testing.T) {
+	got, err := parseProviderMap(" claude = a ; https://example.com=b;;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 2 || got["claude"] != "a" || got["https://example.com"] != "b" {
+		t.Errorf("unexpected map: %v", got)
+	}
+}
+
+// createTempProvidersFile creates a temp providers file with the given content and returns its path.
+func createTempProvidersFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "providers.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create temp providers file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_CommandProviders(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	path := createTempProvidersFile(t, `{
+  "providers": [
+    {
+      "name": "aider",
+      "description": "Aider",
+      "command": "aider",
+      "args": ["--no-git", "--message", "{{user}}"],
+      "output": {"format": "text"}
+    },
+    {
+      "name": "llm",
+      "command": "llm",
+      "prompt_mode": "stdin",
+      "output": {"format": "json", "path": "response"}
+    }
+  ]
+}`)
+	os.Setenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE", path)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cfg.CommandProviders) != 2 {
+		t.Fatalf("expected 2 command providers, got %d", len(cfg.CommandProviders))
+	}
+	if cfg.CommandProviders[0].Name != "aider" || cfg.CommandProviders[0].PromptMode != "argv" {
+		t.Errorf("unexpected first provider: %+v", cfg.CommandProviders[0])
+	}
+	if cfg.CommandProviders[1].Output.Path != "response" {
+		t.Errorf("unexpected second provider: %+v", cfg.CommandProviders[1])
+	}
+}
+
+func TestLoad_InvalidCommandProviders(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid json", `{"providers": [`},
+		{"invalid spec", `{"providers": [{"name": "x"}]}`},
+		{"duplicate", `{"providers": [{"name": "x", "command": "x", "prompt_mode": "stdin"}, {"name": "x", "command": "y", "prompt_mode": "stdin"}]}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+			os.Setenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE", createTempProvidersFile(t, tc.content))
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE")
+
+			if _, err := Load(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_ModelsWithoutModelArgs(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE", createTempProvidersFile(t, `{"providers": [
+		{"name": "aider", "command": "aider", "args": ["--message", "{{user}}"]},
+		{"name": "llm", "command": "llm", "prompt_mode": "stdin", "model_args": ["-m", "{{model}}"]}
+	]}`))
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROVIDERS_FILE")
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_MODELS", "llm=gpt-4o")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_MODELS")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_MODELS", "aider=sonnet")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "aider has no model_args") {
+		t.Errorf("expected model_args error, got %v", err)
+	}
+}
+
+func TestLoad_PromptsDir(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "support.md"), []byte("---\ndescription: Support\n---\nYou help customers."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("LOCAL_AI_TOOL_PROXY_PROMPTS_DIR", dir)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROMPTS_DIR")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p, ok := cfg.Prompts.Get("support")
+	if !ok {
+		t.Fatal("expected support prompt to be loaded")
+	}
+	if p.Text != "You help customers." {
+		t.Errorf("unexpected prompt text: %q", p.Text)
+	}
+}
+
+func TestLoad_InvalidPromptsDir(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "empty.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("LOCAL_AI_TOOL_PROXY_PROMPTS_DIR", dir)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROMPTS_DIR")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for empty prompt file")
+	}
+}
+
+func TestLoad_ProbeInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"default", "", 5 * time.Minute, false},
+		{"custom", "30s", 30 * time.Second, false},
+		{"disabled", "0", 0, false},
+		{"invalid", "often", 0, true},
+		{"negative", "-1m", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+			os.Setenv("LOCAL_AI_TOOL_PROXY_PROBE_INTERVAL", tc.value)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROBE_INTERVAL")
+
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.ProbeInterval != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, cfg.ProbeInterval)
+			}
+		})
+	}
+}
+
+func TestLoad_PromptReloadInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"default", "", 2 * time.Second, false},
+		{"custom", "10s", 10 * time.Second, false},
+		{"disabled", "0", 0, false},
+		{"invalid", "often", 0, true},
+		{"negative", "-1s", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+			os.Setenv("LOCAL_AI_TOOL_PROXY_PROMPT_RELOAD_INTERVAL", tc.value)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROMPT_RELOAD_INTERVAL")
+
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.PromptReloadInterval != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, cfg.PromptReloadInterval)
+			}
+		})
+	}
+}
+
+func TestLoad_SystemOverride(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SystemOverride.Default != prompt.OverrideDisabled {
+		t.Errorf("expected overrides to be disabled by default, got %s", cfg.SystemOverride.Default)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE", "append")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_ORIGINS", "https://public.example.com=disabled")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_KEYS", "secret-key=replace")
+	defer func() {
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE")
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_ORIGINS")
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_KEYS")
+	}()
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SystemOverride.Default != prompt.OverrideAppend {
+		t.Errorf("expected append, got %s", cfg.SystemOverride.Default)
+	}
+	if cfg.SystemOverride.Origins["https://public.example.com"] != prompt.OverrideDisabled {
+		t.Errorf("unexpected origins: %v", cfg.SystemOverride.Origins)
+	}
+	if cfg.SystemOverride.APIKeys["secret-key"] != prompt.OverrideReplace {
+		t.Errorf("unexpected API keys: %v", cfg.SystemOverride.APIKeys)
+	}
+}
+
+func TestLoad_InvalidSystemOverride(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+	}{
+		{"default", "LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE", "prepend"},
+		{"origin", "LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_ORIGINS", "https://app.example.com=always"},
+		{"key", "LOCAL_AI_TOOL_PROXY_SYSTEM_OVERRIDE_KEYS", "secret-key"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+			os.Setenv(tc.env, tc.value)
+			defer os.Unsetenv(tc.env)
+
+			if _, err := Load(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_Concurrency(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxConcurrency != 2 || cfg.QueueSize != 10 {
+		t.Errorf("expected defaults 2 and 10, got %d and %d", cfg.MaxConcurrency, cfg.QueueSize)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_MAX_CONCURRENCY", "3")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_QUEUE_SIZE", "0")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_PROVIDER_CONCURRENCY", "claude=1")
+	defer func() {
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_MAX_CONCURRENCY")
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_QUEUE_SIZE")
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROVIDER_CONCURRENCY")
+	}()
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.QueueSize != 0 {
+		t.Errorf("expected queue size 0, got %d", cfg.QueueSize)
+	}
+	if got := cfg.ConcurrencyFor("claude"); got != 1 {
+		t.Errorf("expected claude concurrency 1, got %d", got)
+	}
+	if got := cfg.ConcurrencyFor("gemini"); got != 3 {
+		t.Errorf("expected gemini concurrency 3, got %d", got)
+	}
+}
+
+func TestLoad_InvalidConcurrency(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+	}{
+		{"zero max", "LOCAL_AI_TOOL_PROXY_MAX_CONCURRENCY", "0"},
+		{"non-numeric max", "LOCAL_AI_TOOL_PROXY_MAX_CONCURRENCY", "many"},
+		{"negative queue", "LOCAL_AI_TOOL_PROXY_QUEUE_SIZE", "-1"},
+		{"zero provider", "LOCAL_AI_TOOL_PROXY_PROVIDER_CONCURRENCY", "claude=0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+			os.Setenv(tc.env, tc.value)
+			defer os.Unsetenv(tc.env)
+
+			if _, err := Load(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_Fallbacks(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_FALLBACKS", "claude=gemini, codex;gemini=codex")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_FALLBACKS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := cfg.Fallbacks["claude"]; !slices.Equal(got, []string{"gemini", "codex"}) {
+		t.Errorf("unexpected claude fallbacks: %v", got)
+	}
+	if got := cfg.Fallbacks["gemini"]; !slices.Equal(got, []string{"codex"}) {
+		t.Errorf("unexpected gemini fallbacks: %v", got)
+	}
+}
+
+func TestLoad_FallbackToSelf(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_FALLBACKS", "claude=gemini,claude")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_FALLBACKS")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLoad_FallbackCodes(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_FALLBACK_CODES", "auth_required, binary_not_found")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_FALLBACK_CODES")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.FallbackCodes, []string{"auth_required", "binary_not_found"}) {
+		t.Errorf("unexpected fallback codes: %v", cfg.FallbackCodes)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_FALLBACK_CODES", "auth_required,invalid_output")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for unknown code")
+	}
+}
+
+func TestLoad_Timeouts(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Timeout != 5*time.Minute || cfg.ReadTimeout != 30*time.Second || cfg.IdleTimeout != 120*time.Second {
+		t.Errorf("unexpected default timeouts: %v, %v, %v", cfg.Timeout, cfg.ReadTimeout, cfg.IdleTimeout)
+	}
+	if cfg.WriteTimeout != 5*time.Minute+10*time.Second {
+		t.Errorf("expected write timeout derived from the provider timeout, got %v", cfg.WriteTimeout)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_TIMEOUT", "2m")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS", "codex=15m")
+	defer func() {
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_TIMEOUT")
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS")
+	}()
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.TimeoutFor("codex"); got != 15*time.Minute {
+		t.Errorf("expected codex timeout 15m, got %v", got)
+	}
+	if got := cfg.TimeoutFor("claude"); got != 2*time.Minute {
+		t.Errorf("expected claude timeout 2m, got %v", got)
+	}
+	if cfg.WriteTimeout != 15*time.Minute+10*time.Second {
+		t.Errorf("expected write timeout derived from the longest provider timeout, got %v", cfg.WriteTimeout)
+	}
+}
+
+func TestLoad_InvalidTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"invalid timeout", map[string]string{"LOCAL_AI_TOOL_PROXY_TIMEOUT": "soon"}},
+		{"zero timeout", map[string]string{"LOCAL_AI_TOOL_PROXY_TIMEOUT": "0"}},
+		{"invalid provider timeout", map[string]string{"LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS": "codex=-1m"}},
+		{"invalid read timeout", map[string]string{"LOCAL_AI_TOOL_PROXY_READ_TIMEOUT": "30"}},
+		{"write timeout too short", map[string]string{
+			"LOCAL_AI_TOOL_PROXY_PROVIDER_TIMEOUTS": "codex=15m",
+			"LOCAL_AI_TOOL_PROXY_WRITE_TIMEOUT":     "10m",
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+			for name, value := range tc.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			if _, err := Load(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_OllamaAPI(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.OllamaAPI {
+		t.Error("expected Ollama API to be disabled by default")
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_OLLAMA_API", "true")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_OLLAMA_API")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.OllamaAPI {
+		t.Error("expected Ollama API to be enabled")
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_OLLAMA_API", "sometimes")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLoad_JobRetention(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.JobRetention != time.Hour {
+		t.Errorf("expected default job retention 1h, got %v", cfg.JobRetention)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_JOB_RETENTION", "15m")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_JOB_RETENTION")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.JobRetention != 15*time.Minute {
+		t.Errorf("expected job retention 15m, got %v", cfg.JobRetention)
+	}
+}
+
+func TestLoad_SessionTTL(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SessionTTL != 24*time.Hour {
+		t.Errorf("expected default session TTL 24h, got %v", cfg.SessionTTL)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SESSION_TTL", "30m")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SESSION_TTL")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SessionTTL != 30*time.Minute {
+		t.Errorf("expected session TTL 30m, got %v", cfg.SessionTTL)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SESSION_TTL", "0")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for session TTL 0")
+	}
+}
+
+func TestLoad_BatchConcurrency(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BatchConcurrency != 4 {
+		t.Errorf("expected default batch concurrency 4, got %d", cfg.BatchConcurrency)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_BATCH_CONCURRENCY", "0")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_BATCH_CONCURRENCY")
+
+	if _, err := Load(); err == nil {
+		t.Error("expected error for batch concurrency 0")
+	}
+}
+
+func TestLoad_SchemaRetries(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SchemaRetries != 2 {
+		t.Errorf("expected default schema retries 2, got %d", cfg.SchemaRetries)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SCHEMA_RETRIES", "0")
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SCHEMA_RETRIES")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SchemaRetries != 0 {
+		t.Errorf("expected schema retries 0, got %d", cfg.SchemaRetries)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_SCHEMA_RETRIES", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for negative schema retries")
+	}
+}
+
+func TestLoad_Webhooks(t *testing.T) {
+	setRequiredEnv(t)
+	defer os.Unsetenv("LOCAL_AI_TOOL_PROXY_SYSTEM_PROMPT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.WebhookSecret != "" || cfg.WebhookAttempts != 5 {
+		t.Errorf("expected no secret and 5 attempts, got %q and %d", cfg.WebhookSecret, cfg.WebhookAttempts)
+	}
+
+	os.Setenv("LOCAL_AI_TOOL_PROXY_WEBHOOK_SECRET", "s3cret")
+	os.Setenv("LOCAL_AI_TOOL_PROXY_WEBHOOK_ATTEMPTS", "3")
+	defer func() {
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_WEBHOOK_SECRET")
+		os.Unsetenv("LOCAL_AI_TOOL_PROXY_WEBHOOK_ATTEMPTS")
+	}()
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.WebhookSecret != "s3cret" || cfg.WebhookAttempts != 3 {
+		t.Errorf("expected configured webhooks, got %q and %d", cfg.WebhookSecret, cfg.WebhookAttempts)
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// MessagesRequest is the subset of an Anthropic Messages API request
+// supported by the proxy. Model selects the provider, or is an Anthropic
+// model ID run by the claude provider. MaxTokens is accepted
+// for compatibility but not enforced, as the CLIs cannot limit their output.
+type MessagesRequest struct {
+	Model     string        `json:"model"`
+	System    ChatContent   `json:"system"`
+	Messages  []ChatMessage `json:"messages"`
+	MaxTokens int           `json:"max_tokens"`
+	Stream    bool          `json:"stream,omitempty"`
+}
+
+// MessagesResponse is an Anthropic Messages API response.
+type MessagesResponse struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Role         string         `json:"role"`
+	Model        string         `json:"model"`
+	Content      []ContentBlock `json:"content"`
+	StopReason   *string        `json:"stop_reason"`
+	StopSequence *string        `json:"stop_sequence"`
+	Usage        MessagesUsage  `json:"usage"`
+}
+
+// ContentBlock is a text content block of a Messages API response.
+type ContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// MessagesUsage reports token usage. It is zero for streamed messages and
+// for providers whose CLI does not report usage.
+type MessagesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicError is the Anthropic error response format, also used for the
+// "error" stream event.
+type anthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// HandleMessages handles POST /v1/messages requests.
+func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		sendAnthropicError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		sendAnthropicError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxTokens < 0 {
+		sendAnthropicError(w, "The 'max_tokens' field must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	p, providerName, err := h.resolveVendorModelID(req.Model, "claude")
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendAnthropicError(w, err.Error(), compatStatus(err))
+		return
+	}
+
+	userPrompt, err := messagesPrompt(req.Messages)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendAnthropicError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	systemPrompt, err := h.withSystemPrompt(providerName, []string{string(req.System)}, h.overrideFor(r))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendAnthropicError(w, err.Error(), systemPromptStatus(err))
+		return
+	}
+
+	log.Printf("[INFO] Generating message using %s for prompt: %q", providerName, userPrompt)
+
+	g := generation{
+		provider:     p,
+		providerName: providerName,
+		systemPrompt: systemPrompt,
+		userPrompt:   userPrompt,
+	}
+	message := MessagesResponse{
+		ID:      newResponseID("msg_"),
+		Type:    "message",
+		Role:    session.RoleAssistant,
+		Model:   req.Model,
+		Content: []ContentBlock{},
+	}
+	if message.Model == "" {
+		message.Model = providerName
+	}
+
+	if req.Stream {
+		h.streamMessage(w, r, g, message)
+		return
+	}
+
+	g.onPosition = queuePositionHeader(w)
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		if resp, status, ok := h.compatFailure(w, r, res); ok {
+			sendAnthropicError(w, resp.Error, status)
+		}
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated message using %s", res.provider)
+	message.Model = answeredModel(req.Model, providerName, res.provider)
+	message.Content = []ContentBlock{{Type: "text", Text: res.text}}
+	if res.usage != nil {
+		message.Usage = MessagesUsage(*res.usage)
+	}
+	message.StopReason = stringPtr("end_turn")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// streamMessage serves a message as a stream of Messages API events with a
+// single text content block. Queue positions are sent as comments, which
+// clients ignore.
+func (h *Handler) streamMessage(w http.ResponseWriter, r *http.Request, g generation, message MessagesResponse) {
+	if _, ok := w.(http.Flusher); !ok {
+		sendAnthropicError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream is opened lazily so that a full queue can still be
+	// reported with a 429 status
+	var sse *sseWriter
+	openStream := func() {
+		if sse == nil {
+			sse, _ = newSSEWriter(w)
+		}
+	}
+	var started bool
+
+	g.onPosition = func(position int) {
+		openStream()
+		sse.write(": queue position %d\n\n", position)
+	}
+	g.onStart = func(string) {
+		openStream()
+		if started {
+			return
+		}
+		started = true
+		sse.send("message_start", map[string]any{"type": "message_start", "message": message})
+		sse.send("content_block_start", map[string]any{
+			"type":          "content_block_start",
+			"index":         0,
+			"content_block": ContentBlock{Type: "text"},
+		})
+		sse.send("ping", map[string]string{"type": "ping"})
+	}
+	g.onDelta = func(delta string) {
+		sse.send("content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]string{"type": "text_delta", "text": delta},
+		})
+	}
+
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		resp, status, ok := h.compatFailure(w, r, res)
+		if !ok {
+			return
+		}
+		if sse == nil {
+			sendAnthropicError(w, resp.Error, status)
+			return
+		}
+		sse.send("error", newAnthropicError(resp.Error, status))
+		return
+	}
+
+	log.Printf("[INFO] Successfully streamed message using %s", res.provider)
+	sse.send("content_block_stop", map[string]any{"type": "content_block_stop", "index": 0})
+	sse.send("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": "end_turn", "stop_sequence": nil},
+		"usage": map[string]int{"output_tokens": 0},
+	})
+	sse.send("message_stop", map[string]string{"type": "message_stop"})
+}
+
+// messagesPrompt replays Messages API messages into the user prompt.
+func messagesPrompt(messages []ChatMessage) (string, error) {
+	turns := make([]session.Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Role != session.RoleUser && m.Role != session.RoleAssistant {
+			return "", fmt.Errorf("Unsupported message role: %s", m.Role)
+		}
+		turns = append(turns, session.Message{Role: m.Role, Content: string(m.Content)})
+	}
+	return conversationPrompt(turns)
+}
+
+// newAnthropicError builds an Anthropic error response. The error type is
+// derived from the HTTP status.
+func newAnthropicError(message string, statusCode int) anthropicError {
+	resp := anthropicError{Type: "error"}
+	resp.Error.Message = message
+	switch statusCode {
+	case http.StatusUnauthorized:
+		resp.Error.Type = "authentication_error"
+	case http.StatusForbidden:
+		resp.Error.Type = "permission_error"
+	case http.StatusNotFound:
+		resp.Error.Type = "not_found_error"
+	case http.StatusTooManyRequests:
+		resp.Error.Type = "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		resp.Error.Type = "overloaded_error"
+	default:
+		if statusCode < http.StatusInternalServerError {
+			resp.Error.Type = "invalid_request_error"
+		} else {
+			resp.Error.Type = "api_error"
+		}
+	}
+	return resp
+}
+
+// sendAnthropicError sends an error response in the Anthropic format.
+func sendAnthropicError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(newAnthropicError(message, statusCode))
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+func postMessages(handler *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.HandleMessages(w, req)
+	return w
+}
+
+func TestHandleMessages(t *testing.T) {
+	gen := &promptGenerator{}
+	handler := newOverrideTestHandler(gen, prompt.OverrideAppend)
+
+	w := postMessages(handler, `{
+		"model": "claude",
+		"max_tokens": 1024,
+		"system": [{"type": "text", "text": "Answer briefly."}],
+		"messages": [
+			{"role": "user", "content": "Hi"},
+			{"role": "assistant", "content": [{"type": "text", "text": "Hello!"}]},
+			{"role": "user", "content": "How are you?"}
+		]
+	}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var message MessagesResponse
+	json.NewDecoder(w.Body).Decode(&message)
+	if message.Type != "message" || message.Role != "assistant" || message.Model != "claude" || !strings.HasPrefix(message.ID, "msg_") {
+		t.Errorf("unexpected message: %+v", message)
+	}
+	if len(message.Content) != 1 || message.Content[0].Type != "text" || message.Content[0].Text != "ok" {
+		t.Errorf("unexpected content: %+v", message.Content)
+	}
+	if message.StopReason == nil || *message.StopReason != "end_turn" {
+		t.Errorf("unexpected stop reason: %v", message.StopReason)
+	}
+
+	if gen.systemPrompt != "You are a test assistant.\n\nAnswer briefly." {
+		t.Errorf("unexpected system prompt: %q", gen.systemPrompt)
+	}
+	if !strings.Contains(gen.userPrompt, "Assistant: Hello!") || !strings.HasSuffix(gen.userPrompt, "How are you?") {
+		t.Errorf("expected transcript in user prompt, got %q", gen.userPrompt)
+	}
+}
+
+func TestHandleMessages_SystemNotAllowed(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "ok"})
+
+	w := postMessages(handler, `{"model": "claude", "max_tokens": 1024, "system": "Answer briefly.", "messages": [{"role": "user", "content": "Hi"}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp anthropicError
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error.Type != "permission_error" {
+		t.Errorf("unexpected error: %+v", resp)
+	}
+}
+
+func TestHandleMessages_Errors(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{err: errRateLimited})
+
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		errorType string
+	}{
+		{"invalid JSON", `{`, http.StatusBadRequest, "invalid_request_error"},
+		{"negative max_tokens", `{"max_tokens": -1, "messages": [{"role": "user", "content": "Hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"unknown provider", `{"model": "mistral/large", "messages": [{"role": "user", "content": "Hi"}]}`, http.StatusNotFound, "not_found_error"},
+		{"system role", `{"messages": [{"role": "system", "content": "Hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"last message from assistant", `{"messages": [{"role": "assistant", "content": "Hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"provider failure", `{"messages": [{"role": "user", "content": "Hi"}]}`, http.StatusTooManyRequests, "rate_limit_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postMessages(handler, tt.body)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			var resp anthropicError
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Type != "error" || resp.Error.Type != tt.errorType || resp.Error.Message == "" {
+				t.Errorf("unexpected error: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleMessages_AnthropicModelIDs(t *testing.T) {
+	handler := New(map[string]provider.Generator{
+		"claude": &modelGenerator{},
+		"gemini": &mockGenerator{response: "Hello from gemini"},
+	}, "gemini", "http://localhost:3000", "You are a test assistant.", WithModels(map[string][]string{"claude": {"claude-sonnet-4-5"}}))
+
+	tests := []struct {
+		model string
+		text  string
+	}{
+		{"claude-sonnet-4-5", "model=claude-sonnet-4-5"},
+		{"claude-opus-4-1", "model="},
+		{"gemini", "Hello from gemini"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			w := postMessages(handler, `{"model": "`+tt.model+`", "max_tokens": 1024, "messages": [{"role": "user", "content": "Hi"}]}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var message MessagesResponse
+			json.NewDecoder(w.Body).Decode(&message)
+			if message.Model != tt.model || len(message.Content) != 1 || message.Content[0].Text != tt.text {
+				t.Errorf("unexpected message: %+v", message)
+			}
+		})
+	}
+}
+
+func TestHandleMessages_Usage(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &usageGenerator{mockGenerator: mockGenerator{response: "ok"}, usage: provider.Usage{InputTokens: 12, OutputTokens: 3}},
+	}, "claude")
+
+	w := postMessages(handler, `{"model": "claude", "max_tokens": 1024, "messages": [{"role": "user", "content": "Hi"}]}`)
+
+	var message MessagesResponse
+	json.NewDecoder(w.Body).Decode(&message)
+	if message.Usage.InputTokens != 12 || message.Usage.OutputTokens != 3 {
+		t.Errorf("unexpected usage: %+v", message.Usage)
+	}
+}
+
+func TestHandleMessages_Stream(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hello", ", world!"}},
+	}, "claude")
+
+	w := postMessages(handler, `{"model": "claude", "max_tokens": 1024, "stream": true, "messages": [{"role": "user", "content": "Hi"}]}`)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	var names []string
+	var content strings.Builder
+	for _, event := range events {
+		names = append(names, event.name)
+
+		var payload struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(event.data), &payload); err != nil {
+			t.Fatalf("invalid event data %q: %v", event.data, err)
+		}
+		if payload.Type != event.name {
+			t.Errorf("event %q has type %q", event.name, payload.Type)
+		}
+		content.WriteString(payload.Delta.Text)
+	}
+
+	expected := "message_start,content_block_start,ping,content_block_delta,content_block_delta,content_block_stop,message_delta,message_stop"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("unexpected events: %v", names)
+	}
+	if content.String() != "Hello, world!" {
+		t.Errorf("unexpected content %q", content.String())
+	}
+}
+
+func TestHandleMessages_StreamError(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hel"}, err: provider.ErrCLIExecution},
+	}, "claude")
+
+	w := postMessages(handler, `{"stream": true, "messages": [{"role": "user", "content": "Hi"}]}`)
+
+	events := parseSSE(t, w.Body.String())
+	last := events[len(events)-1]
+	if last.name != "error" {
+		t.Fatalf("expected final error event, got %+v", last)
+	}
+
+	var resp anthropicError
+	json.Unmarshal([]byte(last.data), &resp)
+	if resp.Error.Type != "api_error" || resp.Error.Message != "Failed to generate response" {
+		t.Errorf("unexpected error: %+v", resp)
+	}
+}
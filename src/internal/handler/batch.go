@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+const (
+	// defaultBatchConcurrency is the number of batch items run concurrently
+	// unless configured otherwise.
+	defaultBatchConcurrency = 4
+	// maxBatchSize is the maximum number of items in a batch.
+	maxBatchSize = 100
+)
+
+// BatchRequest represents the payload of POST /prompt/batch.
+type BatchRequest struct {
+	Requests []Request `json:"requests"`
+}
+
+// BatchResult is the result of a single batch item: the response or error as
+// returned by POST /prompt, and the HTTP status that request would have had.
+type BatchResult struct {
+	Index  int `json:"index"`
+	Status int `json:"status"`
+	Response
+}
+
+// BatchResponse represents the response of POST /prompt/batch. Results are
+// in the order of the requests.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// HandleBatch handles POST /prompt/batch requests. Items are run with bounded
+// parallelism; a failing item does not fail the batch.
+func (h *Handler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		h.sendError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Requests) == 0 {
+		h.sendError(w, "The 'requests' field is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Requests) > maxBatchSize {
+		h.sendError(w, fmt.Sprintf("The 'requests' field exceeds the maximum of %d items", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[INFO] Running batch of %d prompts", len(req.Requests))
+
+	// Items run concurrently, so their write deadlines must only ever
+	// extend the response's deadline
+	dw := &deadlineWriter{ResponseWriter: w}
+	override := h.overrideFor(r)
+	results := make([]BatchResult, len(req.Requests))
+	slots := make(chan struct{}, h.batchConcurrency)
+	providerSlots := make(map[string]chan struct{})
+	var wg sync.WaitGroup
+	for i, item := range req.Requests {
+		g, err := h.promptGeneration(item, override)
+		if err != nil {
+			results[i] = BatchResult{Index: i, Status: http.StatusBadRequest, Response: Response{Error: err.Error()}}
+			continue
+		}
+
+		providerSlot, ok := providerSlots[g.providerName]
+		if !ok {
+			providerSlot = make(chan struct{}, h.batchProviderConcurrency(g.providerName))
+			providerSlots[g.providerName] = providerSlot
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			providerSlot <- struct{}{}
+			defer func() { <-providerSlot }()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			results[i] = h.runBatchItem(r, dw, i, g)
+		}()
+	}
+	wg.Wait()
+
+	if r.Context().Err() != nil {
+		log.Printf("[INFO] Client disconnected, batch cancelled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BatchResponse{Results: results})
+}
+
+// batchProviderConcurrency returns the number of items of a batch that may
+// run on the named provider at once. It is capped at the runs the provider's
+// queue can hold, so that a batch does not reject its own items as
+// queue_full.
+func (h *Handler) batchProviderConcurrency(providerName string) int {
+	limiter, ok := h.queues[providerName]
+	if !ok {
+		return h.batchConcurrency
+	}
+	stats := limiter.Stats()
+	return min(h.batchConcurrency, stats.MaxConcurrency+stats.MaxQueue)
+}
+
+// runBatchItem generates the response of a single batch item.
+func (h *Handler) runBatchItem(r *http.Request, w http.ResponseWriter, index int, g generation) BatchResult {
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("[ERROR] Batch item %d: %s CLI failed: %v", index, res.provider, res.err)
+		}
+		resp, status := res.failedResponse()
+		return BatchResult{Index: index, Status: status, Response: resp}
+	}
+
+	return BatchResult{
+		Index:    index,
+		Status:   http.StatusOK,
+		Response: res.response(),
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+)
+
+// echoGenerator implements provider.Generator by returning the user prompt.
+type echoGenerator struct {
+	delay   time.Duration
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (g *echoGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	n := g.running.Add(1)
+	defer g.running.Add(-1)
+	for {
+		peak := g.peak.Load()
+		if n <= peak || g.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(g.delay)
+	return userPrompt, nil
+}
+
+func postBatch(handler *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/prompt/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.HandleBatch(w, req)
+	return w
+}
+
+func TestHandleBatch(t *testing.T) {
+	gen := &echoGenerator{delay: 10 * time.Millisecond}
+	handler := New(map[string]provider.Generator{"claude": gen}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithBatchConcurrency(2))
+
+	w := postBatch(handler, `{"requests": [
+		{"user": "one"}, {"user": "two"}, {"user": "three"}, {"user": "four"}, {"user": "five"}
+	]}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp BatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	want := []string{"one", "two", "three", "four", "five"}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(resp.Results))
+	}
+	for i, result := range resp.Results {
+		if result.Index != i || result.Status != http.StatusOK || result.ResponseText != want[i] || result.Provider != "claude" {
+			t.Errorf("unexpected result %d: %+v", i, result)
+		}
+	}
+
+	if peak := gen.peak.Load(); peak > 2 {
+		t.Errorf("expected at most 2 concurrent runs, got %d", peak)
+	}
+}
+
+func TestHandleBatch_QueueCapacity(t *testing.T) {
+	gen := &echoGenerator{delay: 10 * time.Millisecond}
+	handler := New(map[string]provider.Generator{"claude": gen}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithBatchConcurrency(8), WithQueues(map[string]*queue.Limiter{"claude": queue.NewLimiter(1, 1)}))
+
+	w := postBatch(handler, `{"requests": [
+		{"user": "one"}, {"user": "two"}, {"user": "three"}, {"user": "four"}, {"user": "five"}
+	]}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp BatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	for i, result := range resp.Results {
+		if result.Status != http.StatusOK {
+			t.Errorf("unexpected result %d: %+v", i, result)
+		}
+	}
+}
+
+func TestHandleBatch_ItemErrors(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockGenerator{response: "Hello"},
+		"gemini": &mockGenerator{err: errRateLimited},
+	}, "claude")
+
+	w := postBatch(handler, `{"requests": [
+		{"user": "Hi"}, {"user": ""}, {"user": "Hi", "provider": "gemini"},
+		{"user": "Hi", "callback_url": "http://localhost:9000/hook"}
+	]}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp BatchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(resp.Results))
+	}
+	if got := resp.Results[0]; got.Status != http.StatusOK || got.ResponseText != "Hello" {
+		t.Errorf("unexpected first result: %+v", got)
+	}
+	if got := resp.Results[1]; got.Status != http.StatusBadRequest || got.Error != "The 'user' field is required" {
+		t.Errorf("unexpected second result: %+v", got)
+	}
+	if got := resp.Results[2]; got.Status != http.StatusTooManyRequests || got.Code != "rate_limited" {
+		t.Errorf("unexpected third result: %+v", got)
+	}
+	if got := resp.Results[3]; got.Status != http.StatusBadRequest || got.Error != "The 'callback_url' field is only supported by POST /jobs" {
+		t.Errorf("unexpected fourth result: %+v", got)
+	}
+}
+
+func TestHandleBatch_InvalidRequest(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	tooMany := `{"requests": [` + strings.Repeat(`{"user": "Hi"},`, maxBatchSize) + `{"user": "Hi"}]}`
+	for _, body := range []string{`{`, `{}`, `{"requests": []}`, tooMany} {
+		if w := postBatch(handler, body); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// CompareRequest represents the payload of POST /compare. Without providers,
+// the prompt is sent to all registered providers. Models optionally selects
+// the model per provider, TimeoutMS shortens the run timeout of each, and
+// SystemPrompt, Variables and System select, render and override the system
+// prompt of all of them. Requests with other fields are rejected.
+type CompareRequest struct {
+	User         string            `json:"user"`
+	Providers    []string          `json:"providers,omitempty"`
+	Models       map[string]string `json:"models,omitempty"`
+	TimeoutMS    int               `json:"timeout_ms,omitempty"`
+	SystemPrompt string            `json:"system_prompt,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	System       string            `json:"system,omitempty"`
+	Schema       json.RawMessage   `json:"schema,omitempty"`
+}
+
+// CompareResult is the answer of a single provider. LatencyMS is the time the
+// provider's CLI took, excluding the wait for a run slot. Usage is included
+// if the provider reports token counts.
+type CompareResult struct {
+	Provider     string          `json:"provider"`
+	Model        string          `json:"model,omitempty"`
+	Status       int             `json:"status"`
+	ResponseText string          `json:"response,omitempty"`
+	Output       json.RawMessage `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Code         string          `json:"code,omitempty"`
+	Retryable    *bool           `json:"retryable,omitempty"`
+	LatencyMS    int64           `json:"latency_ms"`
+	Usage        *provider.Usage `json:"usage,omitempty"`
+	// ValidationErrors lists why the response does not match the schema.
+	ValidationErrors []string `json:"validation_errors,omitempty"`
+}
+
+// CompareResponse represents the response of POST /compare. Results are in
+// the order of the requested providers.
+type CompareResponse struct {
+	Results []CompareResult `json:"results"`
+}
+
+// HandleCompare handles POST /compare requests. The prompt runs on all
+// providers concurrently, each bounded by its own timeout and without
+// fallback, so that a slow or failing provider does not affect the others.
+func (h *Handler) HandleCompare(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CompareRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		message := "Invalid JSON"
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			message = "Unknown field " + field
+		}
+		h.sendError(w, message, http.StatusBadRequest)
+		return
+	}
+
+	generations, err := h.compareGenerations(req, h.overrideFor(r))
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[INFO] Comparing %d providers", len(generations))
+
+	// Runs are concurrent, so their write deadlines must only ever extend
+	// the response's deadline
+	dw := &deadlineWriter{ResponseWriter: w}
+	results := make([]CompareResult, len(generations))
+	var wg sync.WaitGroup
+	for i, g := range generations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.runComparison(r, dw, g, req.Models[g.providerName])
+		}()
+	}
+	wg.Wait()
+
+	if r.Context().Err() != nil {
+		log.Printf("[INFO] Client disconnected, comparison cancelled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CompareResponse{Results: results})
+}
+
+// compareGenerations validates a comparison request and returns the
+// generation of each provider. override governs the system prompt sent with
+// the request.
+func (h *Handler) compareGenerations(req CompareRequest, override prompt.Override) ([]generation, error) {
+	if req.User == "" {
+		return nil, fmt.Errorf("The 'user' field is required")
+	}
+
+	names := req.Providers
+	if len(names) == 0 {
+		for name := range h.providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("The 'providers' field must not contain empty names")
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("Provider %s is listed more than once", name)
+		}
+		seen[name] = true
+	}
+	for name := range req.Models {
+		if !seen[name] {
+			return nil, fmt.Errorf("The 'models' field selects a model for %s, which is not compared", name)
+		}
+	}
+
+	generations := make([]generation, 0, len(names))
+	for _, name := range names {
+		g, err := h.promptGeneration(Request{
+			User:         req.User,
+			Provider:     name,
+			Model:        req.Models[name],
+			TimeoutMS:    req.TimeoutMS,
+			SystemPrompt: req.SystemPrompt,
+			Variables:    req.Variables,
+			System:       req.System,
+			Schema:       req.Schema,
+		}, override)
+		if err != nil {
+			return nil, err
+		}
+		g.noFallback = true
+		generations = append(generations, g)
+	}
+	return generations, nil
+}
+
+// runComparison runs the generation of a single provider and measures its
+// latency.
+func (h *Handler) runComparison(r *http.Request, w http.ResponseWriter, g generation, model string) CompareResult {
+	start := time.Now()
+	g.onStart = func(string) {
+		start = time.Now()
+	}
+
+	res := h.generate(r.Context(), w, g)
+	result := CompareResult{
+		Provider:  g.providerName,
+		Model:     model,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Usage:     res.usage,
+	}
+
+	if res.err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("[ERROR] Comparison: %s CLI failed: %v", g.providerName, res.err)
+		}
+		resp, status := errorResponse(res.err)
+		result.Status = status
+		result.Error = resp.Error
+		result.Code = resp.Code
+		result.Retryable = resp.Retryable
+		result.ValidationErrors = resp.ValidationErrors
+		return result
+	}
+
+	result.Status = http.StatusOK
+	result.ResponseText = res.text
+	result.Output = res.output
+	return result
+}
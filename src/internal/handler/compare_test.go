@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// usageGenerator implements provider.UsageGenerator with fixed token counts.
+type usageGenerator struct {
+	mockGenerator
+	usage provider.Usage
+}
+
+func (g *usageGenerator) GenerateWithUsage(ctx context.Context, systemPrompt, userPrompt string) (string, provider.Usage, error) {
+	return g.response, g.usage, g.err
+}
+
+func postCompare(handler *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/compare", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.HandleCompare(w, req)
+	return w
+}
+
+func TestHandleCompare(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &usageGenerator{mockGenerator: mockGenerator{response: "Hello from Claude"}, usage: provider.Usage{InputTokens: 10, OutputTokens: 3}},
+		"codex":  &mockGenerator{err: errRateLimited},
+		"gemini": &mockGenerator{response: "Hello from Gemini"},
+	}, "claude")
+
+	w := postCompare(handler, `{"user": "Hi"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp CompareResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %+v", resp.Results)
+	}
+
+	claude, codex, gemini := resp.Results[0], resp.Results[1], resp.Results[2]
+	if claude.Provider != "claude" || claude.Status != http.StatusOK || claude.ResponseText != "Hello from Claude" {
+		t.Errorf("unexpected claude result: %+v", claude)
+	}
+	if claude.Usage == nil || *claude.Usage != (provider.Usage{InputTokens: 10, OutputTokens: 3}) {
+		t.Errorf("expected claude usage, got %+v", claude.Usage)
+	}
+	if codex.Provider != "codex" || codex.Status != http.StatusTooManyRequests || codex.Code != string(provider.CodeRateLimited) || codex.ResponseText != "" {
+		t.Errorf("unexpected codex result: %+v", codex)
+	}
+	if gemini.Provider != "gemini" || gemini.ResponseText != "Hello from Gemini" || gemini.Usage != nil {
+		t.Errorf("unexpected gemini result: %+v", gemini)
+	}
+}
+
+func TestHandleCompare_Timeout(t *testing.T) {
+	slow := &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+	handler := New(map[string]provider.Generator{
+		"claude": slow,
+		"gemini": &mockGenerator{response: "Hello from Gemini"},
+	}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithFallbacks(map[string][]string{"claude": {"gemini"}}))
+
+	start := time.Now()
+	w := postCompare(handler, `{"user": "Hi", "providers": ["gemini", "claude"], "timeout_ms": 50}`)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the timeout to bound the comparison, took %v", elapsed)
+	}
+
+	var resp CompareResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", resp.Results)
+	}
+	if gemini := resp.Results[0]; gemini.Provider != "gemini" || gemini.ResponseText != "Hello from Gemini" {
+		t.Errorf("unexpected gemini result: %+v", gemini)
+	}
+	// The timed out provider does not fall back to another one
+	if claude := resp.Results[1]; claude.Provider != "claude" || claude.Status != http.StatusGatewayTimeout || claude.LatencyMS < 50 {
+		t.Errorf("unexpected claude result: %+v", claude)
+	}
+}
+
+func TestHandleCompare_InvalidRequest(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"providers": ["claude"]}`, "The 'user' field is required"},
+		{`{"user": "Hi", "providers": ["claude", "unknown"]}`, "Unknown provider: unknown"},
+		{`{"user": "Hi", "providers": ["claude", "claude"]}`, "Provider claude is listed more than once"},
+		{`{"user": "Hi", "providers": ["claude"], "models": {"gemini": "flash"}}`, "The 'models' field selects a model for gemini, which is not compared"},
+		{`{"user": "Hi", "callback_url": "http://localhost:9000/hook"}`, `Unknown field "callback_url"`},
+		{`{"user": "Hi", "provider": "claude"}`, `Unknown field "provider"`},
+		{`{`, "Invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		w := postCompare(handler, tt.body)
+		var resp Response
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp.Error != tt.want {
+			t.Errorf("expected 400 %q for %s, got %d %q", tt.want, tt.body, w.Code, resp.Error)
+		}
+	}
+}
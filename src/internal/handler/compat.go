@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// The compatibility endpoints translate the request formats of other AI APIs
+// onto the registered providers. Their model IDs name a provider, optionally
+// followed by one of its allowed models, e.g. "claude" or "claude/sonnet".
+// The Anthropic and Gemini endpoints also accept the model IDs of their
+// vendor, e.g. "claude-sonnet-4-5", see resolveVendorModelID.
+
+// resolveModelID resolves a compatibility API model ID. An empty ID selects
+// the default provider.
+func (h *Handler) resolveModelID(id string) (provider.Generator, string, error) {
+	name, model, _ := strings.Cut(id, "/")
+	return h.resolveProvider(name, model)
+}
+
+// resolveVendorModelID resolves the model ID of an API whose SDKs send the
+// vendor's own model IDs. IDs that name a provider are resolved as by
+// resolveModelID. Other IDs select the vendor's provider, or the default
+// provider if it is not registered: with the ID as its model if that is an
+// allowed model, and with its default model otherwise.
+func (h *Handler) resolveVendorModelID(id, vendor string) (provider.Generator, string, error) {
+	p, providerName, err := h.resolveModelID(id)
+	if !errors.Is(err, errUnknownProvider) || strings.Contains(id, "/") {
+		return p, providerName, err
+	}
+
+	name := vendor
+	if _, ok := h.providers[name]; !ok {
+		name = h.defaultProvider
+	}
+	if slices.Contains(h.models[name], id) {
+		return h.resolveProvider(name, id)
+	}
+	log.Printf("[INFO] Model %s is not an allowed model, using %s with its default model", id, name)
+	return h.resolveProvider(name, "")
+}
+
+// modelIDs lists the model IDs accepted by the compatibility endpoints: every
+// provider, and every allowed model prefixed with its provider.
+func (h *Handler) modelIDs() []string {
+	var ids []string
+	for name := range h.providers {
+		ids = append(ids, name)
+		for _, model := range h.models[name] {
+			ids = append(ids, name+"/"+model)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// answeredModel returns the model ID to report for a response of answeredBy:
+// the requested ID, or the provider that answered if a fallback did.
+func answeredModel(requested, providerName, answeredBy string) string {
+	if answeredBy != providerName || requested == "" {
+		return answeredBy
+	}
+	return requested
+}
+
+// errSystemNotAllowed is returned by withSystemPrompt if the override policy
+// forbids system instructions.
+var errSystemNotAllowed = errors.New("System instructions are not allowed")
+
+// withSystemPrompt returns the configured system prompt, rendered for the
+// named provider, combined with the system instructions sent by a client as
+// permitted by override. The compatibility APIs cannot send template
+// variables.
+func (h *Handler) withSystemPrompt(providerName string, instructions []string, override prompt.Override) (string, error) {
+	var parts []string
+	for _, instruction := range instructions {
+		if instruction = strings.TrimSpace(instruction); instruction != "" {
+			parts = append(parts, instruction)
+		}
+	}
+	if len(parts) == 0 {
+		return h.renderSystemPrompt("", providerName, nil)
+	}
+
+	switch override {
+	case prompt.OverrideAppend:
+		systemPrompt, err := h.renderSystemPrompt("", providerName, nil)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(append([]string{systemPrompt}, parts...), "\n\n"), nil
+	case prompt.OverrideReplace:
+		return strings.Join(parts, "\n\n"), nil
+	default:
+		return "", errSystemNotAllowed
+	}
+}
+
+// systemPromptStatus returns the HTTP status for an error of
+// withSystemPrompt: 403 if the override policy forbids system instructions,
+// 400 otherwise.
+func systemPromptStatus(err error) int {
+	if errors.Is(err, errSystemNotAllowed) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// conversationPrompt renders a conversation that ends with a user message
+// into a single user prompt.
+func conversationPrompt(turns []session.Message) (string, error) {
+	if len(turns) == 0 || turns[len(turns)-1].Role != session.RoleUser {
+		return "", errors.New("The last message must be from the user")
+	}
+	last := turns[len(turns)-1]
+	if strings.TrimSpace(last.Content) == "" {
+		return "", errors.New("The last message must not be empty")
+	}
+	return session.FormatTranscript(turns[:len(turns)-1], last.Content), nil
+}
+
+// compatHeaders are the request headers the SDKs of the compatible APIs send
+// besides Content-Type. The proxy accepts and ignores their credentials.
+const compatHeaders = "Content-Type, Authorization, X-Api-Key, Anthropic-Version, Anthropic-Beta, Anthropic-Dangerous-Direct-Browser-Access, X-Goog-Api-Key, X-Goog-Api-Client"
+
+// setCompatCORSHeaders sets the CORS headers for the compatibility endpoints.
+func (h *Handler) setCompatCORSHeaders(w http.ResponseWriter) {
+	h.setCORSHeaders(w)
+	w.Header().Set("Access-Control-Allow-Headers", compatHeaders)
+}
+
+// compatFailure logs a failed generation and returns its error response and
+// status, setting Retry-After if a queue was full. It returns false if the
+// client has gone away and nothing should be written.
+func (h *Handler) compatFailure(w http.ResponseWriter, r *http.Request, res chainResult) (Response, int, bool) {
+	if r.Context().Err() != nil {
+		log.Printf("[INFO] Client disconnected, %s CLI cancelled", res.provider)
+		return Response{}, 0, false
+	}
+	if errors.Is(res.err, queue.ErrQueueFull) {
+		h.setRetryAfter(w, res.provider)
+	} else {
+		log.Printf("[ERROR] %s CLI failed: %v", res.provider, res.err)
+	}
+	resp, status := res.failedResponse()
+	return resp, status, true
+}
+
+// compatStatus returns the HTTP status for a request that names an invalid
+// model: 404 for unknown providers, 400 otherwise.
+func compatStatus(err error) int {
+	if errors.Is(err, errUnknownProvider) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// newResponseID returns a random ID with the given prefix.
+func newResponseID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("%s%s", prefix, hex.EncodeToString(b))
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+)
+
+// codeQueueFull is the error code of requests rejected by a full queue.
+const codeQueueFull = "queue_full"
+
+// providerErrors maps provider error codes to HTTP statuses and client
+// messages.
+var providerErrors = map[provider.ErrorCode]struct {
+	status  int
+	message string
+}{
+	provider.CodeRateLimited:    {http.StatusTooManyRequests, "Provider rate limit reached"},
+	provider.CodeAuthRequired:   {http.StatusUnauthorized, "Provider authentication required"},
+	provider.CodeQuotaExhausted: {http.StatusServiceUnavailable, "Provider quota exhausted"},
+	provider.CodeTimeout:        {http.StatusGatewayTimeout, "Provider timed out"},
+	provider.CodeBinaryNotFound: {http.StatusServiceUnavailable, "Provider CLI is not installed"},
+	provider.CodeParseFailure:   {http.StatusBadGateway, "Failed to parse provider response"},
+	provider.CodeCLIFailure:     {http.StatusInternalServerError, "Failed to generate response"},
+}
+
+// errorResponse returns the error response and HTTP status for a failed
+// generation.
+func errorResponse(err error) (Response, int) {
+	if errors.Is(err, queue.ErrQueueFull) {
+		return Response{Error: "Too many requests", Code: codeQueueFull, Retryable: boolPtr(true)}, http.StatusTooManyRequests
+	}
+
+	var outputErr *OutputError
+	if errors.As(err, &outputErr) {
+		return Response{
+			Error:            "Response does not match the schema",
+			Code:             codeInvalidOutput,
+			Retryable:        boolPtr(true),
+			ValidationErrors: outputErr.Err.Errors,
+		}, http.StatusUnprocessableEntity
+	}
+
+	providerErr := provider.Classify(err)
+	mapped, ok := providerErrors[providerErr.Code]
+	if !ok {
+		mapped = providerErrors[provider.CodeCLIFailure]
+	}
+
+	return Response{
+		Error:     mapped.message,
+		Code:      string(providerErr.Code),
+		Retryable: boolPtr(providerErr.Retryable()),
+	}, mapped.status
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
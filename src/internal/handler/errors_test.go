@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+func TestHandlePrompt_ProviderErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		code      string
+		retryable bool
+	}{
+		{"rate limited", &provider.Error{Code: provider.CodeRateLimited, Err: provider.ErrCLIExecution}, http.StatusTooManyRequests, "rate_limited", true},
+		{"auth required", &provider.Error{Code: provider.CodeAuthRequired, Err: provider.ErrCLIExecution}, http.StatusUnauthorized, "auth_required", false},
+		{"quota exhausted", &provider.Error{Code: provider.CodeQuotaExhausted, Err: provider.ErrCLIExecution}, http.StatusServiceUnavailable, "quota_exhausted", false},
+		{"timeout", &provider.Error{Code: provider.CodeTimeout, Err: context.DeadlineExceeded}, http.StatusGatewayTimeout, "timeout", true},
+		{"binary not found", &provider.Error{Code: provider.CodeBinaryNotFound, Err: provider.ErrCLIExecution}, http.StatusServiceUnavailable, "binary_not_found", false},
+		{"parse failure", provider.ErrParsing, http.StatusBadGateway, "parse_failure", true},
+		{"unclassified", errors.New("CLI failed"), http.StatusInternalServerError, "cli_failure", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := newTestHandler(&mockGenerator{err: tc.err})
+
+			w := postPrompt(handler, Request{User: "Say hello"})
+
+			if w.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, w.Code)
+			}
+
+			var resp Response
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Code != tc.code {
+				t.Errorf("expected code %q, got %q", tc.code, resp.Code)
+			}
+			if resp.Retryable == nil || *resp.Retryable != tc.retryable {
+				t.Errorf("expected retryable %v, got %v", tc.retryable, resp.Retryable)
+			}
+		})
+	}
+}
+
+func TestHandleSessionMessages_ProviderErrorStatus(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{err: &provider.Error{Code: provider.CodeAuthRequired, Err: provider.ErrCLIExecution}})
+	sess := createSession(t, handler, `{}`)
+
+	w := postSessionMessage(handler, sess.ID, "Hi")
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != "auth_required" {
+		t.Errorf("expected code auth_required, got %q", resp.Code)
+	}
+}
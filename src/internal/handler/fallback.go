@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// defaultFallbackCodes are the error codes of failures that may succeed with
+// another provider. Further codes can be enabled with WithFallbackCodes.
+var defaultFallbackCodes = []string{
+	string(provider.CodeRateLimited),
+	string(provider.CodeQuotaExhausted),
+	string(provider.CodeTimeout),
+	codeQueueFull,
+}
+
+// ProviderFailure describes a provider that failed while walking a fallback
+// chain.
+type ProviderFailure struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+	Code     string `json:"code"`
+}
+
+// chainAttempt runs a prompt on one provider of a fallback chain.
+type chainAttempt func(p provider.Generator, providerName string) (string, error)
+
+// chainResult is the outcome of walking a fallback chain.
+type chainResult struct {
+	text string
+	// provider is the provider that answered, or the last one tried.
+	provider string
+	failed   []ProviderFailure
+	err      error
+	// usage is the token usage of the answer, if the provider reports it.
+	usage *provider.Usage
+	// output is the validated JSON answer of a generation with a schema,
+	// which then leaves text empty.
+	output json.RawMessage
+}
+
+// response returns the response of a successful chain.
+func (c chainResult) response() Response {
+	return Response{ResponseText: c.text, Output: c.output, Provider: c.provider, FailedProviders: c.failed}
+}
+
+// failedResponse returns the error response and HTTP status for a failed
+// chain. The failed providers are only listed if a fallback was tried.
+func (c chainResult) failedResponse() (Response, int) {
+	resp, status := errorResponse(c.err)
+	if len(c.failed) > 1 {
+		resp.FailedProviders = c.failed
+	}
+	return resp, status
+}
+
+// canFallBack reports whether a failure may succeed with another provider,
+// judged by its error code: one of defaultFallbackCodes or of the configured
+// fallback codes.
+func (h *Handler) canFallBack(err error) bool {
+	resp, _ := errorResponse(err)
+	return slices.Contains(defaultFallbackCodes, resp.Code) || slices.Contains(h.fallbackCodes, resp.Code)
+}
+
+// runChain runs attempt with p and, while it fails with an error accepted by
+// retryable, with each provider of the fallback chain configured for
+// providerName in turn. Fallback providers use their default model.
+func (h *Handler) runChain(ctx context.Context, p provider.Generator, providerName string, attempt chainAttempt, retryable func(error) bool) chainResult {
+	names := append([]string{providerName}, h.fallbacks[providerName]...)
+
+	var res chainResult
+	for i, name := range names {
+		if i > 0 {
+			next, _, err := h.resolveProvider(name, "")
+			if err != nil {
+				log.Printf("[ERROR] Skipping fallback for %s: %v", providerName, err)
+				continue
+			}
+			log.Printf("[WARN] %s failed, falling back to %s: %v", res.provider, name, res.err)
+			p = next
+		}
+
+		res.provider = name
+		res.text, res.err = attempt(p, name)
+		if res.err == nil || ctx.Err() != nil {
+			return res
+		}
+
+		resp, _ := errorResponse(res.err)
+		res.failed = append(res.failed, ProviderFailure{Provider: name, Error: resp.Error, Code: resp.Code})
+		if !retryable(res.err) {
+			return res
+		}
+	}
+	return res
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+)
+
+var errRateLimited = &provider.Error{Code: provider.CodeRateLimited, Err: provider.ErrCLIExecution}
+
+func newFallbackTestHandler(providers map[string]provider.Generator, opts ...Option) *Handler {
+	opts = append(opts, WithFallbacks(map[string][]string{"claude": {"gemini", "codex"}}))
+	return New(providers, "claude", "http://localhost:3000", "You are a test assistant.", opts...)
+}
+
+func postPrompt(handler *Handler, request Request) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handler.HandlePrompt(w, req)
+	return w
+}
+
+func TestHandlePrompt_Fallback(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockGenerator{err: errRateLimited},
+		"gemini": &mockGenerator{err: errRateLimited},
+		"codex":  &mockGenerator{response: "Hello from codex"},
+	})
+
+	w := postPrompt(handler, Request{User: "Say hello"})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.ResponseText != "Hello from codex" || resp.Provider != "codex" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(resp.FailedProviders) != 2 || resp.FailedProviders[0].Provider != "claude" || resp.FailedProviders[1].Provider != "gemini" {
+		t.Errorf("unexpected failed providers: %+v", resp.FailedProviders)
+	}
+}
+
+func TestHandlePrompt_FallbackSkipsNonRetryableErrors(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockGenerator{err: provider.ErrParsing},
+		"gemini": &mockGenerator{response: "Hello from gemini"},
+	})
+
+	w := postPrompt(handler, Request{User: "Say hello"})
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.FailedProviders) != 0 {
+		t.Errorf("expected no failed providers, got %+v", resp.FailedProviders)
+	}
+}
+
+func TestHandlePrompt_FallbackCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		codes    []string
+		provider string
+	}{
+		{"quota exhausted", &provider.Error{Code: provider.CodeQuotaExhausted, Err: provider.ErrCLIExecution}, nil, "gemini"},
+		{"timeout", &provider.Error{Code: provider.CodeTimeout, Err: provider.ErrCLIExecution}, nil, "gemini"},
+		{"auth required", &provider.Error{Code: provider.CodeAuthRequired, Err: provider.ErrCLIExecution}, nil, ""},
+		{"binary not found", &provider.Error{Code: provider.CodeBinaryNotFound, Err: provider.ErrCLIExecution}, nil, ""},
+		{"cli failure", provider.ErrCLIExecution, nil, ""},
+		{"configured code", &provider.Error{Code: provider.CodeAuthRequired, Err: provider.ErrCLIExecution}, []string{"auth_required"}, "gemini"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newFallbackTestHandler(map[string]provider.Generator{
+				"claude": &mockGenerator{err: tt.err},
+				"gemini": &mockGenerator{response: "Hello from gemini"},
+			}, WithFallbackCodes(tt.codes))
+
+			w := postPrompt(handler, Request{User: "Say hello"})
+
+			var resp Response
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Provider != tt.provider {
+				t.Errorf("expected provider %q to answer, got %+v", tt.provider, resp)
+			}
+		})
+	}
+}
+
+func TestHandlePrompt_FallbackSkipsInvalidOutput(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockGenerator{response: "I don't know."},
+		"gemini": &mockGenerator{response: `{"name":"Ada","age":36}`},
+	}, WithSchemaRetries(0))
+
+	w := postPrompt(handler, personRequest)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.FailedProviders) != 0 {
+		t.Errorf("expected no failed providers, got %+v", resp.FailedProviders)
+	}
+}
+
+func TestHandlePrompt_FallbackExhausted(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockGenerator{err: errRateLimited},
+		"gemini": &mockGenerator{err: errRateLimited},
+		"codex":  &mockGenerator{err: errRateLimited},
+	})
+
+	w := postPrompt(handler, Request{User: "Say hello"})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != "rate_limited" || len(resp.FailedProviders) != 3 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandlePrompt_FallbackOnFullQueue(t *testing.T) {
+	limiter := queue.NewLimiter(1, 0)
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockGenerator{response: "Hello from claude"},
+		"gemini": &mockGenerator{response: "Hello from gemini"},
+	}, WithQueues(map[string]*queue.Limiter{"claude": limiter}))
+	defer occupy(t, limiter)()
+
+	w := postPrompt(handler, Request{User: "Say hello"})
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Provider != "gemini" {
+		t.Fatalf("expected gemini to answer, got %+v", resp)
+	}
+	if len(resp.FailedProviders) != 1 || resp.FailedProviders[0].Error != "Too many requests" {
+		t.Errorf("unexpected failed providers: %+v", resp.FailedProviders)
+	}
+}
+
+func TestHandlePrompt_StreamFallback(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{err: errRateLimited},
+		"gemini": &mockStreamGenerator{deltas: []string{"Hello"}},
+	})
+
+	w := postPrompt(handler, Request{User: "Say hello", Stream: true})
+
+	events := parseSSE(t, w.Body.String())
+	last := events[len(events)-1]
+	want := `{"response":"Hello","provider":"gemini","failed_providers":[{"provider":"claude","error":"Provider rate limit reached","code":"rate_limited"}]}`
+	if last.name != "done" || last.data != want {
+		t.Errorf("unexpected final event: %s %q", last.name, last.data)
+	}
+}
+
+func TestHandlePrompt_StreamNoFallbackAfterDeltas(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hel"}, err: errRateLimited},
+		"gemini": &mockStreamGenerator{deltas: []string{"Hello"}},
+	})
+
+	w := postPrompt(handler, Request{User: "Say hello", Stream: true})
+
+	events := parseSSE(t, w.Body.String())
+	last := events[len(events)-1]
+	if last.name != "error" || last.data != `{"error":"Provider rate limit reached","code":"rate_limited","retryable":true}` {
+		t.Errorf("expected error event, got %s %q", last.name, last.data)
+	}
+}
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// geminiPathPrefix is the path prefix of the Gemini REST routes. ServeMux
+// patterns cannot match the "{model}:{method}" segment, so it is parsed by
+// HandleGemini.
+const geminiPathPrefix = "/v1beta/models/"
+
+// geminiRoleModel is the Gemini role of assistant turns.
+const geminiRoleModel = "model"
+
+// GenerateContentRequest is the subset of a Gemini generateContent request
+// supported by the proxy. The REST API accepts both spellings of the system
+// instruction field.
+type GenerateContentRequest struct {
+	Contents               []GeminiContent `json:"contents"`
+	SystemInstruction      *GeminiContent  `json:"systemInstruction,omitempty"`
+	SystemInstructionSnake *GeminiContent  `json:"system_instruction,omitempty"`
+}
+
+// GeminiContent is a turn of a Gemini conversation.
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a part of a Gemini content. Only text parts are supported.
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+// GenerateContentResponse is a Gemini generateContent response or stream
+// chunk.
+type GenerateContentResponse struct {
+	Candidates   []GeminiCandidate `json:"candidates"`
+	ModelVersion string            `json:"modelVersion"`
+}
+
+// GeminiCandidate is the single candidate of a Gemini response.
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// geminiError is the Google API error response format.
+type geminiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// text joins the text parts of c.
+func (c *GeminiContent) text() string {
+	if c == nil {
+		return ""
+	}
+	texts := make([]string, 0, len(c.Parts))
+	for _, part := range c.Parts {
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// HandleGemini handles POST /v1beta/models/{model}:generateContent and
+// :streamGenerateContent requests.
+func (h *Handler) HandleGemini(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		sendGeminiError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	model, method, ok := parseGeminiPath(r.URL.Path)
+	if !ok || (method != "generateContent" && method != "streamGenerateContent") {
+		sendGeminiError(w, fmt.Sprintf("Unknown method: %s", r.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	var req GenerateContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		sendGeminiError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	p, providerName, err := h.resolveVendorModelID(model, "gemini")
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendGeminiError(w, err.Error(), compatStatus(err))
+		return
+	}
+	if model == "" {
+		model = providerName
+	}
+
+	userPrompt, err := geminiPrompt(req.Contents)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendGeminiError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	systemInstruction := req.SystemInstruction
+	if systemInstruction == nil {
+		systemInstruction = req.SystemInstructionSnake
+	}
+
+	systemPrompt, err := h.withSystemPrompt(providerName, []string{systemInstruction.text()}, h.overrideFor(r))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendGeminiError(w, err.Error(), systemPromptStatus(err))
+		return
+	}
+
+	log.Printf("[INFO] Generating content using %s for prompt: %q", providerName, userPrompt)
+
+	g := generation{
+		provider:     p,
+		providerName: providerName,
+		systemPrompt: systemPrompt,
+		userPrompt:   userPrompt,
+	}
+	chunk := func(modelVersion, text, finishReason string) GenerateContentResponse {
+		return GenerateContentResponse{
+			Candidates: []GeminiCandidate{{
+				Content:      GeminiContent{Role: geminiRoleModel, Parts: []GeminiPart{{Text: text}}},
+				FinishReason: finishReason,
+			}},
+			ModelVersion: modelVersion,
+		}
+	}
+
+	if method == "streamGenerateContent" {
+		h.streamGemini(w, r, g, r.URL.Query().Get("alt") == "sse", func(answeredBy, text, finishReason string) any {
+			return chunk(answeredModel(model, providerName, answeredBy), text, finishReason)
+		})
+		return
+	}
+
+	g.onPosition = queuePositionHeader(w)
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		if resp, status, ok := h.compatFailure(w, r, res); ok {
+			sendGeminiError(w, resp.Error, status)
+		}
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated content using %s", res.provider)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chunk(answeredModel(model, providerName, res.provider), res.text, "STOP"))
+}
+
+// streamGemini streams a response as server-sent events if sse is set, and
+// otherwise as a JSON array written element by element, like the Gemini API.
+// The last chunk is empty and carries the finish reason. chunk builds a chunk
+// of the provider that answers.
+func (h *Handler) streamGemini(w http.ResponseWriter, r *http.Request, g generation, sse bool, chunk func(answeredBy, text, finishReason string) any) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendGeminiError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream is opened lazily so that a full queue can still be
+	// reported with a 429 status
+	var events *sseWriter
+	var elements int
+	started := false
+	openStream := func() {
+		if started {
+			return
+		}
+		started = true
+		if sse {
+			events, _ = newSSEWriter(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "[")
+		flusher.Flush()
+	}
+	send := func(payload any) {
+		if sse {
+			events.sendData(payload)
+			return
+		}
+		data, _ := json.Marshal(payload)
+		if elements > 0 {
+			fmt.Fprint(w, ",\n")
+		}
+		elements++
+		w.Write(data)
+		flusher.Flush()
+	}
+	end := func() {
+		if !sse {
+			fmt.Fprint(w, "]")
+			flusher.Flush()
+		}
+	}
+
+	if sse {
+		g.onPosition = func(position int) {
+			openStream()
+			events.write(": queue position %d\n\n", position)
+		}
+	} else {
+		g.onPosition = queuePositionHeader(w)
+	}
+	answeredBy := g.providerName
+	g.onStart = func(providerName string) {
+		answeredBy = providerName
+		openStream()
+	}
+	g.onDelta = func(delta string) {
+		send(chunk(answeredBy, delta, ""))
+	}
+
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		resp, status, ok := h.compatFailure(w, r, res)
+		if !ok {
+			return
+		}
+		if !started {
+			sendGeminiError(w, resp.Error, status)
+			return
+		}
+		send(newGeminiError(resp.Error, status))
+		end()
+		return
+	}
+
+	log.Printf("[INFO] Successfully streamed content using %s", res.provider)
+	send(chunk(res.provider, "", "STOP"))
+	end()
+}
+
+// parseGeminiPath splits a Gemini REST path into the model and method. The
+// model may itself contain a slash, as in "claude/sonnet".
+func parseGeminiPath(path string) (model, method string, ok bool) {
+	rest := strings.TrimPrefix(path, geminiPathPrefix)
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+// geminiPrompt replays Gemini contents into the user prompt.
+func geminiPrompt(contents []GeminiContent) (string, error) {
+	turns := make([]session.Message, 0, len(contents))
+	for _, c := range contents {
+		switch c.Role {
+		case "", session.RoleUser:
+			turns = append(turns, session.Message{Role: session.RoleUser, Content: c.text()})
+		case geminiRoleModel:
+			turns = append(turns, session.Message{Role: session.RoleAssistant, Content: c.text()})
+		default:
+			return "", fmt.Errorf("Unsupported content role: %s", c.Role)
+		}
+	}
+	return conversationPrompt(turns)
+}
+
+// newGeminiError builds a Google API error response. The status name is
+// derived from the HTTP status.
+func newGeminiError(message string, statusCode int) geminiError {
+	var resp geminiError
+	resp.Error.Code = statusCode
+	resp.Error.Message = message
+	switch statusCode {
+	case http.StatusUnauthorized:
+		resp.Error.Status = "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		resp.Error.Status = "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		resp.Error.Status = "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		resp.Error.Status = "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		resp.Error.Status = "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		resp.Error.Status = "DEADLINE_EXCEEDED"
+	default:
+		if statusCode < http.StatusInternalServerError {
+			resp.Error.Status = "INVALID_ARGUMENT"
+		} else {
+			resp.Error.Status = "INTERNAL"
+		}
+	}
+	return resp
+}
+
+// sendGeminiError sends an error response in the Google API format.
+func sendGeminiError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(newGeminiError(message, statusCode))
+}
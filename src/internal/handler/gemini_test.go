@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+func postGemini(handler *Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.HandleGemini(w, req)
+	return w
+}
+
+func TestHandleGemini_GenerateContent(t *testing.T) {
+	gen := &promptGenerator{}
+	handler := newOverrideTestHandler(gen, prompt.OverrideAppend)
+
+	w := postGemini(handler, "/v1beta/models/claude:generateContent", `{
+		"systemInstruction": {"parts": [{"text": "Answer briefly."}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "Hi"}]},
+			{"role": "model", "parts": [{"text": "Hello!"}]},
+			{"role": "user", "parts": [{"text": "How are you?"}]}
+		]
+	}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp GenerateContentResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Candidates) != 1 || resp.ModelVersion != "claude" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	candidate := resp.Candidates[0]
+	if candidate.Content.Role != "model" || candidate.Content.text() != "ok" || candidate.FinishReason != "STOP" {
+		t.Errorf("unexpected candidate: %+v", candidate)
+	}
+
+	if gen.systemPrompt != "You are a test assistant.\n\nAnswer briefly." {
+		t.Errorf("unexpected system prompt: %q", gen.systemPrompt)
+	}
+	if !strings.Contains(gen.userPrompt, "Assistant: Hello!") || !strings.HasSuffix(gen.userPrompt, "How are you?") {
+		t.Errorf("expected transcript in user prompt, got %q", gen.userPrompt)
+	}
+}
+
+func TestHandleGemini_ModelWithSlash(t *testing.T) {
+	handler := New(map[string]provider.Generator{"claude": &modelGenerator{}}, "claude",
+		"http://localhost:3000", "You are a test assistant.",
+		WithModels(map[string][]string{"claude": {"sonnet"}}))
+
+	w := postGemini(handler, "/v1beta/models/claude/sonnet:generateContent",
+		`{"contents": [{"parts": [{"text": "Hi"}]}]}`)
+
+	var resp GenerateContentResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.ModelVersion != "claude/sonnet" || resp.Candidates[0].Content.text() != "model=sonnet" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleGemini_GeminiModelIDs(t *testing.T) {
+	handler := New(map[string]provider.Generator{
+		"claude": &mockGenerator{response: "Hello from claude"},
+		"gemini": &modelGenerator{},
+	}, "claude", "http://localhost:3000", "You are a test assistant.", WithModels(map[string][]string{"gemini": {"gemini-2.5-pro"}}))
+
+	tests := []struct {
+		model string
+		text  string
+	}{
+		{"gemini-2.5-pro", "model=gemini-2.5-pro"},
+		{"gemini-2.5-flash", "model="},
+		{"claude", "Hello from claude"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			w := postGemini(handler, "/v1beta/models/"+tt.model+":generateContent", `{"contents": [{"parts": [{"text": "Hi"}]}]}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp GenerateContentResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.ModelVersion != tt.model || resp.Candidates[0].Content.text() != tt.text {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleGemini_StreamFallbackModelVersion(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{err: errRateLimited},
+		"gemini": &mockStreamGenerator{deltas: []string{"Hello"}},
+	})
+
+	w := postGemini(handler, "/v1beta/models/claude:streamGenerateContent",
+		`{"contents": [{"role": "user", "parts": [{"text": "Hi"}]}]}`)
+
+	var chunks []GenerateContentResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &chunks); err != nil {
+		t.Fatalf("expected a JSON array, got %q: %v", w.Body.String(), err)
+	}
+	for _, chunk := range chunks {
+		if chunk.ModelVersion != "gemini" {
+			t.Errorf("expected model version of the fallback, got %+v", chunk)
+		}
+	}
+}
+
+func TestHandleGemini_StreamSSE(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hello", ", world!"}},
+	}, "claude")
+
+	w := postGemini(handler, "/v1beta/models/claude:streamGenerateContent?alt=sse",
+		`{"contents": [{"role": "user", "parts": [{"text": "Hi"}]}]}`)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %q", len(events), w.Body.String())
+	}
+
+	var text strings.Builder
+	var finishReason string
+	for _, event := range events {
+		var chunk GenerateContentResponse
+		if err := json.Unmarshal([]byte(event.data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", event.data, err)
+		}
+		text.WriteString(chunk.Candidates[0].Content.text())
+		finishReason = chunk.Candidates[0].FinishReason
+	}
+	if text.String() != "Hello, world!" || finishReason != "STOP" {
+		t.Errorf("unexpected stream: %q", w.Body.String())
+	}
+}
+
+func TestHandleGemini_StreamArray(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hello", ", world!"}},
+	}, "claude")
+
+	w := postGemini(handler, "/v1beta/models/claude:streamGenerateContent",
+		`{"contents": [{"role": "user", "parts": [{"text": "Hi"}]}]}`)
+
+	var chunks []GenerateContentResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &chunks); err != nil {
+		t.Fatalf("expected a JSON array, got %q: %v", w.Body.String(), err)
+	}
+	if len(chunks) != 3 || chunks[0].Candidates[0].Content.text() != "Hello" || chunks[2].Candidates[0].FinishReason != "STOP" {
+		t.Errorf("unexpected chunks: %+v", chunks)
+	}
+}
+
+func TestHandleGemini_Errors(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{err: provider.ErrParsing})
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown method", "/v1beta/models/claude:countTokens", `{}`, http.StatusNotFound, "NOT_FOUND"},
+		{"missing method", "/v1beta/models/claude", `{}`, http.StatusNotFound, "NOT_FOUND"},
+		{"unknown provider", "/v1beta/models/mistral/large:generateContent", `{"contents": [{"parts": [{"text": "Hi"}]}]}`, http.StatusNotFound, "NOT_FOUND"},
+		{"invalid JSON", "/v1beta/models/claude:generateContent", `{`, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"unsupported role", "/v1beta/models/claude:generateContent", `{"contents": [{"role": "function", "parts": [{"text": "Hi"}]}]}`, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{"provider failure", "/v1beta/models/claude:generateContent", `{"contents": [{"parts": [{"text": "Hi"}]}]}`, http.StatusBadGateway, "INTERNAL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postGemini(handler, tt.path, tt.body)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			var resp geminiError
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error.Code != tt.status || resp.Error.Status != tt.code || resp.Error.Message == "" {
+				t.Errorf("unexpected error: %+v", resp.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/schema"
+)
+
+// generation describes a prompt to run through the queue, timeout and
+// fallback chain of its provider.
+type generation struct {
+	provider     provider.Generator
+	providerName string
+	systemPrompt string
+	userPrompt   string
+	// timeout shortens the provider's run timeout if set.
+	timeout time.Duration
+
+	// onPosition is called with the queue position while the request waits
+	// for a run slot.
+	onPosition func(position int)
+	// onStart is called with the name of the provider whenever a run slot
+	// has been acquired, before the CLI is started.
+	onStart func(providerName string)
+	// onDelta streams the response if set. Once a delta has been reported,
+	// failures no longer fall back, as that would mix two responses.
+	onDelta func(delta string)
+	// noFallback runs the prompt on its provider only.
+	noFallback bool
+	// schema is the JSON Schema the response must match, if any.
+	schema *schema.Schema
+}
+
+// promptGeneration validates a prompt request and returns its generation.
+// override governs the system prompt sent with the request. Errors are
+// caused by the request.
+func (h *Handler) promptGeneration(req Request, override prompt.Override) (generation, error) {
+	if req.User == "" {
+		return generation{}, errors.New("The 'user' field is required")
+	}
+	if req.CallbackURL != "" {
+		return generation{}, errCallbackURLNotSupported
+	}
+
+	p, providerName, err := h.resolveProvider(req.Provider, req.Model)
+	if err != nil {
+		return generation{}, err
+	}
+
+	timeout, err := h.requestTimeout(providerName, req.TimeoutMS)
+	if err != nil {
+		return generation{}, err
+	}
+
+	systemPrompt, err := h.requestSystemPrompt(req, providerName, override)
+	if err != nil {
+		return generation{}, err
+	}
+
+	var s *schema.Schema
+	if len(req.Schema) > 0 {
+		if s, err = schema.Compile(req.Schema); err != nil {
+			return generation{}, fmt.Errorf("Invalid 'schema' field: %v", err)
+		}
+	}
+
+	return generation{
+		provider:     p,
+		providerName: providerName,
+		systemPrompt: systemPrompt,
+		userPrompt:   req.User,
+		timeout:      timeout,
+		schema:       s,
+	}, nil
+}
+
+// generate runs g on its provider and, on retryable failures, on the
+// providers of its fallback chain. w may be nil for runs that are not bound
+// to a response, such as jobs. Generations with a schema are not streamed;
+// their validated output is reported as a single delta.
+func (h *Handler) generate(ctx context.Context, w http.ResponseWriter, g generation) chainResult {
+	var streamed bool
+	var usage *provider.Usage
+	res := h.runChain(ctx, g.provider, g.providerName, func(p provider.Generator, name string) (string, error) {
+		release, err := h.acquire(ctx, name, g.onPosition)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+
+		if g.onStart != nil {
+			g.onStart(name)
+		}
+
+		runCtx, cancel := withRunDeadline(ctx, w, h.runTimeout(name, g.timeout))
+		defer cancel()
+
+		// Usage is only reported for the provider that answered
+		usage = nil
+		if g.schema != nil {
+			output, err := h.generateStructured(runCtx, p, name, g)
+			if err == nil && g.onDelta != nil {
+				streamed = true
+				g.onDelta(output)
+			}
+			return output, err
+		}
+		if g.onDelta == nil {
+			if up, ok := p.(provider.UsageGenerator); ok {
+				text, u, err := up.GenerateWithUsage(runCtx, g.systemPrompt, g.userPrompt)
+				if err == nil {
+					usage = &u
+				}
+				return text, err
+			}
+			return p.Generate(runCtx, g.systemPrompt, g.userPrompt)
+		}
+		return generateStream(runCtx, p, g.systemPrompt, g.userPrompt, func(delta string) {
+			streamed = true
+			g.onDelta(delta)
+		})
+	}, func(err error) bool {
+		return !g.noFallback && !streamed && h.canFallBack(err)
+	})
+	res.usage = usage
+	if g.schema != nil && res.err == nil {
+		res.output = json.RawMessage(res.text)
+		res.text = ""
+	}
+	return res
+}
@@ -2,29 +2,73 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/health"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/job"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
 	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/webhook"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/websocket"
 )
 
 // Request represents the incoming request payload.
 type Request struct {
 	User     string `json:"user"`
 	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Stream   bool   `json:"stream,omitempty"`
+	// SystemPrompt names a prompt of the prompts directory to use instead
+	// of the default system prompt.
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// Variables are rendered into the system prompt template.
+	Variables map[string]string `json:"variables,omitempty"`
+	// System is appended to or replaces the configured system prompt, as
+	// permitted by the system override policy.
+	System string `json:"system,omitempty"`
+	// Schema is a JSON Schema the response must match. The validated
+	// response is returned as the output of the response.
+	Schema json.RawMessage `json:"schema,omitempty"`
+	// TimeoutMS shortens the provider's CLI run timeout for this request.
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// CallbackURL receives the finished job of POST /jobs requests. Other
+	// endpoints reject it.
+	CallbackURL string `json:"callback_url,omitempty"`
 }
 
-// Response represents the response payload.
+// Response represents the response payload. Provider is the provider that
+// answered, and FailedProviders lists the providers of its fallback chain
+// that failed before. Requests with a schema receive the validated JSON as
+// Output instead of ResponseText. Failed generations carry a machine-readable
+// error code and whether retrying the request may succeed.
 type Response struct {
-	ResponseText string `json:"response,omitempty"`
-	Error        string `json:"error,omitempty"`
+	ResponseText     string            `json:"response,omitempty"`
+	Output           json.RawMessage   `json:"output,omitempty"`
+	Provider         string            `json:"provider,omitempty"`
+	FailedProviders  []ProviderFailure `json:"failed_providers,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	Code             string            `json:"code,omitempty"`
+	Retryable        *bool             `json:"retryable,omitempty"`
+	ValidationErrors []string          `json:"validation_errors,omitempty"`
 }
 
-// ProviderInfo represents a provider with its metadata.
+// ProviderInfo represents a provider with its metadata. The installation
+// status is included once the provider has been probed, and the queue load
+// if its concurrency is limited.
 type ProviderInfo struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Queue       *queue.Stats `json:"queue,omitempty"`
+	*provider.Status
 }
 
 // providerDescriptions maps provider names to human-readable descriptions.
@@ -42,16 +86,152 @@ type Handler struct {
 	defaultProvider string
 	allowedOrigin   string
 	systemPrompt    string
+	prompts         *prompt.Library
+	systemOverride  prompt.OverridePolicy
+	sessions        *session.Store
+	jobs            *job.Store
+	webhooks        *webhook.Sender
+	models          map[string][]string
+	health          *health.Monitor
+	queues          map[string]*queue.Limiter
+	fallbacks       map[string][]string
+	fallbackCodes   []string
+	timeouts        map[string]time.Duration
+	// batchConcurrency bounds the concurrently running items of a batch.
+	batchConcurrency int
+	// schemaRetries bounds the repair attempts of responses that do not
+	// match the request's schema.
+	schemaRetries int
+
+	// sockets are the open WebSockets, closed on shutdown.
+	socketsMu sync.Mutex
+	sockets   map[*websocket.Conn]struct{}
+}
+
+// Option configures optional Handler behavior.
+type Option func(*Handler)
+
+// WithModels sets the models clients may select per provider. Requests for
+// other models, or for providers without an entry, are rejected.
+func WithModels(models map[string][]string) Option {
+	return func(h *Handler) {
+		h.models = models
+	}
+}
+
+// WithPrompts sets the named system prompts requests may select. A system
+// prompt of the library replaces the one passed to New, so that reloads take
+// effect. Without it, requests selecting a system prompt are rejected.
+func WithPrompts(prompts *prompt.Library) Option {
+	return func(h *Handler) {
+		h.prompts = prompts
+	}
+}
+
+// WithSystemOverride sets the policy for system prompts sent with requests.
+// Without it, requests sending a system prompt are rejected.
+func WithSystemOverride(policy prompt.OverridePolicy) Option {
+	return func(h *Handler) {
+		h.systemOverride = policy
+	}
+}
+
+// WithHealth sets the monitor whose provider status is reported by
+// GET /providers.
+func WithHealth(monitor *health.Monitor) Option {
+	return func(h *Handler) {
+		h.health = monitor
+	}
+}
+
+// WithQueues sets the limiters that bound concurrent runs per provider.
+// Providers without a limiter run without limit.
+func WithQueues(queues map[string]*queue.Limiter) Option {
+	return func(h *Handler) {
+		h.queues = queues
+	}
+}
+
+// WithFallbacks sets the providers tried in order when a provider fails with
+// a retryable error.
+func WithFallbacks(fallbacks map[string][]string) Option {
+	return func(h *Handler) {
+		h.fallbacks = fallbacks
+	}
+}
+
+// WithFallbackCodes sets the error codes of failures that fall back to the
+// next provider of a chain in addition to rate limits, exhausted quotas,
+// timeouts and full queues, e.g. "auth_required".
+func WithFallbackCodes(codes []string) Option {
+	return func(h *Handler) {
+		h.fallbackCodes = codes
+	}
+}
+
+// WithTimeouts sets the CLI run timeout per provider. Runs of providers
+// without a timeout are only bounded by the client.
+func WithTimeouts(timeouts map[string]time.Duration) Option {
+	return func(h *Handler) {
+		h.timeouts = timeouts
+	}
+}
+
+// WithSessionTTL sets how long sessions are kept without a new turn.
+func WithSessionTTL(ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.sessions = session.NewStore(ttl)
+	}
+}
+
+// WithJobRetention sets how long finished jobs are kept for polling.
+func WithJobRetention(retention time.Duration) Option {
+	return func(h *Handler) {
+		h.jobs = job.NewStore(retention)
+	}
+}
+
+// WithWebhooks sets the sender that delivers finished jobs to their callback
+// URL. Without it, requests with a callback URL are rejected.
+func WithWebhooks(sender *webhook.Sender) Option {
+	return func(h *Handler) {
+		h.webhooks = sender
+	}
+}
+
+// WithBatchConcurrency sets the number of items of a batch that run
+// concurrently.
+func WithBatchConcurrency(n int) Option {
+	return func(h *Handler) {
+		h.batchConcurrency = n
+	}
+}
+
+// WithSchemaRetries sets the number of times a response that does not match
+// the request's schema is sent back to the provider for repair.
+func WithSchemaRetries(n int) Option {
+	return func(h *Handler) {
+		h.schemaRetries = n
+	}
 }
 
 // New creates a new Handler with the given dependencies.
-func New(providers map[string]provider.Generator, defaultProvider, allowedOrigin, systemPrompt string) *Handler {
-	return &Handler{
-		providers:       providers,
-		defaultProvider: defaultProvider,
-		allowedOrigin:   allowedOrigin,
-		systemPrompt:    systemPrompt,
+func New(providers map[string]provider.Generator, defaultProvider, allowedOrigin, systemPrompt string, opts ...Option) *Handler {
+	h := &Handler{
+		providers:        providers,
+		defaultProvider:  defaultProvider,
+		allowedOrigin:    allowedOrigin,
+		systemPrompt:     systemPrompt,
+		sessions:         session.NewStore(session.DefaultIdleTTL),
+		jobs:             job.NewStore(job.DefaultRetention),
+		batchConcurrency: defaultBatchConcurrency,
+		schemaRetries:    defaultSchemaRetries,
+		sockets:          make(map[*websocket.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // HandlePrompt handles POST /prompt requests.
@@ -75,51 +255,94 @@ func (h *Handler) HandlePrompt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.User == "" {
-		log.Printf("[ERROR] Missing required field: user=%q", req.User)
-		h.sendError(w, "The 'user' field is required", http.StatusBadRequest)
+	g, err := h.promptGeneration(req, h.overrideFor(r))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		h.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Determine which provider to use
-	providerName := req.Provider
-	if providerName == "" {
-		providerName = h.defaultProvider
+	log.Printf("[INFO] Generating response using %s for prompt: %q", g.providerName, req.User)
+
+	if wantsStream(r, req) {
+		h.streamPrompt(w, r, g)
+		return
 	}
 
-	p, ok := h.providers[providerName]
-	if !ok {
-		log.Printf("[ERROR] Unknown provider: %s", providerName)
-		h.sendError(w, fmt.Sprintf("Unknown provider: %s", providerName), http.StatusBadRequest)
+	g.onPosition = queuePositionHeader(w)
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("[INFO] Client disconnected, %s CLI cancelled", res.provider)
+			return
+		}
+		if errors.Is(res.err, queue.ErrQueueFull) {
+			h.sendQueueError(w, r, res.provider, res.err)
+			return
+		}
+		log.Printf("[ERROR] %s CLI failed: %v", res.provider, res.err)
+		resp, status := res.failedResponse()
+		h.sendErrorResponse(w, resp, status)
 		return
 	}
 
-	log.Printf("[INFO] Generating response using %s for prompt: %q", providerName, req.User)
+	log.Printf("[INFO] Successfully generated response using %s", res.provider)
+	h.sendJSON(w, res.response())
+}
 
-	result, err := p.Generate(h.systemPrompt, req.User)
-	if err != nil {
-		log.Printf("[ERROR] %s CLI failed: %v", providerName, err)
-		h.sendError(w, "Failed to generate response", http.StatusInternalServerError)
-		return
+// errUnknownProvider is returned by resolveProvider for unknown providers.
+var errUnknownProvider = errors.New("Unknown provider")
+
+// resolveProvider looks up the named provider, or the default provider if
+// name is empty, and applies the requested model. It returns the generator
+// and the resolved provider name.
+func (h *Handler) resolveProvider(name, model string) (provider.Generator, string, error) {
+	if name == "" {
+		name = h.defaultProvider
+	}
+
+	p, ok := h.providers[name]
+	if !ok {
+		return nil, name, fmt.Errorf("%w: %s", errUnknownProvider, name)
+	}
+
+	if model == "" {
+		return p, name, nil
+	}
+
+	selector, ok := p.(provider.ModelSelector)
+	if !ok {
+		return nil, name, fmt.Errorf("Provider %s does not support model selection", name)
+	}
+	if !slices.Contains(h.models[name], model) {
+		return nil, name, fmt.Errorf("Model %s is not allowed for provider %s", model, name)
 	}
 
-	log.Printf("[INFO] Successfully generated response")
-	h.sendJSON(w, Response{ResponseText: result})
+	return selector.WithModel(model), name, nil
 }
 
+// corsHeaders are the request headers browsers may send. The API key
+// headers select the system override policy.
+const corsHeaders = "Content-Type, Authorization, X-Api-Key"
+
 // setCORSHeaders sets the required CORS and Private Network Access headers.
 func (h *Handler) setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", h.allowedOrigin)
 	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
 	w.Header().Set("Access-Control-Allow-Private-Network", "true")
 }
 
 // sendError sends an error response as JSON.
 func (h *Handler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	h.sendErrorResponse(w, Response{Error: message}, statusCode)
+}
+
+// sendErrorResponse sends an error response with additional details as JSON.
+func (h *Handler) sendErrorResponse(w http.ResponseWriter, response Response, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(Response{Error: message})
+	json.NewEncoder(w).Encode(response)
 }
 
 // sendJSON sends a successful JSON response.
@@ -144,6 +367,18 @@ func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// providerDescription returns the human-readable description of the named
+// provider, falling back to its name.
+func providerDescription(name string, p provider.Generator) string {
+	if d, ok := p.(provider.Describer); ok && d.Description() != "" {
+		return d.Description()
+	}
+	if description, ok := providerDescriptions[name]; ok {
+		return description
+	}
+	return name
+}
+
 // HandleProviders handles GET /providers requests.
 func (h *Handler) HandleProviders(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
@@ -159,17 +394,65 @@ func (h *Handler) HandleProviders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	providers := make([]ProviderInfo, 0, len(h.providers))
+	for name, p := range h.providers {
+		info := ProviderInfo{
+			Name:        name,
+			Description: providerDescription(name, p),
+		}
+		if h.health != nil {
+			if status, ok := h.health.Status(name); ok {
+				info.Status = &status
+			}
+		}
+		if limiter, ok := h.queues[name]; ok {
+			stats := limiter.Stats()
+			info.Queue = &stats
+		}
+		providers = append(providers, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]ProviderInfo{"providers": providers})
+}
+
+// ProviderModels lists the models clients may select for a provider.
+type ProviderModels struct {
+	Provider string   `json:"provider"`
+	Models   []string `json:"models"`
+}
+
+// HandleModels handles GET /models requests.
+func (h *Handler) HandleModels(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names := make([]string, 0, len(h.providers))
 	for name := range h.providers {
-		description := providerDescriptions[name]
-		if description == "" {
-			description = name
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	models := make([]ProviderModels, 0, len(names))
+	for _, name := range names {
+		allowed := h.models[name]
+		if allowed == nil {
+			allowed = []string{}
 		}
-		providers = append(providers, ProviderInfo{
-			Name:        name,
-			Description: description,
+		models = append(models, ProviderModels{
+			Provider: name,
+			Models:   allowed,
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string][]ProviderInfo{"providers": providers})
+	json.NewEncoder(w).Encode(map[string][]ProviderModels{"models": models})
 }
@@ -2,12 +2,15 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/health"
 	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
 )
 
@@ -17,10 +20,19 @@ type mockGenerator struct {
 	err      error
 }
 
-func (m *mockGenerator) Generate(systemPrompt, userPrompt string) (string, error) {
+func (m *mockGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
 	return m.response, m.err
 }
 
+// ctxGenerator implements provider.Generator with a custom function.
+type ctxGenerator struct {
+	fn func(ctx context.Context) (string, error)
+}
+
+func (g *ctxGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return g.fn(ctx)
+}
+
 func newTestHandler(mock *mockGenerator) *Handler {
 	providers := map[string]provider.Generator{
 		"claude": mock,
@@ -47,7 +59,7 @@ func TestHandlePrompt_CORSHeaders(t *testing.T) {
 	expectedHeaders := map[string]string{
 		"Access-Control-Allow-Origin":          "http://localhost:3000",
 		"Access-Control-Allow-Methods":         "POST, GET, OPTIONS",
-		"Access-Control-Allow-Headers":         "Content-Type",
+		"Access-Control-Allow-Headers":         "Content-Type, Authorization, X-Api-Key",
 		"Access-Control-Allow-Private-Network": "true",
 	}
 
@@ -158,6 +170,31 @@ func TestHandlePrompt_ProviderError(t *testing.T) {
 	}
 }
 
+func TestHandlePrompt_PassesRequestContext(t *testing.T) {
+	var gotCtx context.Context
+	mock := &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+		gotCtx = ctx
+		return "", ctx.Err()
+	}}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": mock}, "claude")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	body, _ := json.Marshal(Request{User: "Say hello"})
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	handler.HandlePrompt(w, req)
+
+	if gotCtx == nil || gotCtx.Err() == nil {
+		t.Fatal("expected the cancelled request context to be passed to the provider")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected no response body after client disconnect, got %q", w.Body.String())
+	}
+}
+
 func TestHandlePrompt_MethodNotAllowed(t *testing.T) {
 	handler := newTestHandler(&mockGenerator{})
 
@@ -235,6 +272,22 @@ func TestHandlePrompt_UnknownProvider(t *testing.T) {
 	}
 }
 
+func TestHandlePrompt_CallbackURL(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	w := postPrompt(handler, Request{User: "Hello", CallbackURL: "http://localhost:9000/hook"})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "The 'callback_url' field is only supported by POST /jobs" {
+		t.Errorf("unexpected error: %q", resp.Error)
+	}
+}
+
 func TestHandleProviders_Success(t *testing.T) {
 	providers := map[string]provider.Generator{
 		"claude": &mockGenerator{},
@@ -277,6 +330,98 @@ func TestHandleProviders_Success(t *testing.T) {
 	}
 }
 
+// describedGenerator implements provider.Describer.
+type describedGenerator struct {
+	mockGenerator
+	description string
+}
+
+func (d *describedGenerator) Description() string {
+	return d.description
+}
+
+func TestHandleProviders_Describer(t *testing.T) {
+	providers := map[string]provider.Generator{
+		"aider": &describedGenerator{description: "Aider"},
+		"bare":  &mockGenerator{},
+	}
+	handler := newTestHandlerWithProviders(providers, "aider")
+
+	req := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleProviders(w, req)
+
+	var resp struct {
+		Providers []ProviderInfo `json:"providers"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	descriptions := make(map[string]string)
+	for _, p := range resp.Providers {
+		descriptions[p.Name] = p.Description
+	}
+	if descriptions["aider"] != "Aider" {
+		t.Errorf("expected description from provider, got %q", descriptions["aider"])
+	}
+	if descriptions["bare"] != "bare" {
+		t.Errorf("expected name as fallback description, got %q", descriptions["bare"])
+	}
+}
+
+// probedGenerator implements provider.Prober.
+type probedGenerator struct {
+	mockGenerator
+	status provider.Status
+}
+
+func (p *probedGenerator) Probe(ctx context.Context) provider.Status {
+	return p.status
+}
+
+func TestHandleProviders_Status(t *testing.T) {
+	checked := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	authenticated := true
+	providers := map[string]provider.Generator{
+		"claude": &probedGenerator{status: provider.Status{
+			Binary:        "claude",
+			Installed:     true,
+			Version:       "2.0.1 (Claude Code)",
+			Authenticated: &authenticated,
+			LastChecked:   checked,
+		}},
+		"plain": &mockGenerator{},
+	}
+	monitor := health.NewMonitor(providers)
+	monitor.Check(context.Background())
+	handler := New(providers, "claude", "http://localhost:3000", "You are a test assistant.", WithHealth(monitor))
+
+	req := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleProviders(w, req)
+
+	var resp struct {
+		Providers []map[string]any `json:"providers"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	byName := make(map[string]map[string]any)
+	for _, p := range resp.Providers {
+		byName[p["name"].(string)] = p
+	}
+
+	claude := byName["claude"]
+	if claude["installed"] != true || claude["version"] != "2.0.1 (Claude Code)" ||
+		claude["authenticated"] != true || claude["last_checked"] != "2025-01-01T12:00:00Z" {
+		t.Errorf("unexpected claude status: %v", claude)
+	}
+
+	if _, ok := byName["plain"]["installed"]; ok {
+		t.Errorf("expected no status for an unprobed provider, got %v", byName["plain"])
+	}
+}
+
 func TestHandleProviders_MethodNotAllowed(t *testing.T) {
 	handler := newTestHandler(&mockGenerator{})
 
@@ -289,3 +434,105 @@ func TestHandleProviders_MethodNotAllowed(t *testing.T) {
 		t.Errorf("expected status 405, got %d", w.Code)
 	}
 }
+
+// modelGenerator implements provider.ModelSelector and reports the selected model.
+type modelGenerator struct {
+	model string
+}
+
+func (m *modelGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return "model=" + m.model, nil
+}
+
+func (m *modelGenerator) WithModel(model string) provider.Generator {
+	return &modelGenerator{model: model}
+}
+
+func TestHandlePrompt_ModelSelection(t *testing.T) {
+	providers := map[string]provider.Generator{
+		"claude": &modelGenerator{},
+		"gemini": &mockGenerator{response: "gemini response"},
+	}
+	handler := New(providers, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithModels(map[string][]string{"claude": {"sonnet", "opus"}}))
+
+	tests := []struct {
+		name           string
+		request        Request
+		expectedStatus int
+		expected       string
+	}{
+		{"default model", Request{User: "Hi"}, http.StatusOK, "model="},
+		{"allowed model", Request{User: "Hi", Model: "opus"}, http.StatusOK, "model=opus"},
+		{"disallowed model", Request{User: "Hi", Model: "haiku"}, http.StatusBadRequest, "Model haiku is not allowed for provider claude"},
+		{"unsupported provider", Request{User: "Hi", Provider: "gemini", Model: "x"}, http.StatusBadRequest, "Provider gemini does not support model selection"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(tc.request)
+			req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandlePrompt(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			var resp Response
+			json.NewDecoder(w.Body).Decode(&resp)
+			if got := resp.ResponseText + resp.Error; got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleModels_Success(t *testing.T) {
+	providers := map[string]provider.Generator{
+		"gemini": &modelGenerator{},
+		"claude": &modelGenerator{},
+	}
+	handler := New(providers, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithModels(map[string][]string{"claude": {"sonnet", "opus"}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/models", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleModels(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Models []ProviderModels `json:"models"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Models) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(resp.Models))
+	}
+	if resp.Models[0].Provider != "claude" || len(resp.Models[0].Models) != 2 {
+		t.Errorf("unexpected claude entry: %+v", resp.Models[0])
+	}
+	if resp.Models[1].Provider != "gemini" || resp.Models[1].Models == nil || len(resp.Models[1].Models) != 0 {
+		t.Errorf("expected gemini with an empty model list, got %+v", resp.Models[1])
+	}
+}
+
+func TestHandleModels_MethodNotAllowed(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	req := httptest.NewRequest(http.MethodPost, "/models", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleModels(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/job"
+)
+
+// HandleJobs handles POST /jobs requests. The prompt is generated in the
+// background; the response is the queued job, to be polled at /jobs/{id}.
+func (h *Handler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		h.sendError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// The callback URL is the only field promptGeneration rejects, as other
+	// endpoints cannot deliver webhooks
+	callbackURL := req.CallbackURL
+	req.CallbackURL = ""
+	g, err := h.promptGeneration(req, h.overrideFor(r))
+	if err == nil {
+		err = h.validateCallbackURL(callbackURL)
+	}
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Jobs outlive the request that created them
+	ctx, cancel := context.WithCancel(context.Background())
+	j := h.jobs.Create(g.providerName, req.Model, cancel)
+	log.Printf("[INFO] Created job %s using %s for prompt: %q", j.ID, g.providerName, req.User)
+
+	go h.runJob(ctx, cancel, j.ID, g, callbackURL)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(j)
+}
+
+// runJob generates the response of a job and records its result. Finished
+// jobs are delivered to callbackURL if set; cancelled jobs are not.
+func (h *Handler) runJob(ctx context.Context, cancel context.CancelFunc, id string, g generation, callbackURL string) {
+	defer cancel()
+	if callbackURL != "" {
+		defer h.deliverJob(ctx, id, callbackURL)
+	}
+
+	g.onPosition = func(position int) {
+		h.jobs.SetQueuePosition(id, position)
+	}
+	g.onStart = func(string) {
+		h.jobs.Start(id)
+	}
+
+	res := h.generate(ctx, nil, g)
+	if res.err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			log.Printf("[INFO] Job %s cancelled, %s CLI stopped", id, res.provider)
+			return
+		}
+		log.Printf("[ERROR] Job %s failed, %s CLI failed: %v", id, res.provider, res.err)
+		resp, _ := res.failedResponse()
+		h.jobs.Finish(id, job.StatusFailed, resp)
+		return
+	}
+
+	log.Printf("[INFO] Job %s succeeded using %s", id, res.provider)
+	h.jobs.Finish(id, job.StatusSucceeded, res.response())
+}
+
+// errCallbackURLNotSupported is returned by promptGeneration for requests
+// with a callback URL, which only POST /jobs supports.
+var errCallbackURLNotSupported = errors.New("The 'callback_url' field is only supported by POST /jobs")
+
+// validateCallbackURL checks the callback URL of a job request, which may be
+// empty.
+func (h *Handler) validateCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if h.webhooks == nil {
+		return errors.New("The 'callback_url' field requires a webhook secret to be configured")
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("The 'callback_url' field must be an http or https URL")
+	}
+	return nil
+}
+
+// deliverJob posts a finished job to its callback URL.
+func (h *Handler) deliverJob(ctx context.Context, id, callbackURL string) {
+	// A job cancelled while it ran is not delivered
+	if ctx.Err() != nil {
+		return
+	}
+	j, ok := h.jobs.Get(id)
+	if !ok || !j.Finished() {
+		return
+	}
+
+	body, err := json.Marshal(j)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode job %s: %v", id, err)
+		return
+	}
+
+	// Delivery is retried for a while, independent of the finished job
+	if err := h.webhooks.Deliver(context.Background(), callbackURL, body); err != nil {
+		log.Printf("[ERROR] Failed to deliver job %s to %s: %v", id, callbackURL, err)
+		return
+	}
+	log.Printf("[INFO] Delivered job %s to %s", id, callbackURL)
+}
+
+// HandleJob handles GET and DELETE /jobs/{id} requests. Deleting a queued or
+// running job cancels it; deleting a finished job removes it.
+func (h *Handler) HandleJob(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, DELETE, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		j, ok := h.jobs.Get(id)
+		if !ok {
+			h.sendError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(j)
+
+	case http.MethodDelete:
+		j, ok := h.jobs.Get(id)
+		if !ok {
+			h.sendError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		if j.Finished() {
+			h.jobs.Delete(id)
+			log.Printf("[INFO] Deleted job %s", id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		j, err := h.jobs.Cancel(id)
+		if err != nil {
+			h.sendError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("[INFO] Cancelled job %s", id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(j)
+
+	default:
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// CancelJobs cancels all queued and running jobs, stopping their CLIs.
+func (h *Handler) CancelJobs() {
+	h.jobs.CancelAll()
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/job"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/webhook"
+)
+
+// createJob creates a job through the handler and returns it.
+func createJob(t *testing.T, handler *Handler, request Request) job.Job {
+	t.Helper()
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handler.HandleJobs(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var j job.Job
+	json.NewDecoder(w.Body).Decode(&j)
+	if loc := w.Header().Get("Location"); loc != "/jobs/"+j.ID {
+		t.Errorf("unexpected Location %q", loc)
+	}
+	return j
+}
+
+// getJob fetches a job through the handler.
+func getJob(handler *Handler, id string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.HandleJob(w, req)
+
+	var j map[string]any
+	json.NewDecoder(w.Body).Decode(&j)
+	return w, j
+}
+
+// waitForJob polls a job until it has the given status.
+func waitForJob(t *testing.T, handler *Handler, id string, status job.Status) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, j := getJob(handler, id)
+		if j["status"] == string(status) {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not reach status %s: %v", status, j)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleJobs(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	j := createJob(t, handler, Request{User: "Say hello"})
+	if j.Status != job.StatusQueued || j.Provider != "claude" {
+		t.Errorf("unexpected job: %+v", j)
+	}
+
+	got := waitForJob(t, handler, j.ID, job.StatusSucceeded)
+	result, _ := got["result"].(map[string]any)
+	if result["response"] != "Hello" || result["provider"] != "claude" {
+		t.Errorf("unexpected result: %v", got["result"])
+	}
+	if got["started_at"] == nil || got["finished_at"] == nil {
+		t.Errorf("expected timing, got %v", got)
+	}
+}
+
+func TestHandleJobs_Failure(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{err: errRateLimited})
+
+	j := createJob(t, handler, Request{User: "Say hello"})
+
+	got := waitForJob(t, handler, j.ID, job.StatusFailed)
+	result, _ := got["result"].(map[string]any)
+	if result["code"] != "rate_limited" || result["retryable"] != true {
+		t.Errorf("unexpected result: %v", got["result"])
+	}
+}
+
+func TestHandleJobs_InvalidRequest(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	for _, body := range []string{`{`, `{"user": ""}`, `{"user": "Hi", "provider": "invalid"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.HandleJobs(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestHandleJob_Cancel(t *testing.T) {
+	stopped := make(chan struct{})
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			close(stopped)
+			return "", ctx.Err()
+		}},
+	}, "claude")
+
+	j := createJob(t, handler, Request{User: "Take your time"})
+	waitForJob(t, handler, j.ID, job.StatusRunning)
+
+	req := httptest.NewRequest(http.MethodDelete, "/jobs/"+j.ID, nil)
+	req.SetPathValue("id", j.ID)
+	w := httptest.NewRecorder()
+	handler.HandleJob(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the CLI run to be cancelled")
+	}
+
+	got := waitForJob(t, handler, j.ID, job.StatusCancelled)
+	if got["result"] != nil {
+		t.Errorf("expected no result, got %v", got["result"])
+	}
+}
+
+func TestHandleJob_DeleteFinished(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	j := createJob(t, handler, Request{User: "Say hello"})
+	waitForJob(t, handler, j.ID, job.StatusSucceeded)
+
+	req := httptest.NewRequest(http.MethodDelete, "/jobs/"+j.ID, nil)
+	req.SetPathValue("id", j.ID)
+	w := httptest.NewRecorder()
+	handler.HandleJob(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if w, _ := getJob(handler, j.ID); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestHandleJob_NotFound(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	if w, _ := getJob(handler, "missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestHandleJobs_Webhook(t *testing.T) {
+	type delivery struct {
+		body   []byte
+		header http.Header
+	}
+	deliveries := make(chan delivery, 2)
+	var attempts int
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails and is retried
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- delivery{body, r.Header}
+	}))
+	defer callback.Close()
+
+	handler := New(map[string]provider.Generator{"claude": &mockGenerator{err: errRateLimited}}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithWebhooks(webhook.NewSender("secret", webhook.WithBackoff(time.Millisecond, time.Millisecond))))
+
+	j := createJob(t, handler, Request{User: "Say hello", CallbackURL: callback.URL})
+
+	var d delivery
+	select {
+	case d = <-deliveries:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the job to be delivered")
+	}
+
+	if err := webhook.Verify([]byte("secret"), d.header, d.body, time.Now(), webhook.DefaultTolerance); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+	var got map[string]any
+	json.Unmarshal(d.body, &got)
+	result, _ := got["result"].(map[string]any)
+	if got["id"] != j.ID || got["status"] != "failed" || result["code"] != "rate_limited" {
+		t.Errorf("unexpected delivery: %s", d.body)
+	}
+}
+
+func TestHandleJobs_InvalidCallbackURL(t *testing.T) {
+	withoutWebhooks := newTestHandler(&mockGenerator{response: "Hello"})
+	withWebhooks := New(map[string]provider.Generator{"claude": &mockGenerator{response: "Hello"}}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithWebhooks(webhook.NewSender("secret")))
+
+	tests := []struct {
+		handler *Handler
+		url     string
+		want    string
+	}{
+		{withoutWebhooks, "http://localhost:9000/hook", "The 'callback_url' field requires a webhook secret to be configured"},
+		{withWebhooks, "ftp://localhost/hook", "The 'callback_url' field must be an http or https URL"},
+		{withWebhooks, "/hook", "The 'callback_url' field must be an http or https URL"},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(Request{User: "Hi", CallbackURL: tt.url})
+		req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		tt.handler.HandleJobs(w, req)
+
+		var resp Response
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp.Error != tt.want {
+			t.Errorf("expected 400 %q for %s, got %d %q", tt.want, tt.url, w.Code, resp.Error)
+		}
+	}
+}
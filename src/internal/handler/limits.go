@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+)
+
+// writeDeadlineMargin is the time left to send the response after a CLI run
+// has timed out.
+const writeDeadlineMargin = 10 * time.Second
+
+// QueueStatus is the payload of a "queue" server-sent event.
+type QueueStatus struct {
+	Position int `json:"position"`
+}
+
+// acquire waits for a free run slot of the named provider and returns a
+// function that releases it. onPosition is called with the 1-based queue
+// position while the request waits. Providers without a limiter are not
+// limited.
+func (h *Handler) acquire(ctx context.Context, providerName string, onPosition func(position int)) (func(), error) {
+	limiter, ok := h.queues[providerName]
+	if !ok {
+		return func() {}, nil
+	}
+	return limiter.Acquire(ctx, onPosition)
+}
+
+// sendQueueError reports a failed acquire: 429 with Retry-After if the queue
+// is full, nothing if the client went away while waiting.
+func (h *Handler) sendQueueError(w http.ResponseWriter, r *http.Request, providerName string, err error) {
+	if !errors.Is(err, queue.ErrQueueFull) {
+		log.Printf("[INFO] Client disconnected while queued for %s", providerName)
+		return
+	}
+
+	h.setRetryAfter(w, providerName)
+	h.sendErrorResponse(w, Response{
+		Error:     "Too many requests for provider " + providerName,
+		Code:      codeQueueFull,
+		Retryable: boolPtr(true),
+	}, http.StatusTooManyRequests)
+}
+
+// setRetryAfter sets the Retry-After header for a request rejected by the
+// full queue of the named provider.
+func (h *Handler) setRetryAfter(w http.ResponseWriter, providerName string) {
+	retryAfter := h.queues[providerName].RetryAfter()
+	log.Printf("[WARN] Queue for %s is full, retry after %s", providerName, retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+}
+
+// queuePositionHeader returns a queue position callback that reports the
+// position at which the request entered the queue in the X-Queue-Position
+// header.
+func queuePositionHeader(w http.ResponseWriter) func(position int) {
+	return func(position int) {
+		if w.Header().Get("X-Queue-Position") == "" {
+			w.Header().Set("X-Queue-Position", strconv.Itoa(position))
+		}
+	}
+}
+
+// requestTimeout validates a request's timeout_ms against the run timeout of
+// the named provider. It returns zero if the request does not set one.
+func (h *Handler) requestTimeout(providerName string, timeoutMS int) (time.Duration, error) {
+	if timeoutMS < 0 {
+		return 0, fmt.Errorf("The 'timeout_ms' field must not be negative")
+	}
+
+	requested := time.Duration(timeoutMS) * time.Millisecond
+	if limit := h.timeouts[providerName]; limit > 0 && requested > limit {
+		return 0, fmt.Errorf("The 'timeout_ms' field exceeds the maximum of %d for provider %s", limit.Milliseconds(), providerName)
+	}
+	return requested, nil
+}
+
+// runTimeout returns the CLI run timeout of the named provider, shortened to
+// requested if that is set. Zero means the run is not limited.
+func (h *Handler) runTimeout(providerName string, requested time.Duration) time.Duration {
+	timeout := h.timeouts[providerName]
+	if requested > 0 && (timeout == 0 || requested < timeout) {
+		return requested
+	}
+	return timeout
+}
+
+// withRunDeadline bounds a CLI run by timeout. The write deadline of the
+// response is moved past the run's deadline, so that the server does not cut
+// the connection of a run that started late, e.g. after waiting in the queue
+// or for a fallback.
+func withRunDeadline(ctx context.Context, w http.ResponseWriter, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	// Not every ResponseWriter supports deadlines, the server timeout
+	// applies then
+	if w != nil {
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + writeDeadlineMargin))
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// deadlineWriter is a ResponseWriter whose write deadline is only ever
+// extended, for responses that wait for several concurrent runs.
+type deadlineWriter struct {
+	http.ResponseWriter
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// SetWriteDeadline extends the write deadline of the underlying writer to t,
+// unless it is already later.
+func (d *deadlineWriter) SetWriteDeadline(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !t.After(d.deadline) {
+		return nil
+	}
+	d.deadline = t
+	return http.NewResponseController(d.ResponseWriter).SetWriteDeadline(t)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (d *deadlineWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
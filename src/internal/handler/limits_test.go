@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+)
+
+func newQueuedTestHandler(limiter *queue.Limiter) *Handler {
+	return New(
+		map[string]provider.Generator{"claude": &mockGenerator{response: "Hello"}},
+		"claude", "http://localhost:3000", "You are a test assistant.",
+		WithQueues(map[string]*queue.Limiter{"claude": limiter}),
+	)
+}
+
+// occupy takes the only run slot of limiter until the returned function is
+// called.
+func occupy(t *testing.T, limiter *queue.Limiter) func() {
+	t.Helper()
+	release, err := limiter.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to occupy limiter: %v", err)
+	}
+	return release
+}
+
+// servePrompt runs HandlePrompt in the background once a request is queued
+// behind the occupied slot, then frees the slot and waits for the response.
+func servePrompt(t *testing.T, handler *Handler, limiter *queue.Limiter, release func(), body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.HandlePrompt(w, req)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for limiter.Stats().Queued == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request was not queued")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	release()
+	<-done
+	return w
+}
+
+func TestHandlePrompt_QueueFull(t *testing.T) {
+	limiter := queue.NewLimiter(1, 0)
+	handler := newQueuedTestHandler(limiter)
+	defer occupy(t, limiter)()
+
+	body, _ := json.Marshal(Request{User: "Say hello"})
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandlePrompt(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("expected Retry-After 5, got %q", got)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "Too many requests for provider claude" {
+		t.Errorf("unexpected error: %q", resp.Error)
+	}
+}
+
+func TestHandlePrompt_QueuePosition(t *testing.T) {
+	limiter := queue.NewLimiter(1, 1)
+	handler := newQueuedTestHandler(limiter)
+
+	body, _ := json.Marshal(Request{User: "Say hello"})
+	w := servePrompt(t, handler, limiter, occupy(t, limiter), body)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Queue-Position"); got != "1" {
+		t.Errorf("expected X-Queue-Position 1, got %q", got)
+	}
+	if stats := limiter.Stats(); stats.Active != 0 || stats.Queued != 0 {
+		t.Errorf("expected slot to be released, got %+v", stats)
+	}
+}
+
+func TestHandlePrompt_StreamQueueEvents(t *testing.T) {
+	limiter := queue.NewLimiter(1, 1)
+	handler := newQueuedTestHandler(limiter)
+
+	body, _ := json.Marshal(Request{User: "Say hello", Stream: true})
+	w := servePrompt(t, handler, limiter, occupy(t, limiter), body)
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %q", len(events), w.Body.String())
+	}
+	if events[0].name != "queue" || events[0].data != `{"position":1}` {
+		t.Errorf("unexpected queue event: %s %q", events[0].name, events[0].data)
+	}
+	if events[2].name != "done" {
+		t.Errorf("expected done event, got %s", events[2].name)
+	}
+}
+
+func TestHandlePrompt_StreamQueueFull(t *testing.T) {
+	limiter := queue.NewLimiter(1, 0)
+	handler := newQueuedTestHandler(limiter)
+	defer occupy(t, limiter)()
+
+	body, _ := json.Marshal(Request{User: "Say hello", Stream: true})
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandlePrompt(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+}
+
+func TestHandleSessionMessages_QueueFull(t *testing.T) {
+	limiter := queue.NewLimiter(1, 0)
+	handler := newQueuedTestHandler(limiter)
+	sess := createSession(t, handler, `{}`)
+	defer occupy(t, limiter)()
+
+	w := postSessionMessage(handler, sess.ID, "Hi")
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestHandleSessionMessages_QueuePosition(t *testing.T) {
+	limiter := queue.NewLimiter(1, 1)
+	handler := newQueuedTestHandler(limiter)
+	sess := createSession(t, handler, `{}`)
+	release := occupy(t, limiter)
+
+	var w *httptest.ResponseRecorder
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w = postSessionMessage(handler, sess.ID, "Hi")
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for limiter.Stats().Queued == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("turn was not queued")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	release()
+	<-done
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Queue-Position"); got != "1" {
+		t.Errorf("expected X-Queue-Position 1, got %q", got)
+	}
+}
+
+func TestHandleProviders_QueueStats(t *testing.T) {
+	limiter := queue.NewLimiter(2, 5)
+	handler := newQueuedTestHandler(limiter)
+	defer occupy(t, limiter)()
+
+	req := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleProviders(w, req)
+
+	var resp map[string][]ProviderInfo
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp["providers"]) != 1 || resp["providers"][0].Queue == nil {
+		t.Fatalf("expected queue stats, got %+v", resp["providers"])
+	}
+	want := queue.Stats{Active: 1, Queued: 0, MaxConcurrency: 2, MaxQueue: 5}
+	if got := *resp["providers"][0].Queue; got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestHandlePrompt_Timeout(t *testing.T) {
+	handler := New(
+		map[string]provider.Generator{"claude": &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}}},
+		"claude", "http://localhost:3000", "You are a test assistant.",
+		WithTimeouts(map[string]time.Duration{"claude": 50 * time.Millisecond}),
+	)
+
+	start := time.Now()
+	w := postPrompt(handler, Request{User: "Say hello"})
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status 504, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the run to be cut after the timeout, took %v", elapsed)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != "timeout" {
+		t.Errorf("expected code timeout, got %q", resp.Code)
+	}
+}
+
+func TestHandlePrompt_RequestTimeout(t *testing.T) {
+	var deadline time.Duration
+	handler := New(
+		map[string]provider.Generator{"claude": &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+			d, _ := ctx.Deadline()
+			deadline = time.Until(d)
+			return "Hello", nil
+		}}},
+		"claude", "http://localhost:3000", "You are a test assistant.",
+		WithTimeouts(map[string]time.Duration{"claude": time.Minute}),
+	)
+
+	w := postPrompt(handler, Request{User: "Say hello", TimeoutMS: 5000})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if deadline <= 0 || deadline > 5*time.Second {
+		t.Errorf("expected a deadline of at most 5s, got %v", deadline)
+	}
+}
+
+func TestHandlePrompt_InvalidRequestTimeout(t *testing.T) {
+	handler := New(
+		map[string]provider.Generator{"claude": &mockGenerator{response: "Hello"}},
+		"claude", "http://localhost:3000", "You are a test assistant.",
+		WithTimeouts(map[string]time.Duration{"claude": time.Minute}),
+	)
+
+	tests := []struct {
+		timeoutMS int
+		want      string
+	}{
+		{-1, "The 'timeout_ms' field must not be negative"},
+		{120000, "The 'timeout_ms' field exceeds the maximum of 60000 for provider claude"},
+	}
+
+	for _, tc := range tests {
+		w := postPrompt(handler, Request{User: "Say hello", TimeoutMS: tc.timeoutMS})
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("timeout_ms %d: expected status 400, got %d", tc.timeoutMS, w.Code)
+		}
+
+		var resp Response
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Error != tc.want {
+			t.Errorf("timeout_ms %d: expected error %q, got %q", tc.timeoutMS, tc.want, resp.Error)
+		}
+	}
+}
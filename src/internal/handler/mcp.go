@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/mcp"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+)
+
+// mcpHeaders are the request headers of the MCP streamable HTTP transport.
+const mcpHeaders = "Content-Type, Accept, Mcp-Session-Id, Mcp-Protocol-Version"
+
+// mcpWriterKey is the context key of the ResponseWriter of an MCP HTTP
+// request, so that tool calls can extend its write deadline.
+type mcpWriterKey struct{}
+
+// askArguments are the arguments of the ask_<provider> tools.
+type askArguments struct {
+	Prompt    string `json:"prompt"`
+	Model     string `json:"model,omitempty"`
+	TimeoutMS int    `json:"timeout_ms,omitempty"`
+}
+
+// MCPServer returns an MCP server that exposes each provider as an
+// ask_<provider> tool and the system prompt as the "system_prompt" prompt.
+// Characters of provider names that tool names do not allow are replaced
+// with "_"; providers whose tool name is already taken are left out. Tool
+// calls run through the same queues and timeouts as POST /prompt, but never
+// fall back, as the client chose the provider by choosing the tool.
+func (h *Handler) MCPServer(version string) *mcp.Server {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]mcp.Tool, 0, len(names))
+	taken := make(map[string]string, len(names))
+	for _, name := range names {
+		tool := h.askTool(name)
+		if other, ok := taken[tool.Name]; ok {
+			log.Printf("[WARN] Skipping MCP tool for provider %s, %s is already used by %s", name, tool.Name, other)
+			continue
+		}
+		taken[tool.Name] = name
+		tools = append(tools, tool)
+	}
+
+	prompts := []mcp.Prompt{{
+		Name:        "system_prompt",
+		Description: "The system prompt the proxy sends to every provider",
+		TextFunc:    func() string { return h.defaultSystemPrompt().Text },
+	}}
+
+	return mcp.NewServer("local-ai-tool-proxy", version, tools, prompts)
+}
+
+// askTool returns the tool that sends a prompt to the named provider.
+func (h *Handler) askTool(name string) mcp.Tool {
+	properties := map[string]any{
+		"prompt": map[string]any{
+			"type":        "string",
+			"description": "The prompt to send",
+		},
+		"timeout_ms": map[string]any{
+			"type":        "integer",
+			"description": "Shortens the CLI run timeout, in milliseconds",
+		},
+	}
+	if models := h.models[name]; len(models) > 0 {
+		properties["model"] = map[string]any{
+			"type":        "string",
+			"description": "The model to use instead of the CLI's default",
+			"enum":        models,
+		}
+	}
+
+	return mcp.Tool{
+		Name:        mcp.ToolName("ask_" + name),
+		Description: fmt.Sprintf("Ask %s (the %s provider of the local AI tool proxy) and return its response.", providerDescription(name, h.providers[name]), name),
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"prompt"},
+		},
+		Call: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			return h.ask(ctx, name, arguments)
+		},
+	}
+}
+
+// ask runs an ask_<provider> tool call.
+func (h *Handler) ask(ctx context.Context, name string, arguments json.RawMessage) (string, error) {
+	var args askArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", errors.New("Invalid arguments")
+	}
+	if args.Prompt == "" {
+		return "", errors.New("The 'prompt' argument is required")
+	}
+
+	g, err := h.promptGeneration(Request{User: args.Prompt, Provider: name, Model: args.Model, TimeoutMS: args.TimeoutMS}, prompt.OverrideDisabled)
+	if err != nil {
+		return "", err
+	}
+	g.noFallback = true
+
+	log.Printf("[INFO] Generating MCP response using %s for prompt: %q", name, args.Prompt)
+
+	// Calls over stdio have no writer
+	w, _ := ctx.Value(mcpWriterKey{}).(http.ResponseWriter)
+	res := h.generate(ctx, w, g)
+	if res.err != nil {
+		if ctx.Err() != nil {
+			log.Printf("[INFO] MCP request cancelled, %s CLI stopped", res.provider)
+			return "", ctx.Err()
+		}
+		log.Printf("[ERROR] %s CLI failed: %v", res.provider, res.err)
+		resp, _ := res.failedResponse()
+		return "", fmt.Errorf("%s (%s)", resp.Error, resp.Code)
+	}
+
+	log.Printf("[INFO] Successfully generated MCP response using %s", res.provider)
+	return res.text, nil
+}
+
+// HandleMCP returns the handler of the MCP streamable HTTP transport for
+// server. Requests from other origins are rejected, as required by the
+// transport to prevent DNS rebinding.
+func (h *Handler) HandleMCP(server *mcp.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		w.Header().Set("Access-Control-Allow-Headers", mcpHeaders)
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if !h.originAllowed(r) {
+			log.Printf("[WARN] Rejected MCP request from origin %s", r.Header.Get("Origin"))
+			h.sendError(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		server.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), mcpWriterKey{}, w)))
+	}
+}
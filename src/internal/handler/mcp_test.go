@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// callMCP sends a JSON-RPC request to the handler's MCP server and returns
+// its result.
+func callMCP(t *testing.T, handler *Handler, request string) map[string]any {
+	t.Helper()
+	data := handler.MCPServer("test").Handle(context.Background(), []byte(request))
+
+	var resp struct {
+		Result map[string]any `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Result == nil {
+		t.Fatalf("unexpected response %s", data)
+	}
+	return resp.Result
+}
+
+// toolText returns the text of a tool result and whether it is an error.
+func toolText(result map[string]any) (string, bool) {
+	content, _ := result["content"].([]any)
+	if len(content) == 0 {
+		return "", false
+	}
+	text, _ := content[0].(map[string]any)["text"].(string)
+	return text, result["isError"] == true
+}
+
+func TestMCPServer_Tools(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockGenerator{response: "Hello from Claude"},
+		"gemini": &mockGenerator{response: "Hello from Gemini"},
+	}, "claude")
+
+	result := callMCP(t, handler, `{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	tools, _ := result["tools"].([]any)
+	if len(tools) != 2 {
+		t.Fatalf("expected 2 tools, got %v", result)
+	}
+	for i, name := range []string{"ask_claude", "ask_gemini"} {
+		if tool := tools[i].(map[string]any); tool["name"] != name {
+			t.Errorf("expected tool %s, got %v", name, tool)
+		}
+	}
+	if description, _ := tools[1].(map[string]any)["description"].(string); !strings.Contains(description, "Google Gemini") {
+		t.Errorf("expected provider description, got %q", description)
+	}
+
+	result = callMCP(t, handler, `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "ask_gemini", "arguments": {"prompt": "Hi"}}}`)
+	if text, isError := toolText(result); isError || text != "Hello from Gemini" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestMCPServer_SanitisesToolNames(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"my.tool": &mockGenerator{response: "Hello from my.tool"},
+		"my_tool": &mockGenerator{response: "Hello from my_tool"},
+	}, "my.tool")
+
+	result := callMCP(t, handler, `{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	tools, _ := result["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "ask_my_tool" {
+		t.Fatalf("expected only ask_my_tool, got %v", result)
+	}
+
+	result = callMCP(t, handler, `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "ask_my_tool", "arguments": {"prompt": "Hi"}}}`)
+	if text, isError := toolText(result); isError || text != "Hello from my.tool" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestMCPServer_ToolErrors(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockGenerator{err: errRateLimited},
+	}, "claude")
+
+	tests := []struct {
+		arguments string
+		want      string
+	}{
+		{`{}`, "The 'prompt' argument is required"},
+		{`{"prompt": "Hi", "model": "opus"}`, "Provider claude does not support model selection"},
+		{`{"prompt": "Hi"}`, "Provider rate limit reached (rate_limited)"},
+	}
+
+	for _, tt := range tests {
+		result := callMCP(t, handler, `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "ask_claude", "arguments": `+tt.arguments+`}}`)
+		if text, isError := toolText(result); !isError || text != tt.want {
+			t.Errorf("expected error %q for %s, got %v", tt.want, tt.arguments, result)
+		}
+	}
+}
+
+func TestMCPServer_NoFallback(t *testing.T) {
+	handler := newFallbackTestHandler(map[string]provider.Generator{
+		"claude": &mockGenerator{err: errRateLimited},
+		"gemini": &mockGenerator{response: "Hello from gemini"},
+		"codex":  &mockGenerator{response: "Hello from codex"},
+	})
+
+	result := callMCP(t, handler, `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "ask_claude", "arguments": {"prompt": "Hi"}}}`)
+	if text, isError := toolText(result); !isError || text != "Provider rate limit reached (rate_limited)" {
+		t.Errorf("expected the claude failure, got %v", result)
+	}
+}
+
+func TestMCPServer_SystemPrompt(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	result := callMCP(t, handler, `{"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": {"name": "system_prompt"}}`)
+	messages, _ := result["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("unexpected prompt: %v", result)
+	}
+	content, _ := messages[0].(map[string]any)["content"].(map[string]any)
+	if content["text"] != "You are a test assistant." {
+		t.Errorf("unexpected prompt text: %v", content)
+	}
+}
+
+func TestHandleMCP(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+	serve := handler.HandleMCP(handler.MCPServer("test"))
+
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "ask_claude", "arguments": {"prompt": "Hi"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	serve(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"text":"Hello"`) {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	serve(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for another origin, got %d", w.Code)
+	}
+}
+
+func TestHandleMCP_ExtendsWriteDeadline(t *testing.T) {
+	handler := New(
+		map[string]provider.Generator{"claude": &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+			time.Sleep(300 * time.Millisecond)
+			return "Hello", nil
+		}}},
+		"claude", "http://localhost:3000", "You are a test assistant.",
+		WithTimeouts(map[string]time.Duration{"claude": time.Minute}),
+	)
+
+	// The tool call outlasts the server's write timeout
+	server := httptest.NewUnstartedServer(handler.HandleMCP(handler.MCPServer("test")))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "ask_claude", "arguments": {"prompt": "Hi"}}}`
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var message struct {
+		Result map[string]any `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&message)
+	if text, isError := toolText(message.Result); isError || text != "Hello" {
+		t.Errorf("unexpected result: %v", message.Result)
+	}
+}
@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// OllamaGenerateRequest is the subset of an Ollama /api/generate request
+// supported by the proxy. Stream defaults to true, as in Ollama.
+type OllamaGenerateRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	System string `json:"system,omitempty"`
+	Stream *bool  `json:"stream,omitempty"`
+}
+
+// OllamaChatRequest is the subset of an Ollama /api/chat request supported by
+// the proxy. Stream defaults to true, as in Ollama.
+type OllamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   *bool         `json:"stream,omitempty"`
+}
+
+// OllamaStatus holds the fields shared by all Ollama responses and stream
+// lines. The done fields are only set on the final one.
+type OllamaStatus struct {
+	Model         string `json:"model"`
+	CreatedAt     string `json:"created_at"`
+	Done          bool   `json:"done"`
+	DoneReason    string `json:"done_reason,omitempty"`
+	TotalDuration int64  `json:"total_duration,omitempty"`
+}
+
+// OllamaGenerateResponse is an /api/generate response or stream line.
+type OllamaGenerateResponse struct {
+	OllamaStatus
+	Response string `json:"response"`
+}
+
+// OllamaChatResponse is an /api/chat response or stream line.
+type OllamaChatResponse struct {
+	OllamaStatus
+	Message ChatMessage `json:"message"`
+}
+
+// OllamaTag is an entry of the /api/tags model list.
+type OllamaTag struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	ModifiedAt string `json:"modified_at"`
+	Size       int64  `json:"size"`
+	Digest     string `json:"digest"`
+	Details    struct {
+		Family string `json:"family"`
+	} `json:"details"`
+}
+
+// ollamaReply builds the response or stream line for text from its status.
+type ollamaReply func(status OllamaStatus, text string) any
+
+// HandleOllamaGenerate handles POST /api/generate requests.
+func (h *Handler) HandleOllamaGenerate(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req OllamaGenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		h.sendError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(req.Prompt) == "" {
+		h.sendError(w, "The 'prompt' field is required", http.StatusBadRequest)
+		return
+	}
+
+	h.serveOllama(w, r, req.Model, req.Stream, []string{req.System}, req.Prompt, func(status OllamaStatus, text string) any {
+		return OllamaGenerateResponse{OllamaStatus: status, Response: text}
+	})
+}
+
+// HandleOllamaChat handles POST /api/chat requests.
+func (h *Handler) HandleOllamaChat(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req OllamaChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		h.sendError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var instructions []string
+	var turns []session.Message
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system":
+			instructions = append(instructions, string(m.Content))
+		case session.RoleUser, session.RoleAssistant:
+			turns = append(turns, session.Message{Role: m.Role, Content: string(m.Content)})
+		default:
+			h.sendError(w, "Unsupported message role: "+m.Role, http.StatusBadRequest)
+			return
+		}
+	}
+
+	userPrompt, err := conversationPrompt(turns)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.serveOllama(w, r, req.Model, req.Stream, instructions, userPrompt, func(status OllamaStatus, text string) any {
+		return OllamaChatResponse{
+			OllamaStatus: status,
+			Message:      ChatMessage{Role: session.RoleAssistant, Content: ChatContent(text)},
+		}
+	})
+}
+
+// serveOllama generates a response and sends it as a single JSON object or,
+// if stream is unset or true, as newline-delimited JSON objects ending with
+// a done object. Errors are sent as {"error": ...}, as Ollama does.
+func (h *Handler) serveOllama(w http.ResponseWriter, r *http.Request, model string, stream *bool, instructions []string, userPrompt string, reply ollamaReply) {
+	p, providerName, err := h.resolveModelID(strings.TrimSuffix(model, ":latest"))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		h.sendError(w, err.Error(), compatStatus(err))
+		return
+	}
+	if model == "" {
+		model = providerName
+	}
+
+	systemPrompt, err := h.withSystemPrompt(providerName, instructions, h.overrideFor(r))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		h.sendError(w, err.Error(), systemPromptStatus(err))
+		return
+	}
+
+	log.Printf("[INFO] Generating Ollama response using %s for prompt: %q", providerName, userPrompt)
+
+	start := time.Now()
+	status := func(done bool) OllamaStatus {
+		s := OllamaStatus{Model: model, CreatedAt: time.Now().UTC().Format(time.RFC3339Nano), Done: done}
+		if done {
+			s.DoneReason = "stop"
+			s.TotalDuration = time.Since(start).Nanoseconds()
+		}
+		return s
+	}
+
+	// The response is started once a run slot is acquired, so that the
+	// queue position header and a 429 status can still be sent
+	var started bool
+	g := generation{
+		provider:     p,
+		providerName: providerName,
+		systemPrompt: systemPrompt,
+		userPrompt:   userPrompt,
+		onPosition:   queuePositionHeader(w),
+	}
+	streaming := stream == nil || *stream
+	if streaming {
+		g.onStart = func(string) {
+			if !started {
+				started = true
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+				flush(w)
+			}
+		}
+		g.onDelta = func(delta string) {
+			writeNDJSON(w, reply(status(false), delta))
+		}
+	}
+
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		resp, statusCode, ok := h.compatFailure(w, r, res)
+		if !ok {
+			return
+		}
+		if !started {
+			h.sendErrorResponse(w, resp, statusCode)
+			return
+		}
+		writeNDJSON(w, resp)
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated Ollama response using %s", res.provider)
+	final := status(true)
+	final.Model = answeredModel(model, providerName, res.provider)
+	if streaming {
+		writeNDJSON(w, reply(final, ""))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply(final, res.text))
+}
+
+// HandleOllamaTags handles GET /api/tags requests, listing every provider and
+// allowed model as a local model.
+func (h *Handler) HandleOllamaTags(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tags := make([]OllamaTag, 0, len(h.providers))
+	for _, id := range h.modelIDs() {
+		tag := OllamaTag{Name: id, Model: id, ModifiedAt: time.Time{}.Format(time.RFC3339)}
+		tag.Details.Family, _, _ = strings.Cut(id, "/")
+		tags = append(tags, tag)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"models": tags})
+}
+
+// writeNDJSON writes payload as a line of JSON and flushes it.
+func writeNDJSON(w http.ResponseWriter, payload any) {
+	json.NewEncoder(w).Encode(payload)
+	flush(w)
+}
+
+// flush flushes w if it supports flushing.
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
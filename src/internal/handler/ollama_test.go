@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+func postOllama(handler http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestHandleOllamaGenerate(t *testing.T) {
+	gen := &promptGenerator{}
+	handler := newOverrideTestHandler(gen, prompt.OverrideAppend)
+
+	w := postOllama(handler.HandleOllamaGenerate, "/api/generate",
+		`{"model": "claude:latest", "prompt": "Hi", "system": "Answer briefly.", "stream": false}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp OllamaGenerateResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Response != "ok" || !resp.Done || resp.DoneReason != "stop" || resp.Model != "claude:latest" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if gen.systemPrompt != "You are a test assistant.\n\nAnswer briefly." || gen.userPrompt != "Hi" {
+		t.Errorf("unexpected prompts: %q, %q", gen.systemPrompt, gen.userPrompt)
+	}
+}
+
+func TestHandleOllamaGenerate_Stream(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hello", ", world!"}},
+	}, "claude")
+
+	// Ollama streams unless "stream" is false
+	w := postOllama(handler.HandleOllamaGenerate, "/api/generate", `{"model": "claude", "prompt": "Hi"}`)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected Content-Type application/x-ndjson, got %q", ct)
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %q", len(lines), w.Body.String())
+	}
+
+	var text strings.Builder
+	for i, line := range lines {
+		var resp OllamaGenerateResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		if resp.Done != (i == len(lines)-1) {
+			t.Errorf("unexpected done flag in line %d: %q", i, line)
+		}
+		text.WriteString(resp.Response)
+	}
+	if text.String() != "Hello, world!" {
+		t.Errorf("unexpected text %q", text.String())
+	}
+}
+
+func TestHandleOllamaGenerate_StreamError(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hel"}, err: provider.ErrCLIExecution},
+	}, "claude")
+
+	w := postOllama(handler.HandleOllamaGenerate, "/api/generate", `{"prompt": "Hi"}`)
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var resp Response
+	json.Unmarshal([]byte(lines[len(lines)-1]), &resp)
+	if resp.Error != "Failed to generate response" || resp.Code != "cli_failure" {
+		t.Errorf("unexpected final line: %q", lines[len(lines)-1])
+	}
+}
+
+func TestHandleOllamaChat(t *testing.T) {
+	gen := &promptGenerator{}
+	handler := newOverrideTestHandler(gen, prompt.OverrideAppend)
+
+	w := postOllama(handler.HandleOllamaChat, "/api/chat", `{
+		"model": "claude",
+		"stream": false,
+		"messages": [
+			{"role": "system", "content": "Answer briefly."},
+			{"role": "user", "content": "Hi"},
+			{"role": "assistant", "content": "Hello!"},
+			{"role": "user", "content": "How are you?"}
+		]
+	}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp OllamaChatResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Message.Role != "assistant" || resp.Message.Content != "ok" || !resp.Done {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if gen.systemPrompt != "You are a test assistant.\n\nAnswer briefly." {
+		t.Errorf("unexpected system prompt: %q", gen.systemPrompt)
+	}
+	if !strings.Contains(gen.userPrompt, "Assistant: Hello!") {
+		t.Errorf("expected transcript in user prompt, got %q", gen.userPrompt)
+	}
+}
+
+func TestHandleOllama_Errors(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "ok"})
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		status  int
+	}{
+		{"generate invalid JSON", handler.HandleOllamaGenerate, `{`, http.StatusBadRequest},
+		{"generate missing prompt", handler.HandleOllamaGenerate, `{"model": "claude"}`, http.StatusBadRequest},
+		{"generate unknown model", handler.HandleOllamaGenerate, `{"model": "llama3", "prompt": "Hi"}`, http.StatusNotFound},
+		{"chat unsupported role", handler.HandleOllamaChat, `{"messages": [{"role": "tool", "content": "Hi"}]}`, http.StatusBadRequest},
+		{"chat no user message", handler.HandleOllamaChat, `{"messages": []}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postOllama(tt.handler, "/api", tt.body)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			var resp Response
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error == "" {
+				t.Error("expected error message")
+			}
+		})
+	}
+}
+
+func TestHandleOllamaTags(t *testing.T) {
+	handler := New(map[string]provider.Generator{
+		"claude": &mockGenerator{},
+		"gemini": &mockGenerator{},
+	}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithModels(map[string][]string{"gemini": {"gemini-2.5-pro"}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tags", nil)
+	w := httptest.NewRecorder()
+	handler.HandleOllamaTags(w, req)
+
+	var resp struct {
+		Models []OllamaTag `json:"models"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	var names []string
+	for _, tag := range resp.Models {
+		names = append(names, tag.Name+"@"+tag.Details.Family)
+	}
+	if strings.Join(names, ",") != "claude@claude,gemini@gemini,gemini/gemini-2.5-pro@gemini" {
+		t.Errorf("unexpected tags: %v", names)
+	}
+}
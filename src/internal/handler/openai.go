@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// ChatCompletionRequest is the subset of an OpenAI chat completion request
+// supported by the proxy. Model selects the provider.
+type ChatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+}
+
+// ChatMessage is a message of an OpenAI chat conversation.
+type ChatMessage struct {
+	Role    string      `json:"role"`
+	Content ChatContent `json:"content"`
+}
+
+// ChatContent is the content of a chat message. Clients send it either as a
+// string or as an array of content parts, of which the text parts are used.
+type ChatContent string
+
+// UnmarshalJSON accepts a string, an array of content parts or null.
+func (c *ChatContent) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*c = ""
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = ChatContent(text)
+		return nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*c = ChatContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+// ChatCompletion is an OpenAI chat completion or, when streaming, a chat
+// completion chunk.
+type ChatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+}
+
+// ChatChoice is the single choice of a chat completion. Message is set for
+// complete responses and Delta for chunks.
+type ChatChoice struct {
+	Index        int          `json:"index"`
+	Message      *ChatMessage `json:"message,omitempty"`
+	Delta        *ChatDelta   `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+// ChatDelta is the incremental content of a chat completion chunk.
+type ChatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// OpenAIModel is an entry of the OpenAI model list.
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// openAIError is the OpenAI error response format.
+type openAIError struct {
+	Error struct {
+		Message string  `json:"message"`
+		Type    string  `json:"type"`
+		Param   *string `json:"param"`
+		Code    string  `json:"code,omitempty"`
+	} `json:"error"`
+}
+
+// HandleChatCompletions handles POST /v1/chat/completions requests.
+func (h *Handler) HandleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		sendOpenAIError(w, "Method not allowed", "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		sendOpenAIError(w, "Invalid JSON", "", http.StatusBadRequest)
+		return
+	}
+
+	p, providerName, err := h.resolveModelID(req.Model)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendOpenAIError(w, err.Error(), "model_not_found", compatStatus(err))
+		return
+	}
+
+	systemPrompt, userPrompt, err := h.chatPrompt(providerName, req.Messages, h.overrideFor(r))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		sendOpenAIError(w, err.Error(), "", systemPromptStatus(err))
+		return
+	}
+
+	log.Printf("[INFO] Generating chat completion using %s for prompt: %q", providerName, userPrompt)
+
+	g := generation{
+		provider:     p,
+		providerName: providerName,
+		systemPrompt: systemPrompt,
+		userPrompt:   userPrompt,
+	}
+	completion := ChatCompletion{
+		ID:      newResponseID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+
+	if req.Stream {
+		h.streamChatCompletion(w, r, g, completion)
+		return
+	}
+
+	g.onPosition = queuePositionHeader(w)
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		if resp, status, ok := h.compatFailure(w, r, res); ok {
+			sendOpenAIError(w, resp.Error, resp.Code, status)
+		}
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated chat completion using %s", res.provider)
+	completion.Model = answeredModel(req.Model, providerName, res.provider)
+	completion.Choices = []ChatChoice{{
+		Message:      &ChatMessage{Role: session.RoleAssistant, Content: ChatContent(res.text)},
+		FinishReason: stringPtr("stop"),
+	}}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(completion)
+}
+
+// streamChatCompletion serves a chat completion as a stream of chunks,
+// terminated by "data: [DONE]". Queue positions are sent as comments, which
+// clients ignore.
+func (h *Handler) streamChatCompletion(w http.ResponseWriter, r *http.Request, g generation, completion ChatCompletion) {
+	if _, ok := w.(http.Flusher); !ok {
+		sendOpenAIError(w, "Streaming not supported", "", http.StatusInternalServerError)
+		return
+	}
+
+	completion.Object = "chat.completion.chunk"
+	chunk := func(delta ChatDelta, finishReason *string) ChatCompletion {
+		c := completion
+		c.Choices = []ChatChoice{{Delta: &delta, FinishReason: finishReason}}
+		return c
+	}
+
+	// The stream is opened lazily so that a full queue can still be
+	// reported with a 429 status
+	var sse *sseWriter
+	openStream := func() {
+		if sse == nil {
+			sse, _ = newSSEWriter(w)
+		}
+	}
+	var started bool
+
+	g.onPosition = func(position int) {
+		openStream()
+		sse.write(": queue position %d\n\n", position)
+	}
+	g.onStart = func(string) { openStream() }
+	g.onDelta = func(delta string) {
+		if !started {
+			started = true
+			sse.sendData(chunk(ChatDelta{Role: session.RoleAssistant}, nil))
+		}
+		sse.sendData(chunk(ChatDelta{Content: delta}, nil))
+	}
+
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		resp, status, ok := h.compatFailure(w, r, res)
+		if !ok {
+			return
+		}
+		if sse == nil {
+			sendOpenAIError(w, resp.Error, resp.Code, status)
+			return
+		}
+		sse.sendData(newOpenAIError(resp.Error, resp.Code, status))
+		return
+	}
+
+	log.Printf("[INFO] Successfully streamed chat completion using %s", res.provider)
+	if !started {
+		sse.sendData(chunk(ChatDelta{Role: session.RoleAssistant}, nil))
+	}
+	sse.sendData(chunk(ChatDelta{}, stringPtr("stop")))
+	sse.write("data: [DONE]\n\n")
+}
+
+// HandleOpenAIModels handles GET /v1/models requests.
+func (h *Handler) HandleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	h.setCompatCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		sendOpenAIError(w, "Method not allowed", "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	models := make([]OpenAIModel, 0, len(h.providers))
+	for _, id := range h.modelIDs() {
+		models = append(models, OpenAIModel{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: "local-ai-tool-proxy",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": models})
+}
+
+// chatPrompt translates chat messages into the system prompt and user
+// prompt for the named provider. System and developer messages are combined
+// with the configured system prompt as permitted by override; the
+// conversation is replayed into the user prompt.
+func (h *Handler) chatPrompt(providerName string, messages []ChatMessage, override prompt.Override) (string, string, error) {
+	var instructions []string
+	var turns []session.Message
+	for _, m := range messages {
+		switch m.Role {
+		case "system", "developer":
+			instructions = append(instructions, string(m.Content))
+		case session.RoleUser, session.RoleAssistant:
+			turns = append(turns, session.Message{Role: m.Role, Content: string(m.Content)})
+		default:
+			return "", "", fmt.Errorf("Unsupported message role: %s", m.Role)
+		}
+	}
+
+	userPrompt, err := conversationPrompt(turns)
+	if err != nil {
+		return "", "", err
+	}
+	systemPrompt, err := h.withSystemPrompt(providerName, instructions, override)
+	if err != nil {
+		return "", "", err
+	}
+	return systemPrompt, userPrompt, nil
+}
+
+// newOpenAIError builds an OpenAI error response. The error type is derived
+// from the HTTP status.
+func newOpenAIError(message, code string, statusCode int) openAIError {
+	var resp openAIError
+	resp.Error.Message = message
+	resp.Error.Code = code
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		resp.Error.Type = "authentication_error"
+	case statusCode == http.StatusTooManyRequests:
+		resp.Error.Type = "rate_limit_error"
+	case statusCode < http.StatusInternalServerError:
+		resp.Error.Type = "invalid_request_error"
+	default:
+		resp.Error.Type = "api_error"
+	}
+	return resp
+}
+
+// sendOpenAIError sends an error response in the OpenAI format.
+func sendOpenAIError(w http.ResponseWriter, message, code string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(newOpenAIError(message, code, statusCode))
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
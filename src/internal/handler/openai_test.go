@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// promptGenerator records the system and user prompt of the last call.
+type promptGenerator struct {
+	systemPrompt string
+	userPrompt   string
+}
+
+func (g *promptGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	g.systemPrompt, g.userPrompt = systemPrompt, userPrompt
+	return "ok", nil
+}
+
+// newOverrideTestHandler returns a handler for gen whose system override
+// policy is override.
+func newOverrideTestHandler(gen provider.Generator, override prompt.Override) *Handler {
+	return New(map[string]provider.Generator{"claude": gen}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithSystemOverride(prompt.OverridePolicy{Default: override}))
+}
+
+func postChatCompletion(handler *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.HandleChatCompletions(w, req)
+	return w
+}
+
+func TestHandleChatCompletions(t *testing.T) {
+	gen := &promptGenerator{}
+	handler := newOverrideTestHandler(gen, prompt.OverrideAppend)
+
+	w := postChatCompletion(handler, `{
+		"model": "claude",
+		"messages": [
+			{"role": "system", "content": "Answer briefly."},
+			{"role": "user", "content": "Hi"},
+			{"role": "assistant", "content": "Hello!"},
+			{"role": "user", "content": [{"type": "text", "text": "How are you?"}]}
+		]
+	}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var completion ChatCompletion
+	json.NewDecoder(w.Body).Decode(&completion)
+	if completion.Object != "chat.completion" || completion.Model != "claude" || !strings.HasPrefix(completion.ID, "chatcmpl-") {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "ok" || *completion.Choices[0].FinishReason != "stop" {
+		t.Errorf("unexpected choices: %+v", completion.Choices)
+	}
+
+	if gen.systemPrompt != "You are a test assistant.\n\nAnswer briefly." {
+		t.Errorf("unexpected system prompt: %q", gen.systemPrompt)
+	}
+	if !strings.Contains(gen.userPrompt, "Assistant: Hello!") || !strings.HasSuffix(gen.userPrompt, "How are you?") {
+		t.Errorf("expected transcript in user prompt, got %q", gen.userPrompt)
+	}
+}
+
+func TestHandleChatCompletions_SystemOverride(t *testing.T) {
+	body := `{"model": "claude", "messages": [{"role": "system", "content": "Answer briefly."}, {"role": "user", "content": "Hi"}]}`
+
+	gen := &promptGenerator{}
+	w := postChatCompletion(newOverrideTestHandler(gen, prompt.OverrideDisabled), body)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d: %s", w.Code, w.Body.String())
+	}
+	if gen.userPrompt != "" {
+		t.Error("expected the provider not to be called")
+	}
+
+	w = postChatCompletion(newOverrideTestHandler(gen, prompt.OverrideReplace), body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gen.systemPrompt != "Answer briefly." {
+		t.Errorf("expected replaced system prompt, got %q", gen.systemPrompt)
+	}
+
+	// Requests without system messages are not affected by the policy
+	w = postChatCompletion(newOverrideTestHandler(gen, prompt.OverrideDisabled), `{"model": "claude", "messages": [{"role": "user", "content": "Hi"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gen.systemPrompt != "You are a test assistant." {
+		t.Errorf("expected configured system prompt, got %q", gen.systemPrompt)
+	}
+}
+
+func TestHandleChatCompletions_SystemOverrideByAPIKey(t *testing.T) {
+	gen := &promptGenerator{}
+	handler := New(map[string]provider.Generator{"claude": gen}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithSystemOverride(prompt.OverridePolicy{APIKeys: map[string]prompt.Override{"internal": prompt.OverrideAppend}}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model": "claude", "messages": [{"role": "system", "content": "Answer briefly."}, {"role": "user", "content": "Hi"}]}`))
+	req.Header.Set("Authorization", "Bearer internal")
+	w := httptest.NewRecorder()
+	handler.HandleChatCompletions(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gen.systemPrompt != "You are a test assistant.\n\nAnswer briefly." {
+		t.Errorf("expected appended system prompt, got %q", gen.systemPrompt)
+	}
+}
+
+func TestHandleChatCompletions_ModelSelection(t *testing.T) {
+	handler := New(map[string]provider.Generator{"claude": &modelGenerator{}}, "claude",
+		"http://localhost:3000", "You are a test assistant.",
+		WithModels(map[string][]string{"claude": {"sonnet"}}))
+
+	w := postChatCompletion(handler, `{"model": "claude/sonnet", "messages": [{"role": "user", "content": "Hi"}]}`)
+
+	var completion ChatCompletion
+	json.NewDecoder(w.Body).Decode(&completion)
+	if completion.Model != "claude/sonnet" || completion.Choices[0].Message.Content != "model=sonnet" {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+}
+
+func TestHandleChatCompletions_Errors(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{err: provider.ErrCLIExecution})
+
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		errorType string
+	}{
+		{"invalid JSON", `{`, http.StatusBadRequest, "invalid_request_error"},
+		{"unknown model", `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`, http.StatusNotFound, "invalid_request_error"},
+		{"no user message", `{"messages": [{"role": "system", "content": "Hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"unsupported role", `{"messages": [{"role": "tool", "content": "Hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"provider failure", `{"messages": [{"role": "user", "content": "Hi"}]}`, http.StatusInternalServerError, "api_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postChatCompletion(handler, tt.body)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			var resp openAIError
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error.Type != tt.errorType || resp.Error.Message == "" {
+				t.Errorf("unexpected error: %+v", resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_Stream(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hello", ", world!"}},
+	}, "claude")
+
+	w := postChatCompletion(handler, `{"model": "claude", "stream": true, "messages": [{"role": "user", "content": "Hi"}]}`)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %d: %q", len(events), w.Body.String())
+	}
+	if events[4].data != "[DONE]" {
+		t.Errorf("expected final [DONE], got %q", events[4].data)
+	}
+
+	var content strings.Builder
+	for i, event := range events[:4] {
+		var chunk ChatCompletion
+		if err := json.Unmarshal([]byte(event.data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", event.data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("unexpected object %q", chunk.Object)
+		}
+		choice := chunk.Choices[0]
+		if i == 0 && choice.Delta.Role != "assistant" {
+			t.Errorf("expected first chunk to carry the role, got %+v", choice.Delta)
+		}
+		if i == 3 && (choice.FinishReason == nil || *choice.FinishReason != "stop") {
+			t.Errorf("expected last chunk to finish, got %+v", choice)
+		}
+		content.WriteString(choice.Delta.Content)
+	}
+	if content.String() != "Hello, world!" {
+		t.Errorf("unexpected content %q", content.String())
+	}
+}
+
+func TestHandleOpenAIModels(t *testing.T) {
+	handler := New(map[string]provider.Generator{
+		"claude": &mockGenerator{},
+		"gemini": &mockGenerator{},
+	}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithModels(map[string][]string{"claude": {"sonnet"}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	w := httptest.NewRecorder()
+	handler.HandleOpenAIModels(w, req)
+
+	var resp struct {
+		Object string        `json:"object"`
+		Data   []OpenAIModel `json:"data"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	var ids []string
+	for _, model := range resp.Data {
+		ids = append(ids, model.ID)
+	}
+	if resp.Object != "list" || strings.Join(ids, ",") != "claude,claude/sonnet,gemini" {
+		t.Errorf("unexpected models: %+v", resp)
+	}
+}
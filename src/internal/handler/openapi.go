@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
+	"slices"
+	"sort"
 )
 
 // OpenAPISpec is the OpenAPI v3 specification for the Local AI Tool Proxy API.
@@ -48,6 +51,27 @@ const OpenAPISpec = `{
                     "user": "Explain quantum computing in simple terms",
                     "provider": "gemini"
                   }
+                },
+                "streaming": {
+                  "summary": "Streaming prompt",
+                  "value": {
+                    "user": "Write a haiku about the sea",
+                    "stream": true
+                  }
+                },
+                "structured_output": {
+                  "summary": "Prompt with a JSON Schema for the response",
+                  "value": {
+                    "user": "Extract the person from: Ada Lovelace, born 1815 in London.",
+                    "schema": {
+                      "type": "object",
+                      "properties": {
+                        "name": {"type": "string"},
+                        "born": {"type": "integer"}
+                      },
+                      "required": ["name", "born"]
+                    }
+                  }
                 }
               }
             }
@@ -56,14 +80,30 @@ const OpenAPISpec = `{
         "responses": {
           "200": {
             "description": "Successfully generated response",
+            "headers": {
+              "X-Queue-Position": {
+                "description": "Position at which the request entered the provider's queue, if it had to wait",
+                "schema": {
+                  "type": "integer"
+                }
+              }
+            },
             "content": {
               "application/json": {
                 "schema": {
                   "$ref": "#/components/schemas/Response"
                 },
                 "example": {
-                  "response": "The capital of France is Paris."
+                  "response": "The capital of France is Paris.",
+                  "provider": "claude"
                 }
+              },
+              "text/event-stream": {
+                "schema": {
+                  "type": "string",
+                  "description": "Returned when streaming is requested via 'stream: true' or 'Accept: text/event-stream'. Emits 'queue' events with a QueueStatus payload while the request waits for a free slot, 'delta' events with a StreamDelta payload, followed by a single 'done' event with a Response payload or an 'error' event with an ErrorResponse payload."
+                },
+                "example": "event: delta\ndata: {\"text\":\"The capital\"}\n\nevent: delta\ndata: {\"text\":\" of France is Paris.\"}\n\nevent: done\ndata: {\"response\":\"The capital of France is Paris.\",\"provider\":\"claude\"}\n\n"
               }
             }
           },
@@ -92,11 +132,62 @@ const OpenAPISpec = `{
                     "value": {
                       "error": "Unknown provider: invalid"
                     }
+                  },
+                  "model_not_allowed": {
+                    "summary": "Model not allowed",
+                    "value": {
+                      "error": "Model haiku is not allowed for provider claude"
+                    }
+                  },
+                  "timeout_too_long": {
+                    "summary": "Timeout exceeds the provider's timeout",
+                    "value": {
+                      "error": "The 'timeout_ms' field exceeds the maximum of 300000 for provider claude"
+                    }
+                  },
+                  "unknown_system_prompt": {
+                    "summary": "Unknown system prompt",
+                    "value": {
+                      "error": "Unknown system prompt: pirate"
+                    }
+                  },
+                  "missing_variables": {
+                    "summary": "Variables required by the system prompt are missing",
+                    "value": {
+                      "error": "Missing system prompt variables: locale, product"
+                    }
+                  },
+                  "system_not_allowed": {
+                    "summary": "The override policy does not allow the 'system' field",
+                    "value": {
+                      "error": "The 'system' field is not allowed"
+                    }
+                  },
+                  "invalid_schema": {
+                    "summary": "The 'schema' field is not a supported JSON Schema",
+                    "value": {
+                      "error": "Invalid 'schema' field: #/type: unknown type \"text\""
+                    }
                   }
                 }
               }
             }
           },
+          "401": {
+            "description": "Provider authentication required - the CLI is not logged in",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Provider authentication required",
+                  "code": "auth_required",
+                  "retryable": false
+                }
+              }
+            }
+          },
           "405": {
             "description": "Method not allowed - only POST and OPTIONS are supported",
             "content": {
@@ -110,15 +201,101 @@ const OpenAPISpec = `{
               }
             }
           },
+          "422": {
+            "description": "Unprocessable entity - the response still does not match the request's schema after all repair attempts",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Response does not match the schema",
+                  "code": "invalid_output",
+                  "retryable": true,
+                  "validation_errors": ["$: missing required property \"born\""]
+                }
+              }
+            }
+          },
+          "429": {
+            "description": "Too many requests - the provider's queue is full (code queue_full) or the provider is rate limited (code rate_limited)",
+            "headers": {
+              "Retry-After": {
+                "description": "Suggested number of seconds to wait before retrying, if the queue is full",
+                "schema": {
+                  "type": "integer"
+                }
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Too many requests for provider claude",
+                  "code": "queue_full",
+                  "retryable": true
+                }
+              }
+            }
+          },
           "500": {
-            "description": "Internal server error - AI CLI execution failed",
+            "description": "Internal server error - AI CLI execution failed for an unclassified reason",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Failed to generate response",
+                  "code": "cli_failure",
+                  "retryable": false
+                }
+              }
+            }
+          },
+          "502": {
+            "description": "Bad gateway - the provider's output could not be parsed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Failed to parse provider response",
+                  "code": "parse_failure",
+                  "retryable": true
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "Service unavailable - the provider's quota is exhausted (code quota_exhausted) or its CLI is not installed (code binary_not_found)",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Provider quota exhausted",
+                  "code": "quota_exhausted",
+                  "retryable": false
+                }
+              }
+            }
+          },
+          "504": {
+            "description": "Gateway timeout - the CLI run exceeded the provider's timeout or the request's timeout_ms and was killed",
             "content": {
               "application/json": {
                 "schema": {
                   "$ref": "#/components/schemas/ErrorResponse"
                 },
                 "example": {
-                  "error": "Failed to generate response"
+                  "error": "Provider timed out",
+                  "code": "timeout",
+                  "retryable": true
                 }
               }
             }
@@ -136,18 +313,44 @@ const OpenAPISpec = `{
         }
       }
     },
-    "/openapi.json": {
-      "get": {
-        "summary": "OpenAPI Specification",
-        "description": "Returns the OpenAPI v3 specification for this API.",
-        "operationId": "getOpenAPISpec",
+    "/prompt/batch": {
+      "post": {
+        "summary": "Batch Prompts",
+        "description": "Generates responses for several prompts, running a bounded number of them concurrently. Each item is a request as accepted by POST /prompt; the 'stream' field is ignored. A failing item does not fail the batch: results are returned in the order of the requests, each with the status its request would have had on its own.",
+        "operationId": "batchPrompts",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/BatchRequest"
+              },
+              "example": {
+                "requests": [
+                  {"user": "What is the capital of France?"},
+                  {"user": "What is the capital of Spain?", "provider": "gemini"}
+                ]
+              }
+            }
+          }
+        },
         "responses": {
           "200": {
-            "description": "OpenAPI v3 specification",
+            "description": "Results of all items",
             "content": {
               "application/json": {
                 "schema": {
-                  "type": "object"
+                  "$ref": "#/components/schemas/BatchResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Bad request - invalid JSON, or no or more than 100 items",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
                 }
               }
             }
@@ -155,27 +358,44 @@ const OpenAPISpec = `{
         }
       }
     },
-    "/providers": {
-      "get": {
-        "summary": "List Providers",
-        "description": "Returns the list of available AI providers with their descriptions.",
-        "operationId": "listProviders",
+    "/compare": {
+      "post": {
+        "summary": "Compare Providers",
+        "description": "Sends one prompt to several providers concurrently and returns their answers side by side. Without 'providers', all registered providers are compared. Each provider runs with its own timeout and without fallback, so a slow or failing provider does not delay or affect the others; its failure is reported in its result.",
+        "operationId": "compareProviders",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CompareRequest"
+              },
+              "example": {
+                "user": "Summarize the plot of Hamlet in one sentence.",
+                "providers": ["claude", "gemini"],
+                "models": {"gemini": "gemini-2.5-flash"},
+                "timeout_ms": 60000
+              }
+            }
+          }
+        },
         "responses": {
           "200": {
-            "description": "List of available providers",
+            "description": "Results of all providers",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/ProvidersResponse"
-                },
-                "example": {
-                  "providers": [
-                    {"name": "claude", "description": "Anthropic Claude CLI"},
-                    {"name": "gemini", "description": "Google Gemini CLI"},
-                    {"name": "codex", "description": "OpenAI Codex CLI"},
-                    {"name": "continue", "description": "Continue CLI"},
-                    {"name": "opencode", "description": "OpenCode CLI"}
-                  ]
+                  "$ref": "#/components/schemas/CompareResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Bad request - invalid JSON, missing user field, unknown or duplicate provider, or invalid model or timeout",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
                 }
               }
             }
@@ -183,65 +403,1575 @@ const OpenAPISpec = `{
         }
       }
     },
-    "/health": {
-      "get": {
-        "summary": "Health Check",
-        "description": "Returns HTTP 200 if the proxy is running. Used by tools to check if the proxy is available.",
-        "operationId": "healthCheck",
+    "/sessions": {
+      "post": {
+        "summary": "Create Session",
+        "description": "Create a multi-turn conversation session bound to a provider. Sessions idle for longer than the session TTL are removed.",
+        "operationId": "createSession",
+        "requestBody": {
+          "required": false,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateSessionRequest"
+              },
+              "example": {
+                "provider": "claude"
+              }
+            }
+          }
+        },
         "responses": {
-          "200": {
-            "description": "Proxy is running"
+          "201": {
+            "description": "Session created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Session"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Bad request - invalid JSON or unknown provider",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
           }
         }
       }
-    }
-  },
-  "components": {
-    "schemas": {
-      "Request": {
-        "type": "object",
-        "required": ["user"],
-        "properties": {
-          "user": {
-            "type": "string",
-            "description": "The user prompt to send to the AI provider",
-            "example": "What is the capital of France?"
+    },
+    "/sessions/{id}": {
+      "parameters": [
+        {
+          "name": "id",
+          "in": "path",
+          "required": true,
+          "schema": {
+            "type": "string"
           },
-          "provider": {
-            "type": "string",
-            "description": "AI provider to use for response generation. If omitted, uses the default configured provider.",
-            "enum": ["claude", "gemini", "codex", "continue", "opencode"],
-            "example": "claude"
-          }
+          "description": "Session ID"
         }
-      },
-      "Response": {
-        "type": "object",
-        "properties": {
-          "response": {
-            "type": "string",
-            "description": "Generated response from the AI provider",
-            "example": "The capital of France is Paris."
+      ],
+      "get": {
+        "summary": "Get Session",
+        "description": "Returns the session including its transcript.",
+        "operationId": "getSession",
+        "responses": {
+          "200": {
+            "description": "Session with transcript",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Session"
+                }
+              }
+            }
           },
-          "error": {
-            "type": "string",
-            "description": "Error message if the request failed"
+          "404": {
+            "description": "Session not found",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
           }
         }
       },
-      "ErrorResponse": {
-        "type": "object",
-        "properties": {
-          "error": {
-            "type": "string",
-            "description": "Error message describing what went wrong",
-            "example": "Failed to generate response"
+      "delete": {
+        "summary": "Delete Session",
+        "description": "Deletes the session and its transcript.",
+        "operationId": "deleteSession",
+        "responses": {
+          "204": {
+            "description": "Session deleted"
+          },
+          "404": {
+            "description": "Session not found",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
           }
         }
-      },
-      "ProvidersResponse": {
-        "type": "object",
-        "properties": {
+      }
+    },
+    "/sessions/{id}/messages": {
+      "parameters": [
+        {
+          "name": "id",
+          "in": "path",
+          "required": true,
+          "schema": {
+            "type": "string"
+          },
+          "description": "Session ID"
+        }
+      ],
+      "post": {
+        "summary": "Send Session Message",
+        "description": "Sends the next user turn of a session and returns the assistant reply. Claude, Codex and OpenCode resume their own conversation natively; other providers receive the transcript replayed into the prompt.",
+        "operationId": "sendSessionMessage",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/SessionMessageRequest"
+              },
+              "example": {
+                "user": "And what is its population?"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Successfully generated reply",
+            "headers": {
+              "X-Queue-Position": {
+                "description": "Position at which the turn entered the provider's queue, if it had to wait",
+                "schema": {
+                  "type": "integer"
+                }
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Response"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Bad request - invalid JSON or missing fields",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Provider authentication required - the CLI is not logged in",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Session not found",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "429": {
+            "description": "Too many requests - the provider's queue is full (code queue_full) or the provider is rate limited (code rate_limited)",
+            "headers": {
+              "Retry-After": {
+                "description": "Suggested number of seconds to wait before retrying, if the queue is full",
+                "schema": {
+                  "type": "integer"
+                }
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "500": {
+            "description": "Internal server error - AI CLI execution failed for an unclassified reason",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "502": {
+            "description": "Bad gateway - the provider's output could not be parsed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "Service unavailable - the provider's quota is exhausted or its CLI is not installed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "504": {
+            "description": "Gateway timeout - the CLI run exceeded the provider's timeout or the request's timeout_ms and was killed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "OpenAPI Specification",
+        "description": "Returns the OpenAPI v3 specification for this API.",
+        "operationId": "getOpenAPISpec",
+        "responses": {
+          "200": {
+            "description": "OpenAPI v3 specification",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/jobs": {
+      "post": {
+        "summary": "Create Job",
+        "description": "Generates a response in the background, for runs that outlive client or server timeouts. Returns the queued job immediately; poll GET /jobs/{id} for its status and result. The 'stream' field is ignored.",
+        "operationId": "createJob",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/Request"
+              },
+              "example": {
+                "user": "Refactor the billing module",
+                "provider": "codex"
+              }
+            }
+          }
+        },
+        "responses": {
+          "202": {
+            "description": "Job created",
+            "headers": {
+              "Location": {
+                "description": "URL of the job",
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Bad request - invalid JSON, missing fields or unknown provider",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/jobs/{id}": {
+      "parameters": [
+        {
+          "name": "id",
+          "in": "path",
+          "required": true,
+          "schema": {
+            "type": "string"
+          },
+          "description": "Job ID"
+        }
+      ],
+      "get": {
+        "summary": "Get Job",
+        "description": "Returns the status, timing and, once finished, the result of a job. Finished jobs are kept for LOCAL_AI_TOOL_PROXY_JOB_RETENTION.",
+        "operationId": "getJob",
+        "responses": {
+          "200": {
+            "description": "Job",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Job not found or expired",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "summary": "Cancel or Delete Job",
+        "description": "Cancels a queued or running job, stopping its CLI, and returns the cancelled job. Deletes a finished job.",
+        "operationId": "deleteJob",
+        "responses": {
+          "200": {
+            "description": "Job cancelled",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Job"
+                }
+              }
+            }
+          },
+          "204": {
+            "description": "Finished job deleted"
+          },
+          "404": {
+            "description": "Job not found or expired",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/ws": {
+      "get": {
+        "summary": "WebSocket",
+        "description": "Opens a WebSocket for interactive use. Messages are JSON text frames. The client sends 'prompt' messages with a client-chosen 'id' and the fields of POST /prompt, 'cancel' messages with the 'id' of a running request, and 'ping' messages. The server sends 'queue' messages with the queue position, 'delta' messages with response text, a single 'done' or 'error' message per request, and 'pong' messages. Requests run concurrently and are told apart by their 'id'; closing the socket cancels all of them. Connections are pinged every 30 seconds and closed after a minute without any frame from the client. Browsers must connect from the allowed origin.",
+        "operationId": "openWebSocket",
+        "responses": {
+          "101": {
+            "description": "Switching to the WebSocket protocol"
+          },
+          "400": {
+            "description": "Bad request - not a valid WebSocket handshake",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "403": {
+            "description": "The request's origin is not allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mcp": {
+      "post": {
+        "summary": "MCP",
+        "description": "Model Context Protocol server over the streamable HTTP transport. Each request carries a single JSON-RPC message and is answered with a JSON response; notifications are acknowledged with 202. Each provider is offered as an 'ask_<provider>' tool with the arguments 'prompt', 'model' and 'timeout_ms' that runs on that provider only, without fallback, and the system prompt as the 'system_prompt' prompt. Requests from other origins than the allowed origin are rejected.",
+        "operationId": "mcp",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "description": "JSON-RPC 2.0 request or notification"
+              },
+              "example": {
+                "jsonrpc": "2.0",
+                "id": 1,
+                "method": "tools/call",
+                "params": {
+                  "name": "ask_gemini",
+                  "arguments": {"prompt": "Review this function for bugs: ..."}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "JSON-RPC response",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          },
+          "202": {
+            "description": "Notification accepted"
+          },
+          "403": {
+            "description": "The request's origin is not allowed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/providers": {
+      "get": {
+        "summary": "List Providers",
+        "description": "Returns the list of available AI providers with their descriptions and the installation status of their CLI, which is checked at startup and periodically.",
+        "operationId": "listProviders",
+        "responses": {
+          "200": {
+            "description": "List of available providers",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProvidersResponse"
+                },
+                "example": {
+                  "providers": [
+                    {"name": "claude", "description": "Anthropic Claude CLI", "binary": "claude", "installed": true, "version": "2.0.1 (Claude Code)", "authenticated": true, "last_checked": "2025-01-01T12:00:00Z"},
+                    {"name": "gemini", "description": "Google Gemini CLI"},
+                    {"name": "codex", "description": "OpenAI Codex CLI"},
+                    {"name": "continue", "description": "Continue CLI"},
+                    {"name": "opencode", "description": "OpenCode CLI"}
+                  ]
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/models": {
+      "get": {
+        "summary": "List Models",
+        "description": "Returns the models clients may select for each provider, as configured with LOCAL_AI_TOOL_PROXY_MODELS.",
+        "operationId": "listModels",
+        "responses": {
+          "200": {
+            "description": "Selectable models per provider",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ModelsResponse"
+                },
+                "example": {
+                  "models": [
+                    {"provider": "claude", "models": ["sonnet", "opus"]},
+                    {"provider": "gemini", "models": ["gemini-2.5-pro", "gemini-2.5-flash"]}
+                  ]
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/prompts": {
+      "get": {
+        "summary": "List System Prompts",
+        "description": "Returns the named system prompts of the directory configured with LOCAL_AI_TOOL_PROXY_PROMPTS_DIR, with the description from their front-matter. Requests select one with the 'system_prompt' field.",
+        "operationId": "listPrompts",
+        "responses": {
+          "200": {
+            "description": "Named system prompts",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PromptsResponse"
+                },
+                "example": {
+                  "prompts": [
+                    {"name": "support", "description": "Friendly customer support agent", "variables": ["locale", "product"]},
+                    {"name": "terse"}
+                  ]
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/v1/chat/completions": {
+      "post": {
+        "summary": "OpenAI Chat Completions",
+        "description": "OpenAI-compatible chat completions. The 'model' field selects the provider, optionally followed by one of its models (e.g. 'claude' or 'claude/sonnet'). System and developer messages are combined with the configured system prompt as permitted by the override policy, or rejected with status 403 if overrides are disabled; earlier user and assistant messages are replayed as a transcript. With 'stream: true' the response is a stream of chat.completion.chunk events terminated by 'data: [DONE]'. Errors use the OpenAI error format.",
+        "operationId": "createChatCompletion",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ChatCompletionRequest"
+              },
+              "example": {
+                "model": "claude",
+                "messages": [
+                  {"role": "system", "content": "Answer briefly."},
+                  {"role": "user", "content": "What is the capital of France?"}
+                ]
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Chat completion, or a stream of chunks if 'stream' is true",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ChatCompletion"
+                }
+              },
+              "text/event-stream": {
+                "schema": {
+                  "type": "string",
+                  "description": "'data:' lines with ChatCompletion chunks, followed by 'data: [DONE]'"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid request",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OpenAIError"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Unknown model",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OpenAIError"
+                }
+              }
+            }
+          },
+          "429": {
+            "description": "Provider queue is full or the provider is rate limited",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OpenAIError"
+                }
+              }
+            }
+          },
+          "500": {
+            "description": "Provider failure; see /prompt for the possible 401, 502, 503 and 504 statuses",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OpenAIError"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/v1/models": {
+      "get": {
+        "summary": "OpenAI Models",
+        "description": "Lists the model IDs accepted by /v1/chat/completions in the OpenAI format: every provider, and every allowed model prefixed with its provider.",
+        "operationId": "listOpenAIModels",
+        "responses": {
+          "200": {
+            "description": "Model list",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OpenAIModelList"
+                },
+                "example": {
+                  "object": "list",
+                  "data": [
+                    {"id": "claude", "object": "model", "created": 0, "owned_by": "local-ai-tool-proxy"},
+                    {"id": "claude/sonnet", "object": "model", "created": 0, "owned_by": "local-ai-tool-proxy"}
+                  ]
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/v1/messages": {
+      "post": {
+        "summary": "Anthropic Messages",
+        "description": "Anthropic Messages API-compatible endpoint. The 'model' field selects the provider, optionally followed by one of its models (e.g. 'claude' or 'claude/sonnet'); Anthropic model names such as 'claude-sonnet-4-5' run on the claude provider. The 'system' field is combined with the configured system prompt as permitted by the override policy, or rejected with status 403 if overrides are disabled; earlier messages are replayed as a transcript. 'max_tokens' is accepted but not enforced. With 'stream: true' the response is a stream of message_start, content_block_start, ping, content_block_delta, content_block_stop, message_delta and message_stop events. Errors use the Anthropic error format.",
+        "operationId": "createMessage",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/MessagesRequest"
+              },
+              "example": {
+                "model": "claude",
+                "max_tokens": 1024,
+                "system": "Answer briefly.",
+                "messages": [
+                  {"role": "user", "content": "What is the capital of France?"}
+                ]
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Message, or a stream of events if 'stream' is true",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MessagesResponse"
+                }
+              },
+              "text/event-stream": {
+                "schema": {
+                  "type": "string",
+                  "description": "Messages API stream events; failures are reported with an 'error' event"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid request",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AnthropicError"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Unknown provider, e.g. 'mistral/large'",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AnthropicError"
+                }
+              }
+            }
+          },
+          "429": {
+            "description": "Provider queue is full or the provider is rate limited",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AnthropicError"
+                }
+              }
+            }
+          },
+          "500": {
+            "description": "Provider failure; see /prompt for the possible 401, 502, 503 and 504 statuses",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AnthropicError"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/v1beta/models/{model}:generateContent": {
+      "post": {
+        "summary": "Gemini Generate Content",
+        "description": "Gemini REST-compatible content generation. The system instruction is combined with the configured system prompt as permitted by the override policy, or rejected with status 403 if overrides are disabled; earlier contents are replayed as a transcript. Errors use the Google API error format.",
+        "operationId": "geminiGenerateContent",
+        "parameters": [
+          {
+            "name": "model",
+            "in": "path",
+            "required": true,
+            "description": "Provider, optionally followed by '/' and a model, e.g. 'claude' or 'claude/sonnet'. Other IDs, such as Gemini model names like 'gemini-2.5-flash', run on the gemini provider (or the default provider if gemini is not registered), with the ID as model if it is allowed and with the default model otherwise.",
+            "schema": {"type": "string"}
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GenerateContentRequest"
+              },
+              "example": {
+                "systemInstruction": {"parts": [{"text": "Answer briefly."}]},
+                "contents": [{"role": "user", "parts": [{"text": "What is the capital of France?"}]}]
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Generated content",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GenerateContentResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid request",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Unknown provider or method",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          },
+          "429": {
+            "description": "Provider queue is full or the provider is rate limited",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          },
+          "500": {
+            "description": "Provider failure; see /prompt for the possible 401, 502, 503 and 504 statuses",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/v1beta/models/{model}:streamGenerateContent": {
+      "post": {
+        "summary": "Gemini Stream Generate Content",
+        "description": "Gemini REST-compatible streaming content generation. The system instruction is combined with the configured system prompt as permitted by the override policy, or rejected with status 403 if overrides are disabled; earlier contents are replayed as a transcript. Errors use the Google API error format.",
+        "operationId": "geminiStreamGenerateContent",
+        "parameters": [
+          {
+            "name": "model",
+            "in": "path",
+            "required": true,
+            "description": "Provider, optionally followed by '/' and a model, e.g. 'claude' or 'claude/sonnet'. Other IDs, such as Gemini model names like 'gemini-2.5-flash', run on the gemini provider (or the default provider if gemini is not registered), with the ID as model if it is allowed and with the default model otherwise.",
+            "schema": {"type": "string"}
+          },
+          {
+            "name": "alt",
+            "in": "query",
+            "description": "'sse' streams server-sent events instead of a JSON array",
+            "schema": {"type": "string", "enum": ["sse"]}
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GenerateContentRequest"
+              },
+              "example": {
+                "systemInstruction": {"parts": [{"text": "Answer briefly."}]},
+                "contents": [{"role": "user", "parts": [{"text": "What is the capital of France?"}]}]
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Stream of chunks, as a JSON array written incrementally or as server-sent events if 'alt' is 'sse'. The last chunk carries the finish reason.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/GenerateContentResponse"
+                  }
+                }
+              },
+              "text/event-stream": {
+                "schema": {
+                  "type": "string",
+                  "description": "'data:' lines with GenerateContentResponse chunks"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Invalid request",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Unknown provider or method",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          },
+          "429": {
+            "description": "Provider queue is full or the provider is rate limited",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          },
+          "500": {
+            "description": "Provider failure; see /prompt for the possible 401, 502, 503 and 504 statuses",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GeminiError"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/api/generate": {
+      "post": {
+        "summary": "Ollama Generate",
+        "description": "Ollama-compatible completion, only served if LOCAL_AI_TOOL_PROXY_OLLAMA_API is true. The 'model' field selects the provider as listed by /api/tags. Unless 'stream' is false, the response is streamed as newline-delimited JSON whose last line has 'done': true.",
+        "operationId": "ollamaGenerate",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["prompt"],
+                "properties": {
+                  "model": {"type": "string", "example": "claude"},
+                  "prompt": {"type": "string", "example": "What is the capital of France?"},
+                  "system": {"type": "string", "description": "Appended to the configured system prompt"},
+                  "stream": {"type": "boolean", "default": true}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Response, or newline-delimited responses if streaming",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OllamaResponse"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "$ref": "#/components/schemas/OllamaResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/OllamaError"
+          },
+          "404": {
+            "$ref": "#/components/responses/OllamaError"
+          },
+          "429": {
+            "$ref": "#/components/responses/OllamaError"
+          },
+          "500": {
+            "$ref": "#/components/responses/OllamaError"
+          }
+        }
+      }
+    },
+    "/api/chat": {
+      "post": {
+        "summary": "Ollama Chat",
+        "description": "Ollama-compatible chat, only served if LOCAL_AI_TOOL_PROXY_OLLAMA_API is true. System messages are combined with the configured system prompt as permitted by the override policy, or rejected with status 403 if overrides are disabled; earlier user and assistant messages are replayed as a transcript. Unless 'stream' is false, the response is streamed as newline-delimited JSON whose last line has 'done': true.",
+        "operationId": "ollamaChat",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["messages"],
+                "properties": {
+                  "model": {"type": "string", "example": "claude"},
+                  "messages": {
+                    "type": "array",
+                    "items": {
+                      "type": "object",
+                      "properties": {
+                        "role": {"type": "string", "enum": ["system", "user", "assistant"]},
+                        "content": {"type": "string"}
+                      }
+                    }
+                  },
+                  "stream": {"type": "boolean", "default": true}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Response, or newline-delimited responses if streaming. The text is in 'message.content' instead of 'response'.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OllamaResponse"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "$ref": "#/components/schemas/OllamaResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/OllamaError"
+          },
+          "404": {
+            "$ref": "#/components/responses/OllamaError"
+          },
+          "429": {
+            "$ref": "#/components/responses/OllamaError"
+          },
+          "500": {
+            "$ref": "#/components/responses/OllamaError"
+          }
+        }
+      }
+    },
+    "/api/tags": {
+      "get": {
+        "summary": "Ollama Tags",
+        "description": "Lists every provider and allowed model as an Ollama model, only served if LOCAL_AI_TOOL_PROXY_OLLAMA_API is true.",
+        "operationId": "ollamaTags",
+        "responses": {
+          "200": {
+            "description": "Model list",
+            "content": {
+              "application/json": {
+                "example": {
+                  "models": [
+                    {"name": "claude", "model": "claude", "modified_at": "0001-01-01T00:00:00Z", "size": 0, "digest": "", "details": {"family": "claude"}}
+                  ]
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/health": {
+      "get": {
+        "summary": "Health Check",
+        "description": "Returns HTTP 200 if the proxy is running. Used by tools to check if the proxy is available.",
+        "operationId": "healthCheck",
+        "responses": {
+          "200": {
+            "description": "Proxy is running"
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Request": {
+        "type": "object",
+        "required": ["user"],
+        "properties": {
+          "user": {
+            "type": "string",
+            "description": "The user prompt to send to the AI provider",
+            "example": "What is the capital of France?"
+          },
+          "provider": {
+            "type": "string",
+            "description": "AI provider to use for response generation. If omitted, uses the default configured provider.",
+            "enum": ["claude", "gemini", "codex", "continue", "opencode"],
+            "example": "claude"
+          },
+          "model": {
+            "type": "string",
+            "description": "Model to use, passed to the CLI's model flag. Must be listed for the provider in GET /models. If omitted, uses the CLI's default model.",
+            "example": "sonnet"
+          },
+          "stream": {
+            "type": "boolean",
+            "description": "Stream the response as server-sent events. Equivalent to sending 'Accept: text/event-stream'.",
+            "default": false
+          },
+          "timeout_ms": {
+            "type": "integer",
+            "minimum": 0,
+            "description": "Timeout of the CLI run in milliseconds. Must not exceed the provider's configured timeout, which applies if omitted.",
+            "example": 30000
+          },
+          "system_prompt": {
+            "type": "string",
+            "description": "Name of a system prompt listed in GET /prompts to use instead of the default system prompt",
+            "example": "support"
+          },
+          "variables": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            },
+            "description": "Variables rendered into the system prompt template as {{.Vars.name}}. All variables the system prompt requires must be present.",
+            "example": {"product": "Acme", "locale": "de-DE"}
+          },
+          "system": {
+            "type": "string",
+            "description": "System prompt of the request. Depending on the override policy configured for the request's API key or origin, it is rejected (the default), appended to the configured system prompt, or replaces it.",
+            "example": "Answer in German."
+          },
+          "schema": {
+            "type": "object",
+            "description": "JSON Schema the response must match. Claude receives it natively, other providers are instructed to follow it. Responses that do not match are sent back to the provider for repair, up to LOCAL_AI_TOOL_PROXY_SCHEMA_RETRIES times, before the request fails with status 422. The validated JSON is returned as 'output'; streamed requests receive it as a single delta. Supports type, enum, const, properties, required, additionalProperties, items, minItems, maxItems, minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not and local $ref; schemas using other assertion keywords, such as uniqueItems or patternProperties, are rejected with status 400.",
+            "example": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}
+          },
+          "callback_url": {
+            "type": "string",
+            "format": "uri",
+            "description": "POST /jobs only: http or https URL that receives the finished job, signed with the configured webhook secret. Rejected if no secret is configured, and by all other endpoints.",
+            "example": "https://automation.example.com/hooks/proxy"
+          }
+        }
+      },
+      "Response": {
+        "type": "object",
+        "properties": {
+          "response": {
+            "type": "string",
+            "description": "Generated response from the AI provider",
+            "example": "The capital of France is Paris."
+          },
+          "output": {
+            "description": "The validated JSON response of a request with a schema, which then has no 'response'",
+            "example": {"name": "Ada Lovelace", "born": 1815}
+          },
+          "provider": {
+            "type": "string",
+            "description": "Provider that generated the response, which differs from the requested provider if a fallback answered",
+            "example": "gemini"
+          },
+          "failed_providers": {
+            "type": "array",
+            "description": "Providers of the fallback chain that failed before the response was generated",
+            "items": {
+              "$ref": "#/components/schemas/ProviderFailure"
+            }
+          },
+          "error": {
+            "type": "string",
+            "description": "Error message if the request failed"
+          }
+        }
+      },
+      "ProviderFailure": {
+        "type": "object",
+        "properties": {
+          "provider": {
+            "type": "string",
+            "description": "Provider that failed",
+            "example": "claude"
+          },
+          "error": {
+            "type": "string",
+            "description": "Why the provider failed",
+            "example": "Provider rate limit reached"
+          },
+          "code": {
+            "type": "string",
+            "description": "Machine-readable error code",
+            "example": "rate_limited"
+          }
+        }
+      },
+      "QueueStatus": {
+        "type": "object",
+        "properties": {
+          "position": {
+            "type": "integer",
+            "description": "1-based position of the request in the provider's queue",
+            "example": 2
+          }
+        }
+      },
+      "QueueStats": {
+        "type": "object",
+        "properties": {
+          "active": {
+            "type": "integer",
+            "description": "Number of CLI processes currently running"
+          },
+          "queued": {
+            "type": "integer",
+            "description": "Number of requests waiting for a free slot"
+          },
+          "max_concurrency": {
+            "type": "integer",
+            "description": "Maximum number of concurrent CLI processes"
+          },
+          "max_queue": {
+            "type": "integer",
+            "description": "Maximum number of waiting requests"
+          }
+        }
+      },
+      "StreamDelta": {
+        "type": "object",
+        "properties": {
+          "text": {
+            "type": "string",
+            "description": "Incremental piece of the generated response",
+            "example": "The capital"
+          }
+        }
+      },
+      "CreateSessionRequest": {
+        "type": "object",
+        "properties": {
+          "provider": {
+            "type": "string",
+            "description": "AI provider for the whole session. If omitted, uses the default configured provider.",
+            "example": "claude"
+          },
+          "model": {
+            "type": "string",
+            "description": "Model for the whole session. Must be listed for the provider in GET /models.",
+            "example": "sonnet"
+          }
+        }
+      },
+      "SessionMessageRequest": {
+        "type": "object",
+        "required": ["user"],
+        "properties": {
+          "user": {
+            "type": "string",
+            "description": "The next user message",
+            "example": "And what is its population?"
+          },
+          "timeout_ms": {
+            "type": "integer",
+            "minimum": 0,
+            "description": "Timeout of the CLI run in milliseconds. Must not exceed the provider's configured timeout, which applies if omitted.",
+            "example": 30000
+          },
+          "system_prompt": {
+            "type": "string",
+            "description": "Name of a system prompt from the prompts directory to use for this turn instead of the default one",
+            "example": "support"
+          },
+          "variables": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            },
+            "description": "Variables rendered into the system prompt template"
+          },
+          "system": {
+            "type": "string",
+            "description": "System prompt of this turn. Depending on the override policy configured for the request's API key or origin, it is rejected (the default), appended to the configured system prompt, or replaces it.",
+            "example": "Answer in German."
+          }
+        }
+      },
+      "SessionMessage": {
+        "type": "object",
+        "properties": {
+          "role": {
+            "type": "string",
+            "enum": ["user", "assistant"]
+          },
+          "content": {
+            "type": "string"
+          },
+          "created_at": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "Session": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Session ID",
+            "example": "3f2a9c0e5b7d4e1f8a6b2c4d9e0f1a2b"
+          },
+          "provider": {
+            "type": "string",
+            "description": "AI provider used for the session",
+            "example": "claude"
+          },
+          "model": {
+            "type": "string",
+            "description": "Model used for the session, if one was selected",
+            "example": "sonnet"
+          },
+          "messages": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/SessionMessage"
+            },
+            "description": "Transcript of the session"
+          },
+          "created_at": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "updated_at": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "BatchRequest": {
+        "type": "object",
+        "required": ["requests"],
+        "properties": {
+          "requests": {
+            "type": "array",
+            "minItems": 1,
+            "maxItems": 100,
+            "items": {
+              "$ref": "#/components/schemas/Request"
+            }
+          }
+        }
+      },
+      "BatchResponse": {
+        "type": "object",
+        "properties": {
+          "results": {
+            "type": "array",
+            "description": "Results in the order of the requests",
+            "items": {
+              "$ref": "#/components/schemas/BatchResult"
+            }
+          }
+        }
+      },
+      "BatchResult": {
+        "description": "Response or error of a batch item, as POST /prompt would have returned it",
+        "allOf": [
+          {
+            "type": "object",
+            "properties": {
+              "index": {
+                "type": "integer",
+                "description": "Index of the item in the batch"
+              },
+              "status": {
+                "type": "integer",
+                "description": "HTTP status of the item",
+                "example": 200
+              }
+            }
+          },
+          {
+            "oneOf": [
+              {"$ref": "#/components/schemas/Response"},
+              {"$ref": "#/components/schemas/ErrorResponse"}
+            ]
+          }
+        ]
+      },
+      "CompareRequest": {
+        "type": "object",
+        "required": ["user"],
+        "additionalProperties": false,
+        "properties": {
+          "user": {
+            "type": "string",
+            "description": "The user prompt sent to every provider"
+          },
+          "providers": {
+            "type": "array",
+            "description": "Providers to compare, in the order of the results. Defaults to all registered providers, sorted by name.",
+            "items": {
+              "type": "string"
+            }
+          },
+          "models": {
+            "type": "object",
+            "description": "Model to use per provider, subject to the allowed models of GET /models",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "timeout_ms": {
+            "type": "integer",
+            "minimum": 0,
+            "description": "Shortens the CLI run timeout of each provider"
+          },
+          "system_prompt": {
+            "type": "string",
+            "description": "Name of a system prompt listed in GET /prompts to use for all providers"
+          },
+          "variables": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            },
+            "description": "Variables rendered into the system prompt template"
+          },
+          "system": {
+            "type": "string",
+            "description": "System prompt of the request, governed by the override policy as for POST /prompt"
+          },
+          "schema": {
+            "type": "object",
+            "description": "JSON Schema every provider's response must match, as for POST /prompt"
+          }
+        }
+      },
+      "CompareResponse": {
+        "type": "object",
+        "properties": {
+          "results": {
+            "type": "array",
+            "description": "Results in the order of the providers",
+            "items": {
+              "$ref": "#/components/schemas/CompareResult"
+            }
+          }
+        }
+      },
+      "CompareResult": {
+        "type": "object",
+        "properties": {
+          "provider": {
+            "type": "string",
+            "example": "claude"
+          },
+          "model": {
+            "type": "string",
+            "description": "The requested model, if any"
+          },
+          "status": {
+            "type": "integer",
+            "description": "HTTP status POST /prompt would have returned for this provider",
+            "example": 200
+          },
+          "response": {
+            "type": "string",
+            "description": "The provider's answer"
+          },
+          "output": {
+            "description": "The provider's validated JSON answer, if a schema was sent"
+          },
+          "error": {
+            "type": "string"
+          },
+          "code": {
+            "type": "string"
+          },
+          "retryable": {
+            "type": "boolean"
+          },
+          "validation_errors": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "latency_ms": {
+            "type": "integer",
+            "description": "Time the provider's CLI took, excluding the wait for a run slot"
+          },
+          "usage": {
+            "$ref": "#/components/schemas/Usage"
+          }
+        }
+      },
+      "Usage": {
+        "type": "object",
+        "description": "Token usage, for providers whose CLI reports it",
+        "properties": {
+          "input_tokens": {
+            "type": "integer",
+            "description": "Prompt tokens, including cached ones"
+          },
+          "output_tokens": {
+            "type": "integer"
+          }
+        }
+      },
+      "Job": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "example": "9b1f0c2e7a4d4c8f8e3a6b5d2c1f0e9a"
+          },
+          "status": {
+            "type": "string",
+            "enum": ["queued", "running", "succeeded", "failed", "cancelled"]
+          },
+          "provider": {
+            "type": "string",
+            "example": "codex"
+          },
+          "model": {
+            "type": "string"
+          },
+          "queue_position": {
+            "type": "integer",
+            "description": "Position in the provider's queue while the job waits for a free slot"
+          },
+          "created_at": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "started_at": {
+            "type": "string",
+            "format": "date-time",
+            "description": "When the CLI run started"
+          },
+          "finished_at": {
+            "type": "string",
+            "format": "date-time",
+            "description": "When the job succeeded, failed or was cancelled"
+          },
+          "result": {
+            "description": "Response of a succeeded job, or error of a failed job",
+            "oneOf": [
+              {"$ref": "#/components/schemas/Response"},
+              {"$ref": "#/components/schemas/ErrorResponse"}
+            ]
+          }
+        }
+      },
+      "ErrorResponse": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "string",
+            "description": "Error message describing what went wrong",
+            "example": "Failed to generate response"
+          },
+          "code": {
+            "type": "string",
+            "description": "Machine-readable error code of a failed generation",
+            "enum": ["rate_limited", "auth_required", "quota_exhausted", "timeout", "binary_not_found", "parse_failure", "cli_failure", "queue_full", "invalid_output"]
+          },
+          "retryable": {
+            "type": "boolean",
+            "description": "Whether sending the same request again later may succeed"
+          },
+          "validation_errors": {
+            "type": "array",
+            "description": "Why the last response does not match the request's schema (code invalid_output)",
+            "items": {
+              "type": "string"
+            }
+          },
+          "failed_providers": {
+            "type": "array",
+            "description": "Providers of the fallback chain that failed, if a fallback was tried",
+            "items": {
+              "$ref": "#/components/schemas/ProviderFailure"
+            }
+          }
+        }
+      },
+      "ProvidersResponse": {
+        "type": "object",
+        "properties": {
           "providers": {
             "type": "array",
             "items": {
@@ -251,6 +1981,71 @@ const OpenAPISpec = `{
           }
         }
       },
+      "ModelsResponse": {
+        "type": "object",
+        "properties": {
+          "models": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ProviderModels"
+            },
+            "description": "Selectable models per provider"
+          }
+        }
+      },
+      "PromptsResponse": {
+        "type": "object",
+        "properties": {
+          "prompts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/PromptInfo"
+            },
+            "description": "Named system prompts sorted by name"
+          }
+        }
+      },
+      "PromptInfo": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string",
+            "description": "Name of the prompt, its file name without extension",
+            "example": "support"
+          },
+          "description": {
+            "type": "string",
+            "description": "Description from the prompt file's front-matter",
+            "example": "Friendly customer support agent"
+          },
+          "variables": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "Variables requests must send in 'variables' when selecting the prompt",
+            "example": ["locale", "product"]
+          }
+        }
+      },
+      "ProviderModels": {
+        "type": "object",
+        "properties": {
+          "provider": {
+            "type": "string",
+            "description": "Technical name of the provider",
+            "example": "claude"
+          },
+          "models": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "Models that may be passed in the 'model' field",
+            "example": ["sonnet", "opus"]
+          }
+        }
+      },
       "ProviderInfo": {
         "type": "object",
         "properties": {
@@ -263,6 +2058,346 @@ const OpenAPISpec = `{
             "type": "string",
             "description": "Human-readable description of the provider",
             "example": "Anthropic Claude CLI"
+          },
+          "queue": {
+            "$ref": "#/components/schemas/QueueStats"
+          },
+          "binary": {
+            "type": "string",
+            "description": "CLI binary of the provider",
+            "example": "claude"
+          },
+          "installed": {
+            "type": "boolean",
+            "description": "Whether the CLI binary was found in PATH"
+          },
+          "version": {
+            "type": "string",
+            "description": "Output of the CLI's version command",
+            "example": "2.0.1 (Claude Code)"
+          },
+          "authenticated": {
+            "type": "boolean",
+            "nullable": true,
+            "description": "Whether the CLI is authenticated, or null if this cannot be determined"
+          },
+          "last_checked": {
+            "type": "string",
+            "format": "date-time",
+            "description": "When the CLI was last checked"
+          }
+        }
+      },
+      "ChatCompletionRequest": {
+        "type": "object",
+        "required": ["messages"],
+        "properties": {
+          "model": {
+            "type": "string",
+            "description": "Provider, optionally followed by '/' and a model. If omitted, uses the default provider.",
+            "example": "claude/sonnet"
+          },
+          "messages": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ChatMessage"
+            },
+            "description": "Conversation, ending with a user message"
+          },
+          "stream": {
+            "type": "boolean",
+            "default": false
+          }
+        }
+      },
+      "ChatMessage": {
+        "type": "object",
+        "required": ["role", "content"],
+        "properties": {
+          "role": {
+            "type": "string",
+            "enum": ["system", "developer", "user", "assistant"]
+          },
+          "content": {
+            "description": "Text, or an array of content parts of which the text parts are used",
+            "oneOf": [
+              {"type": "string"},
+              {
+                "type": "array",
+                "items": {
+                  "type": "object",
+                  "properties": {
+                    "type": {"type": "string", "example": "text"},
+                    "text": {"type": "string"}
+                  }
+                }
+              }
+            ]
+          }
+        }
+      },
+      "ChatCompletion": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "example": "chatcmpl-3f1c2a9b8d7e6f5a4b3c2d1e"
+          },
+          "object": {
+            "type": "string",
+            "enum": ["chat.completion", "chat.completion.chunk"]
+          },
+          "created": {
+            "type": "integer",
+            "description": "Unix timestamp"
+          },
+          "model": {
+            "type": "string",
+            "description": "Requested model, or the provider that answered if a fallback did"
+          },
+          "choices": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "index": {"type": "integer"},
+                "message": {"$ref": "#/components/schemas/ChatMessage"},
+                "delta": {
+                  "type": "object",
+                  "description": "Set instead of 'message' in chunks",
+                  "properties": {
+                    "role": {"type": "string"},
+                    "content": {"type": "string"}
+                  }
+                },
+                "finish_reason": {"type": "string", "nullable": true, "example": "stop"}
+              }
+            }
+          }
+        }
+      },
+      "OpenAIModelList": {
+        "type": "object",
+        "properties": {
+          "object": {
+            "type": "string",
+            "example": "list"
+          },
+          "data": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "id": {"type": "string", "example": "claude"},
+                "object": {"type": "string", "example": "model"},
+                "created": {"type": "integer"},
+                "owned_by": {"type": "string", "example": "local-ai-tool-proxy"}
+              }
+            }
+          }
+        }
+      },
+      "OpenAIError": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "object",
+            "properties": {
+              "message": {"type": "string", "example": "Failed to generate response"},
+              "type": {
+                "type": "string",
+                "enum": ["invalid_request_error", "authentication_error", "rate_limit_error", "api_error"]
+              },
+              "param": {"type": "string", "nullable": true},
+              "code": {"type": "string", "description": "Same codes as ErrorResponse, or model_not_found", "example": "cli_failure"}
+            }
+          }
+        }
+      },
+      "MessagesRequest": {
+        "type": "object",
+        "required": ["messages"],
+        "properties": {
+          "model": {
+            "type": "string",
+            "description": "Provider, optionally followed by '/' and a model. Other IDs, such as Anthropic model names, run on the claude provider (or the default provider if claude is not registered), with the ID as model if it is allowed and with the default model otherwise. If omitted, uses the default provider.",
+            "example": "claude-sonnet-4-5"
+          },
+          "system": {
+            "description": "System instructions appended to the configured system prompt, as text or text blocks",
+            "oneOf": [
+              {"type": "string"},
+              {"type": "array", "items": {"$ref": "#/components/schemas/ContentBlock"}}
+            ]
+          },
+          "messages": {
+            "type": "array",
+            "description": "Conversation of user and assistant messages, ending with a user message",
+            "items": {
+              "type": "object",
+              "required": ["role", "content"],
+              "properties": {
+                "role": {"type": "string", "enum": ["user", "assistant"]},
+                "content": {
+                  "oneOf": [
+                    {"type": "string"},
+                    {"type": "array", "items": {"$ref": "#/components/schemas/ContentBlock"}}
+                  ]
+                }
+              }
+            }
+          },
+          "max_tokens": {
+            "type": "integer",
+            "minimum": 0,
+            "description": "Accepted for compatibility; the CLIs cannot limit their output"
+          },
+          "stream": {
+            "type": "boolean",
+            "default": false
+          }
+        }
+      },
+      "ContentBlock": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "example": "text"},
+          "text": {"type": "string"}
+        }
+      },
+      "MessagesResponse": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string", "example": "msg_3f1c2a9b8d7e6f5a4b3c2d1e"},
+          "type": {"type": "string", "example": "message"},
+          "role": {"type": "string", "example": "assistant"},
+          "model": {
+            "type": "string",
+            "description": "Requested model, or the provider that answered if a fallback did"
+          },
+          "content": {
+            "type": "array",
+            "items": {"$ref": "#/components/schemas/ContentBlock"}
+          },
+          "stop_reason": {"type": "string", "nullable": true, "example": "end_turn"},
+          "stop_sequence": {"type": "string", "nullable": true},
+          "usage": {
+            "type": "object",
+            "description": "Token usage of providers whose CLI reports it; zero otherwise and for streamed messages",
+            "properties": {
+              "input_tokens": {"type": "integer"},
+              "output_tokens": {"type": "integer"}
+            }
+          }
+        }
+      },
+      "AnthropicError": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "example": "error"},
+          "error": {
+            "type": "object",
+            "properties": {
+              "type": {
+                "type": "string",
+                "enum": ["invalid_request_error", "authentication_error", "not_found_error", "rate_limit_error", "api_error", "overloaded_error"]
+              },
+              "message": {"type": "string", "example": "Failed to generate response"}
+            }
+          }
+        }
+      },
+      "OllamaResponse": {
+        "type": "object",
+        "properties": {
+          "model": {"type": "string", "example": "claude"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "response": {"type": "string", "description": "Text of /api/generate responses"},
+          "message": {
+            "type": "object",
+            "description": "Text of /api/chat responses",
+            "properties": {
+              "role": {"type": "string", "example": "assistant"},
+              "content": {"type": "string"}
+            }
+          },
+          "done": {"type": "boolean"},
+          "done_reason": {"type": "string", "example": "stop"},
+          "total_duration": {"type": "integer", "description": "Duration in nanoseconds, on the last line"}
+        }
+      },
+      "GeminiContent": {
+        "type": "object",
+        "properties": {
+          "role": {"type": "string", "enum": ["user", "model"]},
+          "parts": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "text": {"type": "string"}
+              }
+            }
+          }
+        }
+      },
+      "GenerateContentRequest": {
+        "type": "object",
+        "required": ["contents"],
+        "properties": {
+          "contents": {
+            "type": "array",
+            "description": "Conversation, ending with a user turn. Only text parts are used.",
+            "items": {"$ref": "#/components/schemas/GeminiContent"}
+          },
+          "systemInstruction": {"$ref": "#/components/schemas/GeminiContent"}
+        }
+      },
+      "GenerateContentResponse": {
+        "type": "object",
+        "properties": {
+          "candidates": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "content": {"$ref": "#/components/schemas/GeminiContent"},
+                "finishReason": {"type": "string", "example": "STOP"},
+                "index": {"type": "integer"}
+              }
+            }
+          },
+          "modelVersion": {
+            "type": "string",
+            "description": "Requested model, or the provider that answered if a fallback did"
+          }
+        }
+      },
+      "GeminiError": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "object",
+            "properties": {
+              "code": {"type": "integer", "example": 404},
+              "message": {"type": "string", "example": "Unknown provider: gemini-2.5-pro"},
+              "status": {
+                "type": "string",
+                "enum": ["INVALID_ARGUMENT", "UNAUTHENTICATED", "NOT_FOUND", "RESOURCE_EXHAUSTED", "INTERNAL", "UNAVAILABLE", "DEADLINE_EXCEEDED"]
+              }
+            }
+          }
+        }
+      }
+    },
+    "responses": {
+      "OllamaError": {
+        "description": "Error in the Ollama format, with the same statuses and codes as /prompt",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/ErrorResponse"
+            }
           }
         }
       }
@@ -280,5 +2415,49 @@ func (h *Handler) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(OpenAPISpec))
+	w.Write(h.openAPISpec())
+}
+
+// builtinProviders are the providers listed in the OpenAPISpec provider enum.
+var builtinProviders = []string{"claude", "gemini", "codex", "continue", "opencode"}
+
+// openAPISpec returns the OpenAPI specification with any additional
+// registered providers, such as command providers, added to the provider enum.
+func (h *Handler) openAPISpec() []byte {
+	var extra []string
+	for name := range h.providers {
+		if !slices.Contains(builtinProviders, name) {
+			extra = append(extra, name)
+		}
+	}
+	if len(extra) == 0 {
+		return []byte(OpenAPISpec)
+	}
+	sort.Strings(extra)
+
+	var spec map[string]any
+	if err := json.Unmarshal([]byte(OpenAPISpec), &spec); err != nil {
+		return []byte(OpenAPISpec)
+	}
+
+	components, _ := spec["components"].(map[string]any)
+	schemas, _ := components["schemas"].(map[string]any)
+	request, _ := schemas["Request"].(map[string]any)
+	properties, _ := request["properties"].(map[string]any)
+	providerProp, _ := properties["provider"].(map[string]any)
+	if providerProp == nil {
+		return []byte(OpenAPISpec)
+	}
+
+	enum, _ := providerProp["enum"].([]any)
+	for _, name := range extra {
+		enum = append(enum, name)
+	}
+	providerProp["enum"] = enum
+
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return []byte(OpenAPISpec)
+	}
+	return data
 }
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
 )
 
 func TestHandleOpenAPI_ReturnsValidJSON(t *testing.T) {
@@ -148,3 +150,38 @@ func TestHandleOpenAPI_OptionsRequest(t *testing.T) {
 		t.Errorf("expected CORS header 'http://localhost:3000', got %q", cors)
 	}
 }
+
+func TestHandleOpenAPI_IncludesCommandProviders(t *testing.T) {
+	providers := map[string]provider.Generator{
+		"claude": &mockGenerator{},
+		"aider":  &mockGenerator{},
+	}
+	handler := newTestHandlerWithProviders(providers, "claude")
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleOpenAPI(w, req)
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("response is not valid JSON: %v", err)
+	}
+
+	components := spec["components"].(map[string]interface{})
+	schemas := components["schemas"].(map[string]interface{})
+	request := schemas["Request"].(map[string]interface{})
+	properties := request["properties"].(map[string]interface{})
+	providerProp := properties["provider"].(map[string]interface{})
+	enum := providerProp["enum"].([]interface{})
+
+	expected := []string{"claude", "gemini", "codex", "continue", "opencode", "aider"}
+	if len(enum) != len(expected) {
+		t.Fatalf("expected %d providers, got %d", len(expected), len(enum))
+	}
+	for i, name := range expected {
+		if enum[i].(string) != name {
+			t.Errorf("expected provider %q at index %d, got %q", name, i, enum[i])
+		}
+	}
+}
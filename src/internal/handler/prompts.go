@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+)
+
+// defaultSystemPrompt returns the current system prompt of the prompt
+// library, falling back to the system prompt the handler was created with.
+// The system prompt the handler was created with is not a template.
+func (h *Handler) defaultSystemPrompt() prompt.Prompt {
+	if h.prompts != nil {
+		if system := h.prompts.System(); system.Text != "" {
+			return system
+		}
+	}
+	return prompt.Prompt{Text: h.systemPrompt}
+}
+
+// renderSystemPrompt renders the named system prompt, or the default system
+// prompt if name is empty, for a request to the named provider. Errors are
+// caused by the request.
+func (h *Handler) renderSystemPrompt(name, providerName string, vars map[string]string) (string, error) {
+	p := h.defaultSystemPrompt()
+	if name != "" {
+		var ok bool
+		if h.prompts != nil {
+			p, ok = h.prompts.Get(name)
+		}
+		if !ok {
+			return "", fmt.Errorf("Unknown system prompt: %s", name)
+		}
+	}
+
+	text, err := p.Render(prompt.NewData(providerName, vars))
+	var missing *prompt.MissingVariablesError
+	if errors.As(err, &missing) {
+		return "", fmt.Errorf("Missing system prompt variables: %s", strings.Join(missing.Names, ", "))
+	}
+	return text, err
+}
+
+// requestSystemPrompt returns the system prompt of a prompt request for the
+// named provider. A system prompt sent with the request is rejected, appended
+// to the configured system prompt, or replaces it, depending on override.
+func (h *Handler) requestSystemPrompt(req Request, providerName string, override prompt.Override) (string, error) {
+	system := strings.TrimSpace(req.System)
+	if system == "" {
+		return h.renderSystemPrompt(req.SystemPrompt, providerName, req.Variables)
+	}
+
+	switch override {
+	case prompt.OverrideAppend:
+		systemPrompt, err := h.renderSystemPrompt(req.SystemPrompt, providerName, req.Variables)
+		if err != nil {
+			return "", err
+		}
+		return systemPrompt + "\n\n" + system, nil
+	case prompt.OverrideReplace:
+		if req.SystemPrompt != "" {
+			return "", errors.New("The 'system' field replaces the system prompt and cannot be combined with 'system_prompt'")
+		}
+		return system, nil
+	default:
+		return "", errors.New("The 'system' field is not allowed")
+	}
+}
+
+// overrideFor returns the override policy of r, selected by the API key it
+// sends as a bearer token or in the X-Api-Key or X-Goog-Api-Key header, or by
+// its origin.
+func (h *Handler) overrideFor(r *http.Request) prompt.Override {
+	key := r.Header.Get("X-Api-Key")
+	if key == "" {
+		key = r.Header.Get("X-Goog-Api-Key")
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = strings.TrimSpace(token)
+	}
+	return h.systemOverride.For(r.Header.Get("Origin"), key)
+}
+
+// HandlePrompts handles GET /prompts requests.
+func (h *Handler) HandlePrompts(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prompts := []prompt.Prompt{}
+	if h.prompts != nil {
+		prompts = h.prompts.List()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]prompt.Prompt{"prompts": prompts})
+}
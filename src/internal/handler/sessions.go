@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/queue"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// CreateSessionRequest represents the payload for creating a session.
+type CreateSessionRequest struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// SessionMessageRequest represents a new user turn in a session.
+type SessionMessageRequest struct {
+	User string `json:"user"`
+	// TimeoutMS shortens the provider's CLI run timeout for this turn.
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// SystemPrompt selects a named system prompt instead of the default one.
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// Variables are rendered into the system prompt template.
+	Variables map[string]string `json:"variables,omitempty"`
+	// System is sent as the system prompt of this turn, subject to the
+	// override policy of the request.
+	System string `json:"system,omitempty"`
+}
+
+// HandleSessions handles POST /sessions requests.
+func (h *Handler) HandleSessions(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateSessionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[ERROR] Invalid JSON: %v", err)
+			h.sendError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, providerName, err := h.resolveProvider(req.Provider, req.Model)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sess := h.sessions.Create(providerName, req.Model)
+	log.Printf("[INFO] Created session %s using %s", sess.ID, providerName)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sess)
+}
+
+// HandleSession handles GET and DELETE /sessions/{id} requests.
+func (h *Handler) HandleSession(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, DELETE, OPTIONS")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		sess, ok := h.sessions.Get(id)
+		if !ok {
+			h.sendError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sess)
+
+	case http.MethodDelete:
+		if !h.sessions.Delete(id) {
+			h.sendError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("[INFO] Deleted session %s", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSessionMessages handles POST /sessions/{id}/messages requests.
+func (h *Handler) HandleSessionMessages(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SessionMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("[ERROR] Invalid JSON: %v", err)
+		h.sendError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.User == "" {
+		log.Printf("[ERROR] Missing required field: user=%q", req.User)
+		h.sendError(w, "The 'user' field is required", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	// The provider of a session never changes, so the timeout and system
+	// prompt can be validated up front
+	var timeout time.Duration
+	var systemPrompt string
+	if sess, ok := h.sessions.Get(id); ok {
+		requested, err := h.requestTimeout(sess.Provider, req.TimeoutMS)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		timeout = requested
+
+		systemPrompt, err = h.requestSystemPrompt(Request{
+			SystemPrompt: req.SystemPrompt,
+			Variables:    req.Variables,
+			System:       req.System,
+		}, sess.Provider, h.overrideFor(r))
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var result, providerName string
+	err := h.sessions.Update(id, func(sess *session.Session) error {
+		providerName = sess.Provider
+		p, _, err := h.resolveProvider(sess.Provider, sess.Model)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[INFO] Generating session %s turn using %s for prompt: %q", sess.ID, sess.Provider, req.User)
+
+		release, err := h.acquire(r.Context(), sess.Provider, queuePositionHeader(w))
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		ctx, cancel := withRunDeadline(r.Context(), w, h.runTimeout(sess.Provider, timeout))
+		defer cancel()
+
+		result, err = h.generateTurn(ctx, p, sess, systemPrompt, req.User)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		sess.Messages = append(sess.Messages,
+			session.Message{Role: session.RoleUser, Content: req.User, CreatedAt: now},
+			session.Message{Role: session.RoleAssistant, Content: result, CreatedAt: now},
+		)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			h.sendError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, queue.ErrQueueFull) {
+			h.sendQueueError(w, r, providerName, err)
+			return
+		}
+		if r.Context().Err() != nil {
+			log.Printf("[INFO] Client disconnected, session %s turn cancelled", id)
+			return
+		}
+		log.Printf("[ERROR] Session %s turn failed: %v", id, err)
+		resp, status := errorResponse(err)
+		h.sendErrorResponse(w, resp, status)
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated session response")
+	h.sendJSON(w, Response{ResponseText: result})
+}
+
+// generateTurn generates the next assistant turn of a session. Providers that
+// support native resume continue their own conversation; all others receive
+// the transcript replayed into the prompt.
+func (h *Handler) generateTurn(ctx context.Context, p provider.Generator, sess *session.Session, systemPrompt, userPrompt string) (string, error) {
+	// Native resume is only possible for the first turn or when the provider
+	// returned its own session ID for the previous one.
+	if sp, ok := p.(provider.SessionGenerator); ok && (len(sess.Messages) == 0 || sess.ProviderSessionID != "") {
+		result, nextSessionID, err := sp.GenerateTurn(ctx, systemPrompt, userPrompt, sess.ProviderSessionID)
+		if err != nil {
+			return "", err
+		}
+		sess.ProviderSessionID = nextSessionID
+		return result, nil
+	}
+
+	return p.Generate(ctx, systemPrompt, session.FormatTranscript(sess.Messages, userPrompt))
+}
@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/session"
+)
+
+// mockSessionGenerator implements provider.SessionGenerator for testing.
+type mockSessionGenerator struct {
+	sessionIDs []string
+}
+
+func (m *mockSessionGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return "stateless", nil
+}
+
+func (m *mockSessionGenerator) GenerateTurn(ctx context.Context, systemPrompt, userPrompt, sessionID string) (string, string, error) {
+	m.sessionIDs = append(m.sessionIDs, sessionID)
+	return "reply to " + userPrompt, "native-1", nil
+}
+
+// recordingGenerator records the prompts it receives.
+type recordingGenerator struct {
+	prompts []string
+	err     error
+}
+
+func (g *recordingGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	g.prompts = append(g.prompts, userPrompt)
+	return "ok", g.err
+}
+
+// createSession creates a session through the handler and returns it.
+func createSession(t *testing.T, handler *Handler, body string) session.Session {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleSessions(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var sess session.Session
+	if err := json.NewDecoder(w.Body).Decode(&sess); err != nil {
+		t.Fatalf("failed to decode session: %v", err)
+	}
+	return sess
+}
+
+// postSessionMessage posts a user turn to a session.
+func postSessionMessage(handler *Handler, id, user string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(SessionMessageRequest{User: user})
+	req := httptest.NewRequest(http.MethodPost, "/sessions/"+id+"/messages", bytes.NewBuffer(body))
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+
+	handler.HandleSessionMessages(w, req)
+	return w
+}
+
+func TestHandleSessions_Create(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	sess := createSession(t, handler, "")
+	if sess.ID == "" {
+		t.Error("expected session ID")
+	}
+	if sess.Provider != "claude" {
+		t.Errorf("expected default provider claude, got %s", sess.Provider)
+	}
+}
+
+func TestHandleSessions_UnknownProvider(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(`{"provider":"unknown"}`))
+	w := httptest.NewRecorder()
+
+	handler.HandleSessions(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandleSessionMessages_NativeResume(t *testing.T) {
+	mock := &mockSessionGenerator{}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": mock}, "claude")
+	sess := createSession(t, handler, `{"provider":"claude"}`)
+
+	for _, user := range []string{"Hello", "Follow up"} {
+		w := postSessionMessage(handler, sess.ID, user)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp Response
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.ResponseText != "reply to "+user {
+			t.Errorf("unexpected response: %q", resp.ResponseText)
+		}
+	}
+
+	if len(mock.sessionIDs) != 2 || mock.sessionIDs[0] != "" || mock.sessionIDs[1] != "native-1" {
+		t.Errorf("expected native session to be resumed, got session IDs %q", mock.sessionIDs)
+	}
+}
+
+func TestHandleSessionMessages_TranscriptReplay(t *testing.T) {
+	mock := &recordingGenerator{}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": mock}, "claude")
+	sess := createSession(t, handler, "")
+
+	postSessionMessage(handler, sess.ID, "What is 2+2?")
+	postSessionMessage(handler, sess.ID, "And times 3?")
+
+	if len(mock.prompts) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(mock.prompts))
+	}
+	if mock.prompts[0] != "What is 2+2?" {
+		t.Errorf("expected first prompt without transcript, got %q", mock.prompts[0])
+	}
+	if !strings.Contains(mock.prompts[1], "User: What is 2+2?") || !strings.Contains(mock.prompts[1], "Assistant: ok") {
+		t.Errorf("expected second prompt to replay the transcript, got %q", mock.prompts[1])
+	}
+}
+
+func TestHandleSessionMessages_FailureDoesNotRecordTurn(t *testing.T) {
+	mock := &recordingGenerator{err: errors.New("CLI failed")}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": mock}, "claude")
+	sess := createSession(t, handler, "")
+
+	w := postSessionMessage(handler, sess.ID, "Hello")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+
+	got, _ := handler.sessions.Get(sess.ID)
+	if len(got.Messages) != 0 {
+		t.Errorf("expected failed turn not to be recorded, got %+v", got.Messages)
+	}
+}
+
+func TestHandleSessionMessages_SystemPrompt(t *testing.T) {
+	gen := &promptGenerator{}
+	prompts := newTestPrompts(t, map[string]string{"pirate.md": "You are a pirate."})
+	handler := New(map[string]provider.Generator{"claude": gen}, "claude", "http://localhost:3000", "You are a test assistant.",
+		WithPrompts(prompts), WithSystemOverride(prompt.OverridePolicy{Default: prompt.OverrideAppend}))
+	sess := createSession(t, handler, "")
+
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantSystem string
+	}{
+		{`{"user": "Hi"}`, http.StatusOK, "You are a test assistant."},
+		{`{"user": "Hi", "system_prompt": "pirate"}`, http.StatusOK, "You are a pirate."},
+		{`{"user": "Hi", "system_prompt": "pirate", "system": "Answer in German."}`, http.StatusOK, "You are a pirate.\n\nAnswer in German."},
+		{`{"user": "Hi", "system_prompt": "missing"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		gen.systemPrompt = ""
+		req := httptest.NewRequest(http.MethodPost, "/sessions/"+sess.ID+"/messages", strings.NewReader(tt.body))
+		req.SetPathValue("id", sess.ID)
+		w := httptest.NewRecorder()
+		handler.HandleSessionMessages(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Fatalf("expected status %d for %s, got %d: %s", tt.wantStatus, tt.body, w.Code, w.Body.String())
+		}
+		if gen.systemPrompt != tt.wantSystem {
+			t.Errorf("expected system prompt %q for %s, got %q", tt.wantSystem, tt.body, gen.systemPrompt)
+		}
+	}
+}
+
+func TestHandleSessionMessages_NotFound(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	w := postSessionMessage(handler, "missing", "Hello")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestHandleSession_GetAndDelete(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hi there"})
+	sess := createSession(t, handler, "")
+	postSessionMessage(handler, sess.ID, "Hello")
+
+	req := httptest.NewRequest(http.MethodGet, "/sessions/"+sess.ID, nil)
+	req.SetPathValue("id", sess.ID)
+	w := httptest.NewRecorder()
+	handler.HandleSession(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var got session.Session
+	json.NewDecoder(w.Body).Decode(&got)
+	if len(got.Messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(got.Messages))
+	}
+	if got.Messages[0].Role != session.RoleUser || got.Messages[1].Content != "Hi there" {
+		t.Errorf("unexpected transcript: %+v", got.Messages)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/sessions/"+sess.ID, nil)
+	req.SetPathValue("id", sess.ID)
+	w = httptest.NewRecorder()
+	handler.HandleSession(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/sessions/"+sess.ID, nil)
+	req.SetPathValue("id", sess.ID)
+	w = httptest.NewRecorder()
+	handler.HandleSession(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestHandleSession_CORSAllowsDelete(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	req := httptest.NewRequest(http.MethodOptions, "/sessions/abc", nil)
+	w := httptest.NewRecorder()
+	handler.HandleSession(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, "DELETE") {
+		t.Errorf("expected DELETE to be allowed, got %q", got)
+	}
+}
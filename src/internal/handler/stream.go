@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// StreamDelta is the payload of a "delta" server-sent event.
+type StreamDelta struct {
+	Text string `json:"text"`
+}
+
+// sseWriter writes server-sent events and flushes them immediately.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter sets the event stream headers. It returns false if the
+// ResponseWriter does not support flushing.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}, true
+}
+
+// send writes a single event with a JSON encoded payload.
+func (s *sseWriter) send(event string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.write("event: %s\ndata: %s\n\n", event, data)
+}
+
+// sendData writes an unnamed event with a JSON encoded payload, as used by
+// the OpenAI and Gemini streaming formats.
+func (s *sseWriter) sendData(payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.write("data: %s\n\n", data)
+}
+
+// write writes raw event stream data and flushes it.
+func (s *sseWriter) write(format string, args ...any) error {
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// wantsStream reports whether the client asked for a streaming response.
+func wantsStream(r *http.Request, req Request) bool {
+	return req.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// generateStream generates a response with p, reporting deltas as they are
+// produced. Providers without streaming support report the whole response as
+// a single delta.
+func generateStream(ctx context.Context, p provider.Generator, systemPrompt, userPrompt string, onDelta func(string)) (string, error) {
+	if sp, ok := p.(provider.StreamGenerator); ok {
+		return sp.GenerateStream(ctx, systemPrompt, userPrompt, onDelta)
+	}
+
+	result, err := p.Generate(ctx, systemPrompt, userPrompt)
+	if err != nil {
+		return "", err
+	}
+	onDelta(result)
+	return result, nil
+}
+
+// streamPrompt serves a prompt as a stream of server-sent events: "queue"
+// events while the request waits for a run slot, any number of "delta"
+// events, and a single "done" or "error" event.
+func (h *Handler) streamPrompt(w http.ResponseWriter, r *http.Request, g generation) {
+	if _, ok := w.(http.Flusher); !ok {
+		h.sendError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream is opened lazily so that a full queue can still be
+	// reported with a 429 status
+	var sse *sseWriter
+	openStream := func() {
+		if sse == nil {
+			sse, _ = newSSEWriter(w)
+		}
+	}
+
+	g.onPosition = func(position int) {
+		openStream()
+		sse.send("queue", QueueStatus{Position: position})
+	}
+	g.onStart = func(string) { openStream() }
+	g.onDelta = func(delta string) {
+		sse.send("delta", StreamDelta{Text: delta})
+	}
+
+	res := h.generate(r.Context(), w, g)
+	if res.err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("[INFO] Client disconnected, %s CLI cancelled", res.provider)
+			return
+		}
+		if sse == nil {
+			h.sendQueueError(w, r, res.provider, res.err)
+			return
+		}
+		log.Printf("[ERROR] %s CLI failed: %v", res.provider, res.err)
+		resp, _ := res.failedResponse()
+		sse.send("error", resp)
+		return
+	}
+
+	log.Printf("[INFO] Successfully streamed response using %s", res.provider)
+	sse.send("done", res.response())
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// mockStreamGenerator implements provider.StreamGenerator for testing.
+type mockStreamGenerator struct {
+	deltas []string
+	err    error
+}
+
+func (m *mockStreamGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return strings.Join(m.deltas, ""), m.err
+}
+
+func (m *mockStreamGenerator) GenerateStream(ctx context.Context, systemPrompt, userPrompt string, onDelta func(string)) (string, error) {
+	for _, delta := range m.deltas {
+		onDelta(delta)
+	}
+	if m.err != nil {
+		return "", m.err
+	}
+	return strings.Join(m.deltas, ""), nil
+}
+
+// sseEvent is a parsed server-sent event.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// parseSSE splits a server-sent event stream into events.
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestHandlePrompt_StreamAcceptHeader(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hello", ", world!"}},
+	}, "claude")
+
+	body, _ := json.Marshal(Request{User: "Say hello"})
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+
+	handler.HandlePrompt(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %q", len(events), w.Body.String())
+	}
+
+	for i, expected := range []string{"Hello", ", world!"} {
+		var delta StreamDelta
+		json.Unmarshal([]byte(events[i].data), &delta)
+		if events[i].name != "delta" || delta.Text != expected {
+			t.Errorf("event %d: expected delta %q, got %s %q", i, expected, events[i].name, events[i].data)
+		}
+	}
+
+	var done Response
+	json.Unmarshal([]byte(events[2].data), &done)
+	if events[2].name != "done" || done.ResponseText != "Hello, world!" {
+		t.Errorf("expected done event with full text, got %s %q", events[2].name, events[2].data)
+	}
+}
+
+func TestHandlePrompt_StreamField(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "Hello"})
+
+	body, _ := json.Marshal(Request{User: "Say hello", Stream: true})
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandlePrompt(w, req)
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d: %q", len(events), w.Body.String())
+	}
+	if events[0].name != "delta" || events[0].data != `{"text":"Hello"}` {
+		t.Errorf("unexpected delta event: %s %q", events[0].name, events[0].data)
+	}
+	if events[1].name != "done" || events[1].data != `{"response":"Hello","provider":"claude"}` {
+		t.Errorf("unexpected done event: %s %q", events[1].name, events[1].data)
+	}
+}
+
+func TestHandlePrompt_StreamError(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &mockStreamGenerator{deltas: []string{"Hel"}, err: errors.New("CLI failed")},
+	}, "claude")
+
+	body, _ := json.Marshal(Request{User: "Say hello", Stream: true})
+	req := httptest.NewRequest(http.MethodPost, "/prompt", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandlePrompt(w, req)
+
+	events := parseSSE(t, w.Body.String())
+	last := events[len(events)-1]
+	if last.name != "error" || last.data != `{"error":"Failed to generate response","code":"cli_failure","retryable":false}` {
+		t.Errorf("expected error event, got %s %q", last.name, last.data)
+	}
+}
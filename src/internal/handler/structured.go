@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/schema"
+)
+
+const (
+	// defaultSchemaRetries is the number of repair attempts for responses
+	// that do not match the request's schema unless configured otherwise.
+	defaultSchemaRetries = 2
+
+	// codeInvalidOutput is the error code of responses that still do not
+	// match the request's schema after all repair attempts.
+	codeInvalidOutput = "invalid_output"
+)
+
+// schemaInstructions is appended to the system prompt of providers that
+// cannot constrain their response to a JSON Schema natively.
+const schemaInstructions = `Respond with a single JSON value that matches the following JSON Schema. Do not wrap it in a code block and do not add any other text.
+
+JSON Schema:
+%s`
+
+// repairInstructions asks the provider to correct a response that does not
+// match the schema.
+const repairInstructions = `%s
+
+Your previous response does not match the JSON Schema:
+%s
+
+Errors:
+%s
+
+Respond again with only the corrected JSON.`
+
+// OutputError is returned by structured generations whose response still
+// does not match the schema after all repair attempts.
+type OutputError struct {
+	Attempts int
+	Err      *schema.ValidationError
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("response does not match the schema after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// generateStructured runs a generation with a schema on p. Providers that
+// implement provider.SchemaGenerator receive the schema natively, others are
+// instructed to follow it. Responses that do not match the schema are sent
+// back to the provider for repair, up to the configured number of retries.
+// It returns the response as compact JSON.
+func (h *Handler) generateStructured(ctx context.Context, p provider.Generator, name string, g generation) (string, error) {
+	sg, native := p.(provider.SchemaGenerator)
+	systemPrompt := g.systemPrompt
+	if !native {
+		systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + fmt.Sprintf(schemaInstructions, g.schema.Raw()))
+	}
+
+	userPrompt := g.userPrompt
+	attempts := h.schemaRetries + 1
+	var invalid *schema.ValidationError
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var text string
+		var err error
+		if native {
+			text, err = sg.GenerateJSON(ctx, systemPrompt, userPrompt, g.schema.Raw())
+		} else {
+			text, err = p.Generate(ctx, systemPrompt, userPrompt)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		output := extractJSON(text)
+		if err := g.schema.Validate([]byte(output)); err != nil {
+			if !errors.As(err, &invalid) {
+				return "", err
+			}
+			if attempt < attempts {
+				log.Printf("[WARN] %s response does not match the schema, repairing (attempt %d of %d): %v", name, attempt+1, attempts, invalid)
+			}
+			userPrompt = fmt.Sprintf(repairInstructions, g.userPrompt, output, "- "+strings.Join(invalid.Errors, "\n- "))
+			continue
+		}
+
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(output)); err != nil {
+			return "", err
+		}
+		return compact.String(), nil
+	}
+
+	return "", &OutputError{Attempts: attempts, Err: invalid}
+}
+
+// extractJSON returns the JSON value of a response, removing code blocks and
+// any text around it.
+func extractJSON(text string) string {
+	text = provider.CleanResponse(text)
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+		return text[start : end+1]
+	}
+	return text
+}
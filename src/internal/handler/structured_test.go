@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+const personSchema = `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`
+
+// scriptedGenerator returns its responses in turn and records the prompts it
+// receives.
+type scriptedGenerator struct {
+	responses     []string
+	systemPrompts []string
+	userPrompts   []string
+}
+
+func (g *scriptedGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	g.systemPrompts = append(g.systemPrompts, systemPrompt)
+	g.userPrompts = append(g.userPrompts, userPrompt)
+	response := g.responses[0]
+	if len(g.responses) > 1 {
+		g.responses = g.responses[1:]
+	}
+	return response, nil
+}
+
+// schemaGenerator implements provider.SchemaGenerator for testing.
+type schemaGenerator struct {
+	scriptedGenerator
+	schemas []string
+}
+
+func (g *schemaGenerator) GenerateJSON(ctx context.Context, systemPrompt, userPrompt string, schema json.RawMessage) (string, error) {
+	g.schemas = append(g.schemas, string(schema))
+	return g.Generate(ctx, systemPrompt, userPrompt)
+}
+
+// personRequest asks for a response matching personSchema.
+var personRequest = Request{User: "Who wrote the first program?", Schema: json.RawMessage(personSchema)}
+
+func TestHandlePrompt_Schema(t *testing.T) {
+	gen := &scriptedGenerator{responses: []string{"Here you go:\n```json\n{\n  \"name\": \"Ada\",\n  \"age\": 36\n}\n```"}}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": gen}, "claude")
+
+	w := postPrompt(handler, personRequest)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if string(resp.Output) != `{"name":"Ada","age":36}` {
+		t.Errorf("unexpected output: %s", resp.Output)
+	}
+	if resp.ResponseText != "" {
+		t.Errorf("expected no response text, got %q", resp.ResponseText)
+	}
+	if !strings.Contains(gen.systemPrompts[0], personSchema) {
+		t.Errorf("expected schema in system prompt, got %q", gen.systemPrompts[0])
+	}
+}
+
+func TestHandlePrompt_SchemaNative(t *testing.T) {
+	gen := &schemaGenerator{scriptedGenerator: scriptedGenerator{responses: []string{`{"name":"Ada","age":36}`}}}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": gen}, "claude")
+
+	w := postPrompt(handler, personRequest)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(gen.schemas) != 1 || gen.schemas[0] != personSchema {
+		t.Errorf("expected schema to be passed natively, got %q", gen.schemas)
+	}
+	if gen.systemPrompts[0] != "You are a test assistant." {
+		t.Errorf("expected unchanged system prompt, got %q", gen.systemPrompts[0])
+	}
+}
+
+func TestHandlePrompt_SchemaRepair(t *testing.T) {
+	gen := &scriptedGenerator{responses: []string{`{"name":"Ada"}`, `{"name":"Ada","age":36}`}}
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{"claude": gen}, "claude")
+
+	w := postPrompt(handler, personRequest)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(gen.userPrompts) != 2 {
+		t.Fatalf("expected one repair attempt, got %d runs", len(gen.userPrompts))
+	}
+	repair := gen.userPrompts[1]
+	if !strings.HasPrefix(repair, "Who wrote the first program?") || !strings.Contains(repair, `missing required property "age"`) {
+		t.Errorf("unexpected repair prompt: %q", repair)
+	}
+}
+
+func TestHandlePrompt_SchemaInvalidOutput(t *testing.T) {
+	gen := &scriptedGenerator{responses: []string{"I don't know."}}
+	handler := New(map[string]provider.Generator{"claude": gen}, "claude", "http://localhost:3000", "You are a test assistant.", WithSchemaRetries(1))
+
+	w := postPrompt(handler, personRequest)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != codeInvalidOutput || resp.Retryable == nil || !*resp.Retryable {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(resp.ValidationErrors) != 1 || !strings.HasPrefix(resp.ValidationErrors[0], "invalid JSON") {
+		t.Errorf("unexpected validation errors: %q", resp.ValidationErrors)
+	}
+	if len(gen.userPrompts) != 2 {
+		t.Errorf("expected 2 runs, got %d", len(gen.userPrompts))
+	}
+}
+
+func TestHandlePrompt_InvalidSchema(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{response: "ok"})
+
+	w := postPrompt(handler, Request{User: "Hi", Schema: json.RawMessage(`{"type": "text"}`)})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+
+	var resp Response
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !strings.HasPrefix(resp.Error, "Invalid 'schema' field") {
+		t.Errorf("unexpected error: %q", resp.Error)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{`{"a":1}`, `{"a":1}`},
+		{"```json\n[1, 2]\n```", `[1, 2]`},
+		{`The answer is {"a":1}.`, `{"a":1}`},
+		{`no json here`, `no json here`},
+	}
+
+	for _, tt := range tests {
+		if got := extractJSON(tt.text); got != tt.expected {
+			t.Errorf("extractJSON(%q) = %q, expected %q", tt.text, got, tt.expected)
+		}
+	}
+}
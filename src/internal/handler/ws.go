@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/prompt"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/websocket"
+)
+
+// WSClientMessage is a message sent by a WebSocket client: "prompt" starts a
+// request with the fields of POST /prompt, "cancel" stops the request with
+// the given ID, and "ping" is answered with "pong".
+type WSClientMessage struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	Request
+}
+
+// WSServerMessage is a message sent to a WebSocket client: "queue" with the
+// queue position while a request waits for a run slot, "delta" with response
+// text, and a single "done" or "error" per request, carrying the fields of
+// the POST /prompt response. "pong" answers a ping.
+type WSServerMessage struct {
+	Type     string `json:"type"`
+	ID       string `json:"id,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Position int    `json:"position,omitempty"`
+	Response
+}
+
+// wsSession serves the requests multiplexed over a single WebSocket.
+type wsSession struct {
+	h    *Handler
+	conn *websocket.Conn
+	// ctx is cancelled when the socket closes, stopping all requests.
+	ctx context.Context
+	// override governs system prompts sent with requests, as determined
+	// by the handshake.
+	override prompt.Override
+
+	mu       sync.Mutex
+	requests map[string]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// HandleWebSocket handles /ws connections. Requests run concurrently and are
+// identified by client-chosen IDs; closing the socket cancels all of them.
+func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Browsers do not apply CORS to WebSockets
+	if !h.originAllowed(r) {
+		log.Printf("[WARN] Rejected WebSocket from origin %s", r.Header.Get("Origin"))
+		h.sendError(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("[ERROR] WebSocket upgrade failed: %v", err)
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			h.sendError(w, "Invalid WebSocket handshake", http.StatusBadRequest)
+		} else {
+			h.sendError(w, "WebSocket not supported", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.trackSocket(conn, true)
+	defer h.trackSocket(conn, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &wsSession{h: h, conn: conn, ctx: ctx, override: h.overrideFor(r), requests: make(map[string]context.CancelFunc)}
+	log.Printf("[INFO] WebSocket connected from %s", r.RemoteAddr)
+
+	s.serve()
+
+	cancel()
+	s.wg.Wait()
+	conn.Close()
+	log.Printf("[INFO] WebSocket from %s closed", r.RemoteAddr)
+}
+
+// originAllowed reports whether r comes from the allowed origin. Requests
+// without an Origin header do not come from browsers and are allowed.
+func (h *Handler) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || h.allowedOrigin == "*" || origin == h.allowedOrigin
+}
+
+// trackSocket adds or removes an open socket, so that it can be closed on
+// shutdown.
+func (h *Handler) trackSocket(conn *websocket.Conn, open bool) {
+	h.socketsMu.Lock()
+	defer h.socketsMu.Unlock()
+
+	if open {
+		h.sockets[conn] = struct{}{}
+	} else {
+		delete(h.sockets, conn)
+	}
+}
+
+// CloseSockets closes all open WebSockets, cancelling their requests. It is
+// meant to be registered with http.Server.RegisterOnShutdown, as the server
+// does not track upgraded connections.
+func (h *Handler) CloseSockets() {
+	h.socketsMu.Lock()
+	defer h.socketsMu.Unlock()
+
+	for conn := range h.sockets {
+		conn.CloseWithCode(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// serve reads client messages until the socket closes.
+func (s *wsSession) serve() {
+	for {
+		data, err := s.conn.ReadMessage()
+		if err != nil {
+			switch {
+			case errors.Is(err, websocket.ErrIdleTimeout):
+				log.Printf("[INFO] Closing idle WebSocket")
+			case !errors.Is(err, websocket.ErrClosed):
+				log.Printf("[ERROR] WebSocket read failed: %v", err)
+			}
+			return
+		}
+
+		var msg WSClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.send(WSServerMessage{Type: "error", Response: Response{Error: "Invalid JSON"}})
+			continue
+		}
+
+		switch msg.Type {
+		case "prompt":
+			s.start(msg)
+		case "cancel":
+			s.cancel(msg.ID)
+		case "ping":
+			s.send(WSServerMessage{Type: "pong", ID: msg.ID})
+		default:
+			s.sendError(msg.ID, fmt.Sprintf("Unknown message type: %s", msg.Type))
+		}
+	}
+}
+
+// start validates a prompt message and runs it in the background.
+func (s *wsSession) start(msg WSClientMessage) {
+	if msg.ID == "" {
+		s.sendError("", "The 'id' field is required")
+		return
+	}
+
+	g, err := s.h.promptGeneration(msg.Request, s.override)
+	if err != nil {
+		s.sendError(msg.ID, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	s.mu.Lock()
+	if _, ok := s.requests[msg.ID]; ok {
+		s.mu.Unlock()
+		cancel()
+		s.sendError(msg.ID, fmt.Sprintf("Request %s is already running", msg.ID))
+		return
+	}
+	s.requests[msg.ID] = cancel
+	s.mu.Unlock()
+
+	log.Printf("[INFO] Generating WebSocket response %s using %s for prompt: %q", msg.ID, g.providerName, msg.User)
+
+	s.wg.Add(1)
+	go s.run(ctx, msg.ID, g)
+}
+
+// run generates the response of a request and reports it to the client.
+func (s *wsSession) run(ctx context.Context, id string, g generation) {
+	defer s.wg.Done()
+
+	g.onPosition = func(position int) {
+		s.send(WSServerMessage{Type: "queue", ID: id, Position: position})
+	}
+	g.onDelta = func(delta string) {
+		s.send(WSServerMessage{Type: "delta", ID: id, Text: delta})
+	}
+
+	res := s.h.generate(ctx, nil, g)
+	cancelled := ctx.Err() != nil
+	s.finish(id)
+	if res.err != nil {
+		if s.ctx.Err() != nil {
+			log.Printf("[INFO] WebSocket closed, %s CLI cancelled", res.provider)
+			return
+		}
+		if cancelled {
+			log.Printf("[INFO] WebSocket request %s cancelled, %s CLI stopped", id, res.provider)
+			s.sendError(id, "Request cancelled")
+			return
+		}
+		log.Printf("[ERROR] %s CLI failed: %v", res.provider, res.err)
+		resp, _ := res.failedResponse()
+		s.send(WSServerMessage{Type: "error", ID: id, Response: resp})
+		return
+	}
+
+	log.Printf("[INFO] Successfully streamed WebSocket response %s using %s", id, res.provider)
+	s.send(WSServerMessage{Type: "done", ID: id, Response: res.response()})
+}
+
+// cancel stops the request with the given ID. Its "error" message is sent
+// once the CLI has stopped.
+func (s *wsSession) cancel(id string) {
+	s.mu.Lock()
+	cancel, ok := s.requests[id]
+	s.mu.Unlock()
+
+	if !ok {
+		s.sendError(id, fmt.Sprintf("Unknown request: %s", id))
+		return
+	}
+	cancel()
+}
+
+// finish releases the request with the given ID, so that the client may
+// reuse the ID as soon as it receives the result.
+func (s *wsSession) finish(id string) {
+	s.mu.Lock()
+	cancel := s.requests[id]
+	delete(s.requests, id)
+	s.mu.Unlock()
+
+	cancel()
+}
+
+// sendError sends an "error" message for the request with the given ID.
+func (s *wsSession) sendError(id, message string) {
+	s.send(WSServerMessage{Type: "error", ID: id, Response: Response{Error: message}})
+}
+
+// send writes a message to the client. Write errors are not reported, as a
+// broken socket also ends the read loop.
+func (s *wsSession) send(msg WSServerMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.conn.WriteMessage(data)
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/websocket/websockettest"
+)
+
+// dialWS starts a server for the handler's WebSocket endpoint and connects
+// to it.
+func dialWS(t *testing.T, handler *Handler) *websockettest.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	conn, err := websockettest.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendWS(t *testing.T, conn *websockettest.Conn, msg string) {
+	t.Helper()
+	if err := conn.WriteMessage([]byte(msg)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+func readWS(t *testing.T, conn *websockettest.Conn) WSServerMessage {
+	t.Helper()
+	type result struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		data, err := conn.ReadMessage()
+		ch <- result{data, err}
+	}()
+
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			t.Fatalf("read failed: %v", res.err)
+		}
+		var msg WSServerMessage
+		if err := json.Unmarshal(res.data, &msg); err != nil {
+			t.Fatalf("invalid message %s: %v", res.data, err)
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return WSServerMessage{}
+	}
+}
+
+func TestHandleWebSocket(t *testing.T) {
+	conn := dialWS(t, newTestHandler(&mockGenerator{response: "Hello"}))
+
+	sendWS(t, conn, `{"type": "ping", "id": "p1"}`)
+	if msg := readWS(t, conn); msg.Type != "pong" || msg.ID != "p1" {
+		t.Errorf("expected pong, got %+v", msg)
+	}
+
+	sendWS(t, conn, `{"type": "prompt", "id": "1", "user": "Say hello"}`)
+	if msg := readWS(t, conn); msg.Type != "delta" || msg.ID != "1" || msg.Text != "Hello" {
+		t.Errorf("expected delta, got %+v", msg)
+	}
+	if msg := readWS(t, conn); msg.Type != "done" || msg.ID != "1" || msg.ResponseText != "Hello" || msg.Provider != "claude" {
+		t.Errorf("expected done, got %+v", msg)
+	}
+}
+
+func TestHandleWebSocket_Multiplexing(t *testing.T) {
+	stopped := make(chan struct{})
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			close(stopped)
+			return "", ctx.Err()
+		}},
+		"gemini": &mockGenerator{response: "Quick"},
+	}, "claude")
+	conn := dialWS(t, handler)
+
+	sendWS(t, conn, `{"type": "prompt", "id": "slow", "user": "Take your time"}`)
+	sendWS(t, conn, `{"type": "prompt", "id": "fast", "user": "Be quick", "provider": "gemini"}`)
+
+	// The fast request completes while the slow one is still running
+	readWS(t, conn)
+	if msg := readWS(t, conn); msg.Type != "done" || msg.ID != "fast" {
+		t.Fatalf("expected fast request to be done, got %+v", msg)
+	}
+
+	sendWS(t, conn, `{"type": "prompt", "id": "slow", "user": "Again"}`)
+	if msg := readWS(t, conn); msg.Type != "error" || msg.ID != "slow" || !strings.Contains(msg.Error, "already running") {
+		t.Errorf("expected duplicate ID error, got %+v", msg)
+	}
+
+	sendWS(t, conn, `{"type": "cancel", "id": "slow"}`)
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the CLI run to be cancelled")
+	}
+	if msg := readWS(t, conn); msg.Type != "error" || msg.ID != "slow" || msg.Error != "Request cancelled" {
+		t.Errorf("expected cancellation error, got %+v", msg)
+	}
+}
+
+func TestHandleWebSocket_CloseCancelsRequests(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	handler := newTestHandlerWithProviders(map[string]provider.Generator{
+		"claude": &ctxGenerator{fn: func(ctx context.Context) (string, error) {
+			close(started)
+			<-ctx.Done()
+			close(stopped)
+			return "", ctx.Err()
+		}},
+	}, "claude")
+	conn := dialWS(t, handler)
+
+	sendWS(t, conn, `{"type": "prompt", "id": "1", "user": "Take your time"}`)
+	<-started
+	conn.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected closing the socket to cancel the CLI run")
+	}
+}
+
+func TestHandleWebSocket_InvalidMessages(t *testing.T) {
+	conn := dialWS(t, newTestHandler(&mockGenerator{response: "Hello"}))
+
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{`{`, "Invalid JSON"},
+		{`{"type": "prompt", "user": "Hi"}`, "The 'id' field is required"},
+		{`{"type": "prompt", "id": "1"}`, "The 'user' field is required"},
+		{`{"type": "prompt", "id": "1", "user": "Hi", "provider": "invalid"}`, "Unknown provider: invalid"},
+		{`{"type": "prompt", "id": "1", "user": "Hi", "callback_url": "http://localhost:9000/hook"}`, "The 'callback_url' field is only supported by POST /jobs"},
+		{`{"type": "cancel", "id": "missing"}`, "Unknown request: missing"},
+		{`{"type": "shout", "id": "1"}`, "Unknown message type: shout"},
+	}
+
+	for _, tt := range tests {
+		sendWS(t, conn, tt.msg)
+		if msg := readWS(t, conn); msg.Type != "error" || msg.Error != tt.want {
+			t.Errorf("expected error %q for %s, got %+v", tt.want, tt.msg, msg)
+		}
+	}
+}
+
+func TestHandleWebSocket_Origin(t *testing.T) {
+	handler := newTestHandler(&mockGenerator{})
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	handler.HandleWebSocket(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	w = httptest.NewRecorder()
+	handler.HandleWebSocket(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a plain GET, got %d", w.Code)
+	}
+}
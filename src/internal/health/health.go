@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// Monitor periodically probes providers and caches their status.
+type Monitor struct {
+	providers map[string]provider.Generator
+
+	mu       sync.RWMutex
+	statuses map[string]provider.Status
+}
+
+// NewMonitor creates a monitor for the given providers. Providers that do
+// not implement provider.Prober have no status.
+func NewMonitor(providers map[string]provider.Generator) *Monitor {
+	return &Monitor{
+		providers: providers,
+		statuses:  make(map[string]provider.Status),
+	}
+}
+
+// Check probes all providers concurrently and updates their status.
+func (m *Monitor) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, p := range m.providers {
+		prober, ok := p.(provider.Prober)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := prober.Probe(ctx)
+
+			m.mu.Lock()
+			previous, seen := m.statuses[name]
+			m.statuses[name] = status
+			m.mu.Unlock()
+
+			if seen && previous.Installed != status.Installed {
+				log.Printf("[INFO] Provider %s installed changed to %t", name, status.Installed)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Run probes all providers every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Status returns the last known status of the named provider.
+func (m *Monitor) Status(name string) (provider.Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status, ok := m.statuses[name]
+	return status, ok
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/provider"
+)
+
+// mockProber implements provider.Generator and provider.Prober for testing.
+type mockProber struct {
+	installed bool
+	probes    atomic.Int32
+}
+
+func (m *mockProber) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return "", nil
+}
+
+func (m *mockProber) Probe(ctx context.Context) provider.Status {
+	m.probes.Add(1)
+	return provider.Status{Binary: "mock", Installed: m.installed, Version: "1.0.0", LastChecked: time.Now()}
+}
+
+// plainGenerator implements provider.Generator only.
+type plainGenerator struct{}
+
+func (plainGenerator) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return "", nil
+}
+
+func TestMonitor_Check(t *testing.T) {
+	monitor := NewMonitor(map[string]provider.Generator{
+		"installed": &mockProber{installed: true},
+		"missing":   &mockProber{installed: false},
+		"plain":     plainGenerator{},
+	})
+
+	if _, ok := monitor.Status("installed"); ok {
+		t.Error("expected no status before the first check")
+	}
+
+	monitor.Check(context.Background())
+
+	status, ok := monitor.Status("installed")
+	if !ok || !status.Installed || status.Version != "1.0.0" {
+		t.Errorf("unexpected status for installed provider: %+v", status)
+	}
+
+	status, ok = monitor.Status("missing")
+	if !ok || status.Installed {
+		t.Errorf("unexpected status for missing provider: %+v", status)
+	}
+
+	if _, ok := monitor.Status("plain"); ok {
+		t.Error("expected no status for a provider without Probe")
+	}
+}
+
+func TestMonitor_Run(t *testing.T) {
+	prober := &mockProber{installed: true}
+	monitor := NewMonitor(map[string]provider.Generator{"mock": prober})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		monitor.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for prober.probes.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if prober.probes.Load() < 2 {
+		t.Errorf("expected periodic probes, got %d", prober.probes.Load())
+	}
+}
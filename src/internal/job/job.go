@@ -0,0 +1,208 @@
+// Package job keeps asynchronous generation jobs for polling.
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how long finished jobs are kept by default.
+const DefaultRetention = time.Hour
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+var ErrNotFound = errors.New("job not found")
+
+// Job is a generation running in the background.
+type Job struct {
+	ID       string `json:"id"`
+	Status   Status `json:"status"`
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	// QueuePosition is the job's position in the provider's queue while it
+	// waits for a run slot.
+	QueuePosition int        `json:"queue_position,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	// Result is the response or error payload of a finished job.
+	Result any `json:"result,omitempty"`
+}
+
+// Finished reports whether the job has succeeded, failed or been cancelled.
+func (j Job) Finished() bool {
+	return j.FinishedAt != nil
+}
+
+// entry holds a job together with the function that cancels its run.
+type entry struct {
+	job    Job
+	cancel context.CancelFunc
+}
+
+// Store keeps jobs in memory. Finished jobs are removed once they are older
+// than the retention.
+type Store struct {
+	mu        sync.Mutex
+	jobs      map[string]*entry
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewStore creates an empty job store that keeps finished jobs for
+// retention.
+func NewStore(retention time.Duration) *Store {
+	return &Store{
+		jobs:      make(map[string]*entry),
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Create adds a queued job for the given provider and model. cancel is
+// called when the job is cancelled.
+func (s *Store) Create(provider, model string, cancel context.CancelFunc) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	j := Job{
+		ID:        newID(),
+		Status:    StatusQueued,
+		Provider:  provider,
+		Model:     model,
+		CreatedAt: s.now().UTC(),
+	}
+	s.jobs[j.ID] = &entry{job: j, cancel: cancel}
+	return j
+}
+
+// Get returns the job with the given ID.
+func (s *Store) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	e, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return e.job, true
+}
+
+// SetQueuePosition records the queue position of a queued job.
+func (s *Store) SetQueuePosition(id string, position int) {
+	s.update(id, func(j *Job) {
+		j.QueuePosition = position
+	})
+}
+
+// Start marks a job as running.
+func (s *Store) Start(id string) {
+	s.update(id, func(j *Job) {
+		now := s.now().UTC()
+		j.Status = StatusRunning
+		j.QueuePosition = 0
+		j.StartedAt = &now
+	})
+}
+
+// Finish records the status and result of a job.
+func (s *Store) Finish(id string, status Status, result any) {
+	s.update(id, func(j *Job) {
+		now := s.now().UTC()
+		j.Status = status
+		j.QueuePosition = 0
+		j.FinishedAt = &now
+		j.Result = result
+	})
+}
+
+// Cancel cancels a queued or running job and marks it as cancelled. It
+// returns the job, which is unchanged if it had already finished.
+func (s *Store) Cancel(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if !e.job.Finished() {
+		now := s.now().UTC()
+		e.job.Status = StatusCancelled
+		e.job.QueuePosition = 0
+		e.job.FinishedAt = &now
+		e.cancel()
+	}
+	return e.job, nil
+}
+
+// CancelAll cancels every queued or running job.
+func (s *Store) CancelAll() {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		s.Cancel(id)
+	}
+}
+
+// Delete removes the job with the given ID. It returns false if the job does
+// not exist.
+func (s *Store) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return false
+	}
+	delete(s.jobs, id)
+	return true
+}
+
+// update runs fn on an unfinished job. Updates of finished jobs, such as the
+// result of a run that was cancelled, are ignored.
+func (s *Store) update(id string, fn func(*Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.jobs[id]; ok && !e.job.Finished() {
+		fn(&e.job)
+	}
+}
+
+// prune removes finished jobs older than the retention. The caller must hold
+// s.mu.
+func (s *Store) prune() {
+	cutoff := s.now().Add(-s.retention)
+	for id, e := range s.jobs {
+		if e.job.Finished() && e.job.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// newID returns a random 128-bit hex encoded job ID.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
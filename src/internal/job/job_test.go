@@ -0,0 +1,128 @@
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_Lifecycle(t *testing.T) {
+	store := NewStore(time.Hour)
+
+	j := store.Create("claude", "sonnet", func() {})
+	if j.ID == "" || j.Status != StatusQueued || j.Provider != "claude" || j.Model != "sonnet" {
+		t.Fatalf("unexpected job: %+v", j)
+	}
+
+	store.SetQueuePosition(j.ID, 2)
+	if got, _ := store.Get(j.ID); got.QueuePosition != 2 {
+		t.Errorf("expected queue position 2, got %d", got.QueuePosition)
+	}
+
+	store.Start(j.ID)
+	got, _ := store.Get(j.ID)
+	if got.Status != StatusRunning || got.StartedAt == nil || got.QueuePosition != 0 {
+		t.Errorf("unexpected running job: %+v", got)
+	}
+
+	store.Finish(j.ID, StatusSucceeded, "result")
+	got, _ = store.Get(j.ID)
+	if got.Status != StatusSucceeded || got.FinishedAt == nil || got.Result != "result" || !got.Finished() {
+		t.Errorf("unexpected finished job: %+v", got)
+	}
+}
+
+func TestStore_Cancel(t *testing.T) {
+	store := NewStore(time.Hour)
+
+	var cancelled bool
+	j := store.Create("claude", "", func() { cancelled = true })
+	store.Start(j.ID)
+
+	got, err := store.Cancel(j.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cancelled || got.Status != StatusCancelled || !got.Finished() {
+		t.Errorf("expected job to be cancelled, got %+v", got)
+	}
+
+	// The result of the cancelled run is ignored
+	store.Finish(j.ID, StatusFailed, "context canceled")
+	if got, _ := store.Get(j.ID); got.Status != StatusCancelled || got.Result != nil {
+		t.Errorf("expected cancelled job to stay unchanged, got %+v", got)
+	}
+
+	if _, err := store.Cancel("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_CancelFinishedJob(t *testing.T) {
+	store := NewStore(time.Hour)
+
+	var cancelled bool
+	j := store.Create("claude", "", func() { cancelled = true })
+	store.Finish(j.ID, StatusSucceeded, "result")
+
+	got, err := store.Cancel(j.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cancelled || got.Status != StatusSucceeded {
+		t.Errorf("expected finished job to stay unchanged, got %+v", got)
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	store := NewStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	finished := store.Create("claude", "", func() {})
+	store.Finish(finished.ID, StatusSucceeded, "result")
+	running := store.Create("claude", "", func() {})
+	store.Start(running.ID)
+
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := store.Get(finished.ID); ok {
+		t.Error("expected finished job to expire")
+	}
+	if _, ok := store.Get(running.ID); !ok {
+		t.Error("expected running job to be kept")
+	}
+}
+
+func TestStore_CancelAll(t *testing.T) {
+	store := NewStore(time.Hour)
+
+	var cancelled int
+	a := store.Create("claude", "", func() { cancelled++ })
+	b := store.Create("gemini", "", func() { cancelled++ })
+	store.Finish(b.ID, StatusSucceeded, "result")
+
+	store.CancelAll()
+
+	if cancelled != 1 {
+		t.Errorf("expected 1 cancelled run, got %d", cancelled)
+	}
+	if got, _ := store.Get(a.ID); got.Status != StatusCancelled {
+		t.Errorf("expected job to be cancelled, got %s", got.Status)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	store := NewStore(time.Hour)
+	j := store.Create("claude", "", func() {})
+
+	if !store.Delete(j.ID) {
+		t.Fatal("expected delete to succeed")
+	}
+	if _, ok := store.Get(j.ID); ok {
+		t.Error("expected job to be gone after delete")
+	}
+	if store.Delete(j.ID) {
+		t.Error("expected second delete to fail")
+	}
+}
@@ -0,0 +1,444 @@
+// Package mcp implements a Model Context Protocol server offering tools and
+// prompts over stdio and streamable HTTP.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ProtocolVersion is the latest protocol version supported by the server.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the protocol versions the server can speak, newest
+// first.
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// maxMessageSize bounds a single message read from a client.
+const maxMessageSize = 4 << 20
+
+// maxToolNameLength is the longest tool name accepted by clients.
+const maxToolNameLength = 64
+
+// toolNamePattern matches the tool names clients and model APIs accept.
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a tool offered to clients. Call receives the raw arguments of a
+// call; its error is reported to the client as a failed tool result.
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema is the JSON Schema of the arguments.
+	InputSchema map[string]any
+	Call        func(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+// Prompt is a prompt template without arguments offered to clients. If
+// TextFunc is set, it returns the current text and Text is ignored.
+type Prompt struct {
+	Name        string
+	Description string
+	Text        string
+	TextFunc    func() string
+}
+
+// Server answers MCP requests for a fixed set of tools and prompts.
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+	prompts []Prompt
+}
+
+// NewServer creates a server that introduces itself with name and version.
+// Tools whose names clients would reject are left out.
+func NewServer(name, version string, tools []Tool, prompts []Prompt) *Server {
+	valid := make([]Tool, 0, len(tools))
+	for _, t := range tools {
+		if !toolNamePattern.MatchString(t.Name) {
+			log.Printf("[WARN] Skipping MCP tool with invalid name %q", t.Name)
+			continue
+		}
+		valid = append(valid, t)
+	}
+	return &Server{name: name, version: version, tools: valid, prompts: prompts}
+}
+
+// ToolName turns s into a valid tool name by replacing characters other than
+// ASCII letters, digits, "_" and "-" with "_" and truncating it to 64 bytes.
+func ToolName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
+
+// message is a JSON-RPC request, notification or response. Notifications
+// have no ID; responses from the client have a result or error instead of a
+// method.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// response is a JSON-RPC response.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// textContent is a text content block of tool results and prompt messages.
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Handle processes a single JSON-RPC message and returns the encoded
+// response, or nil if the message needs none.
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return encode(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "Parse error"}})
+	}
+
+	if msg.Method == "" {
+		// Responses from the client are not expected, as the server
+		// sends no requests
+		if len(msg.ID) > 0 && (len(msg.Result) > 0 || len(msg.Error) > 0) {
+			return nil
+		}
+		id := msg.ID
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+		return encode(response{JSONRPC: "2.0", ID: id, Error: &rpcError{codeInvalidRequest, "Invalid request"}})
+	}
+
+	result, err := s.dispatch(ctx, msg)
+	if len(msg.ID) == 0 {
+		return nil
+	}
+
+	resp := response{JSONRPC: "2.0", ID: msg.ID, Result: result}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{codeInvalidParams, err.Error()}
+		}
+		resp = response{JSONRPC: "2.0", ID: msg.ID, Error: rerr}
+	}
+	return encode(resp)
+}
+
+// dispatch runs the method of msg and returns its result.
+func (s *Server) dispatch(ctx context.Context, msg message) (any, error) {
+	if msg.JSONRPC != "2.0" {
+		return nil, &rpcError{codeInvalidRequest, "Invalid request"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(msg.Params)
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return s.listTools(), nil
+	case "tools/call":
+		return s.callTool(ctx, msg.Params)
+	case "prompts/list":
+		return s.listPrompts(), nil
+	case "prompts/get":
+		return s.getPrompt(msg.Params)
+	}
+
+	if len(msg.ID) == 0 {
+		// Unknown notifications, such as notifications/initialized,
+		// are ignored
+		return nil, nil
+	}
+	return nil, &rpcError{codeMethodNotFound, "Method not found: " + msg.Method}
+}
+
+// initialize negotiates the protocol version and announces the server's
+// capabilities.
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, errors.New("Invalid initialize params")
+	}
+
+	version := ProtocolVersion
+	if slices.Contains(supportedVersions, p.ProtocolVersion) {
+		version = p.ProtocolVersion
+	}
+
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools":   map[string]any{},
+			"prompts": map[string]any{},
+		},
+		"serverInfo": map[string]string{"name": s.name, "version": s.version},
+	}, nil
+}
+
+func (s *Server) listTools() any {
+	tools := make([]map[string]any, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, map[string]any{
+			"name":        t.Name,
+			"description": t.Description,
+			"inputSchema": t.InputSchema,
+		})
+	}
+	return map[string]any{"tools": tools}
+}
+
+// callTool runs a tool. Failures of the tool itself are results, so that
+// the calling model can see them.
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, errors.New("Invalid tool call params")
+	}
+
+	i := slices.IndexFunc(s.tools, func(t Tool) bool { return t.Name == p.Name })
+	if i < 0 {
+		return nil, errors.New("Unknown tool: " + p.Name)
+	}
+	if len(p.Arguments) == 0 {
+		p.Arguments = json.RawMessage("{}")
+	}
+
+	text, err := s.tools[i].Call(ctx, p.Arguments)
+	if err != nil {
+		return map[string]any{
+			"content": []textContent{{Type: "text", Text: err.Error()}},
+			"isError": true,
+		}, nil
+	}
+	return map[string]any{
+		"content": []textContent{{Type: "text", Text: text}},
+		"isError": false,
+	}, nil
+}
+
+func (s *Server) listPrompts() any {
+	prompts := make([]map[string]any, 0, len(s.prompts))
+	for _, p := range s.prompts {
+		prompts = append(prompts, map[string]any{
+			"name":        p.Name,
+			"description": p.Description,
+		})
+	}
+	return map[string]any{"prompts": prompts}
+}
+
+func (s *Server) getPrompt(params json.RawMessage) (any, error) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, errors.New("Invalid prompt params")
+	}
+
+	i := slices.IndexFunc(s.prompts, func(prompt Prompt) bool { return prompt.Name == p.Name })
+	if i < 0 {
+		return nil, errors.New("Unknown prompt: " + p.Name)
+	}
+
+	prompt := s.prompts[i]
+	text := prompt.Text
+	if prompt.TextFunc != nil {
+		text = prompt.TextFunc()
+	}
+	return map[string]any{
+		"description": prompt.Description,
+		"messages": []map[string]any{
+			{"role": "user", "content": textContent{Type: "text", Text: text}},
+		},
+	}, nil
+}
+
+// ServeStdio serves newline-delimited messages read from r, writing
+// responses to w, until r is exhausted or ctx is cancelled. Requests run
+// concurrently; a notifications/cancelled message cancels a running request.
+// At the end of r, running requests are finished and answered before
+// ServeStdio returns; if ctx is cancelled, they are cancelled.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	st := &stdioSession{server: s, w: w, running: make(map[string]context.CancelFunc)}
+
+	// Reading blocks, so it runs apart from the loop that watches ctx
+	lines := make(chan []byte)
+	var readErr error
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			select {
+			case lines <- slices.Clone(scanner.Bytes()):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr = scanner.Err()
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// Clients may close stdin right after their last request
+				st.wg.Wait()
+				return readErr
+			}
+			if len(line) > 0 {
+				st.serve(ctx, line)
+			}
+		case <-ctx.Done():
+			st.wg.Wait()
+			return ctx.Err()
+		}
+	}
+}
+
+// stdioSession tracks the running requests of a stdio connection.
+type stdioSession struct {
+	server *Server
+	w      io.Writer
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// serve handles a single message in the background, or cancels a running
+// request.
+func (st *stdioSession) serve(ctx context.Context, line []byte) {
+	var msg message
+	if json.Unmarshal(line, &msg) == nil && msg.Method == "notifications/cancelled" {
+		var p struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		json.Unmarshal(msg.Params, &p)
+
+		st.mu.Lock()
+		if cancel, ok := st.running[string(p.RequestID)]; ok {
+			cancel()
+		}
+		st.mu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	key := string(msg.ID)
+	if key != "" {
+		st.mu.Lock()
+		st.running[key] = cancel
+		st.mu.Unlock()
+	}
+
+	st.wg.Add(1)
+	go func() {
+		defer st.wg.Done()
+		defer cancel()
+
+		resp := st.server.Handle(ctx, line)
+
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if key != "" {
+			delete(st.running, key)
+		}
+		// Cancelled requests are not answered
+		if resp != nil && ctx.Err() == nil {
+			st.w.Write(append(resp, '\n'))
+		}
+	}()
+}
+
+// ServeHTTP serves the streamable HTTP transport. Every POST carries a single
+// message, answered with a JSON response; the server does not open streams
+// of its own, so GET is not allowed.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp := s.Handle(r.Context(), data)
+	if r.Context().Err() != nil {
+		log.Printf("[INFO] MCP client disconnected, request cancelled")
+		return
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// encode marshals a response; responses consist of marshalable values only.
+func encode(resp response) []byte {
+	data, _ := json.Marshal(resp)
+	return data
+}
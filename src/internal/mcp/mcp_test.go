@@ -0,0 +1,308 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer() *Server {
+	echo := Tool{
+		Name:        "echo",
+		Description: "Echoes its text",
+		InputSchema: map[string]any{"type": "object"},
+		Call: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(arguments, &args)
+			if args.Text == "" {
+				return "", errors.New("text is required")
+			}
+			return args.Text, nil
+		},
+	}
+	wait := Tool{
+		Name: "wait",
+		Call: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+	prompt := Prompt{Name: "system_prompt", Description: "System prompt", Text: "Be helpful."}
+	return NewServer("test", "1.0", []Tool{echo, wait}, []Prompt{prompt})
+}
+
+// call handles a request and decodes its response.
+func call(t *testing.T, s *Server, request string) map[string]any {
+	t.Helper()
+	data := s.Handle(context.Background(), []byte(request))
+	if data == nil {
+		t.Fatalf("expected a response to %s", request)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", data, err)
+	}
+	return resp
+}
+
+func TestHandle_Initialize(t *testing.T) {
+	s := newTestServer()
+
+	resp := call(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-03-26", "capabilities": {}, "clientInfo": {"name": "test"}}}`)
+	result, _ := resp["result"].(map[string]any)
+	if resp["id"] != float64(1) || result["protocolVersion"] != "2025-03-26" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if info, _ := result["serverInfo"].(map[string]any); info["name"] != "test" || info["version"] != "1.0" {
+		t.Errorf("unexpected server info: %v", result["serverInfo"])
+	}
+
+	resp = call(t, s, `{"jsonrpc": "2.0", "id": 2, "method": "initialize", "params": {"protocolVersion": "1999-01-01"}}`)
+	if result, _ := resp["result"].(map[string]any); result["protocolVersion"] != ProtocolVersion {
+		t.Errorf("expected latest version for unknown versions, got %v", result["protocolVersion"])
+	}
+
+	if data := s.Handle(context.Background(), []byte(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)); data != nil {
+		t.Errorf("expected no response to a notification, got %s", data)
+	}
+}
+
+func TestHandle_Tools(t *testing.T) {
+	s := newTestServer()
+
+	resp := call(t, s, `{"jsonrpc": "2.0", "id": "a", "method": "tools/list"}`)
+	tools, _ := resp["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 2 || tools[0].(map[string]any)["name"] != "echo" {
+		t.Fatalf("unexpected tools: %v", resp)
+	}
+
+	resp = call(t, s, `{"jsonrpc": "2.0", "id": "b", "method": "tools/call", "params": {"name": "echo", "arguments": {"text": "hi"}}}`)
+	result, _ := resp["result"].(map[string]any)
+	content, _ := result["content"].([]any)
+	if result["isError"] != false || len(content) != 1 || content[0].(map[string]any)["text"] != "hi" {
+		t.Errorf("unexpected result: %v", resp)
+	}
+
+	resp = call(t, s, `{"jsonrpc": "2.0", "id": "c", "method": "tools/call", "params": {"name": "echo"}}`)
+	result, _ = resp["result"].(map[string]any)
+	content, _ = result["content"].([]any)
+	if result["isError"] != true || content[0].(map[string]any)["text"] != "text is required" {
+		t.Errorf("expected tool error result, got %v", resp)
+	}
+
+	resp = call(t, s, `{"jsonrpc": "2.0", "id": "d", "method": "tools/call", "params": {"name": "missing"}}`)
+	if rpcErr, _ := resp["error"].(map[string]any); rpcErr["code"] != float64(codeInvalidParams) {
+		t.Errorf("expected invalid params error, got %v", resp)
+	}
+}
+
+func TestNewServer_SkipsInvalidToolNames(t *testing.T) {
+	tools := []Tool{{Name: "ask claude"}, {Name: ""}, {Name: strings.Repeat("a", 65)}, {Name: "ask_claude-2"}}
+	s := NewServer("test", "1.0", tools, nil)
+
+	if len(s.tools) != 1 || s.tools[0].Name != "ask_claude-2" {
+		t.Errorf("expected only the valid tool, got %+v", s.tools)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ask_claude", "ask_claude"},
+		{"ask_my.tool/v2", "ask_my_tool_v2"},
+		{"ask_größe", "ask_gr__e"},
+		{strings.Repeat("a", 70), strings.Repeat("a", 64)},
+	}
+
+	for _, tt := range tests {
+		if got := ToolName(tt.name); got != tt.want || !toolNamePattern.MatchString(got) {
+			t.Errorf("ToolName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandle_Prompts(t *testing.T) {
+	s := newTestServer()
+
+	resp := call(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "prompts/list"}`)
+	prompts, _ := resp["result"].(map[string]any)["prompts"].([]any)
+	if len(prompts) != 1 || prompts[0].(map[string]any)["name"] != "system_prompt" {
+		t.Fatalf("unexpected prompts: %v", resp)
+	}
+
+	resp = call(t, s, `{"jsonrpc": "2.0", "id": 2, "method": "prompts/get", "params": {"name": "system_prompt"}}`)
+	messages, _ := resp["result"].(map[string]any)["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("unexpected prompt: %v", resp)
+	}
+	content, _ := messages[0].(map[string]any)["content"].(map[string]any)
+	if content["text"] != "Be helpful." {
+		t.Errorf("unexpected prompt content: %v", content)
+	}
+}
+
+func TestHandle_Errors(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		request string
+		code    int
+	}{
+		{`{`, codeParseError},
+		{`{"jsonrpc": "2.0", "id": 1}`, codeInvalidRequest},
+		{`{"jsonrpc": "1.0", "id": 1, "method": "ping"}`, codeInvalidRequest},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "resources/list"}`, codeMethodNotFound},
+	}
+
+	for _, tt := range tests {
+		resp := call(t, s, tt.request)
+		if rpcErr, _ := resp["error"].(map[string]any); rpcErr["code"] != float64(tt.code) {
+			t.Errorf("expected error %d for %s, got %v", tt.code, tt.request, resp)
+		}
+	}
+
+	resp := call(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`)
+	if result, ok := resp["result"].(map[string]any); !ok || len(result) != 0 {
+		t.Errorf("expected empty ping result, got %v", resp)
+	}
+}
+
+// chanWriter passes each write on to a channel.
+type chanWriter struct {
+	ch chan string
+}
+
+func (b *chanWriter) Write(p []byte) (int, error) {
+	b.ch <- string(p)
+	return len(p), nil
+}
+
+func TestServeStdio(t *testing.T) {
+	s := newTestServer()
+	in, writer := io.Pipe()
+	out := &chanWriter{ch: make(chan string, 10)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeStdio(context.Background(), in, out)
+	}()
+
+	next := func() string {
+		select {
+		case line := <-out.ch:
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a response")
+			return ""
+		}
+	}
+
+	// The waiting call does not block later requests and is not answered
+	// once cancelled
+	io.WriteString(writer, `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "wait"}}`+"\n")
+	io.WriteString(writer, `{"jsonrpc": "2.0", "id": 2, "method": "ping"}`+"\n")
+	if line := next(); !strings.Contains(line, `"id":2`) || !strings.HasSuffix(line, "\n") {
+		t.Errorf("unexpected response %q", line)
+	}
+
+	io.WriteString(writer, `{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": 1}}`+"\n")
+	io.WriteString(writer, `{"jsonrpc": "2.0", "id": 3, "method": "ping"}`+"\n")
+	if line := next(); !strings.Contains(line, `"id":3`) {
+		t.Errorf("expected only the ping to be answered, got %q", line)
+	}
+
+	writer.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected ServeStdio to return at end of input")
+	}
+}
+
+func TestServeStdio_FinishesRequestsAtEndOfInput(t *testing.T) {
+	release := make(chan struct{})
+	slow := Tool{
+		Name: "slow",
+		Call: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			select {
+			case <-release:
+				return "finished", nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		},
+	}
+	s := NewServer("test", "1.0", []Tool{slow}, nil)
+	out := &chanWriter{ch: make(chan string, 10)}
+	in := strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "slow"}}` + "\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeStdio(context.Background(), in, out)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected ServeStdio to wait for the running call")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case line := <-out.ch:
+		if !strings.Contains(line, `"id":1`) || !strings.Contains(line, "finished") {
+			t.Errorf("unexpected response %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the call to be answered")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := newTestServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected JSON response, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Errorf("expected 202 without body for a notification, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for GET, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader(make([]byte, maxMessageSize+1)))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package prompt
+
+import "fmt"
+
+// Override states what happens to a system prompt sent with a request.
+type Override string
+
+const (
+	// OverrideDisabled rejects requests that send a system prompt.
+	OverrideDisabled Override = "disabled"
+	// OverrideAppend appends the request's system prompt to the configured
+	// one.
+	OverrideAppend Override = "append"
+	// OverrideReplace uses the request's system prompt instead of the
+	// configured one.
+	OverrideReplace Override = "replace"
+)
+
+// ParseOverride parses the name of an Override.
+func ParseOverride(s string) (Override, error) {
+	switch o := Override(s); o {
+	case OverrideDisabled, OverrideAppend, OverrideReplace:
+		return o, nil
+	}
+	return "", fmt.Errorf("expected disabled, append or replace, got %q", s)
+}
+
+// OverridePolicy selects the Override of a request by its API key or,
+// failing that, its origin. The zero policy disables overrides.
+type OverridePolicy struct {
+	Default Override
+	Origins map[string]Override
+	APIKeys map[string]Override
+}
+
+// For returns the Override of a request with the given origin and API key,
+// either of which may be empty.
+func (p OverridePolicy) For(origin, apiKey string) Override {
+	if o, ok := p.APIKeys[apiKey]; ok && apiKey != "" {
+		return o
+	}
+	if o, ok := p.Origins[origin]; ok && origin != "" {
+		return o
+	}
+	if p.Default == "" {
+		return OverrideDisabled
+	}
+	return p.Default
+}
@@ -0,0 +1,51 @@
+package prompt
+
+import "testing"
+
+func TestParseOverride(t *testing.T) {
+	for _, name := range []string{"disabled", "append", "replace"} {
+		o, err := ParseOverride(name)
+		if err != nil || string(o) != name {
+			t.Errorf("ParseOverride(%q) = %q, %v", name, o, err)
+		}
+	}
+
+	if _, err := ParseOverride("prepend"); err == nil {
+		t.Error("expected error for unknown override")
+	}
+}
+
+func TestOverridePolicy_For(t *testing.T) {
+	policy := OverridePolicy{
+		Default: OverrideAppend,
+		Origins: map[string]Override{"https://public.example.com": OverrideDisabled},
+		APIKeys: map[string]Override{"internal-key": OverrideReplace},
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		apiKey string
+		want   Override
+	}{
+		{"default", "", "", OverrideAppend},
+		{"origin", "https://public.example.com", "", OverrideDisabled},
+		{"api key", "", "internal-key", OverrideReplace},
+		{"api key wins over origin", "https://public.example.com", "internal-key", OverrideReplace},
+		{"unknown api key", "https://public.example.com", "other", OverrideDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.For(tt.origin, tt.apiKey); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOverridePolicy_ZeroValueDisables(t *testing.T) {
+	if got := (OverridePolicy{}).For("http://localhost:3000", "key"); got != OverrideDisabled {
+		t.Errorf("expected disabled, got %s", got)
+	}
+}
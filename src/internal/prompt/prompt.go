@@ -0,0 +1,298 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+const (
+	// frontMatterDelimiter opens and closes the front-matter of a prompt file.
+	frontMatterDelimiter = "---"
+	// TemplateExt is the extension of prompt files that are templates.
+	TemplateExt = ".tmpl"
+)
+
+// Prompt is a named system prompt. Its text is plain text, or a
+// text/template template rendered with Data if the prompt was parsed with
+// ParseTemplate.
+type Prompt struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Text        string `json:"-"`
+	// Variables are the request variables the prompt requires.
+	Variables []string `json:"variables,omitempty"`
+
+	tmpl *template.Template
+}
+
+// Library holds the default system prompt and the named system prompts of a
+// prompts directory. Both can be reloaded from disk while the library is in
+// use; a reload either replaces all prompts or none.
+type Library struct {
+	path string
+	dir  string
+
+	current atomic.Pointer[snapshot]
+
+	// fingerprint identifies the files of the last reload attempt, so that
+	// Run only reloads after a change.
+	fingerprint string
+}
+
+// snapshot is the set of prompts of a successful load.
+type snapshot struct {
+	system  Prompt
+	prompts map[string]Prompt
+}
+
+// Load reads the default system prompt from path and every file in dir as a
+// named system prompt. Either may be empty. The name of a prompt is its file
+// name without extension. Files with the TemplateExt extension are templates.
+// Hidden files and subdirectories are ignored.
+func Load(path, dir string) (*Library, error) {
+	l := &Library{path: path, dir: dir}
+	l.fingerprint = l.stat()
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload reads all prompts again. If any file is missing or invalid, the
+// previous prompts are kept and the error is returned.
+func (l *Library) Reload() error {
+	next := &snapshot{}
+
+	if l.path != "" {
+		system, err := readSystemPrompt(l.path)
+		if err != nil {
+			return err
+		}
+		next.system = system
+	}
+
+	if l.dir != "" {
+		prompts, err := readDir(l.dir)
+		if err != nil {
+			return err
+		}
+		next.prompts = prompts
+	}
+
+	l.current.Store(next)
+	return nil
+}
+
+// Run reloads the prompts every interval if their files changed, until ctx
+// is done. Failed reloads keep the previous prompts and are logged.
+func (l *Library) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fingerprint := l.stat()
+			if fingerprint == l.fingerprint {
+				continue
+			}
+			l.fingerprint = fingerprint
+
+			if err := l.Reload(); err != nil {
+				log.Printf("[ERROR] Failed to reload system prompts, keeping previous version: %v", err)
+				continue
+			}
+			log.Printf("[INFO] Reloaded system prompts")
+		}
+	}
+}
+
+// stat describes the size and modification time of all prompt files.
+func (l *Library) stat() string {
+	var b strings.Builder
+	describe := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:missing;", path)
+			return
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	if l.path != "" {
+		describe(l.path)
+	}
+	if l.dir != "" {
+		entries, _ := os.ReadDir(l.dir)
+		for _, entry := range entries {
+			describe(filepath.Join(l.dir, entry.Name()))
+		}
+	}
+	return b.String()
+}
+
+// readSystemPrompt reads the default system prompt file. Unlike prompts of
+// the prompts directory, it has no front-matter. It is a template if its
+// extension is TemplateExt.
+func readSystemPrompt(path string) (Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Prompt{}, fmt.Errorf("failed to read system prompt file: %w", err)
+	}
+
+	system := Prompt{Text: strings.TrimSpace(string(data))}
+	if system.Text == "" {
+		return Prompt{}, fmt.Errorf("system prompt file is empty: %s", path)
+	}
+	if filepath.Ext(path) == TemplateExt {
+		if err := system.compile(nil); err != nil {
+			return Prompt{}, fmt.Errorf("invalid system prompt file %s: %w", path, err)
+		}
+	}
+	return system, nil
+}
+
+// readDir reads every prompt file in dir.
+func readDir(dir string) (map[string]Prompt, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompts directory: %w", err)
+	}
+
+	prompts := make(map[string]Prompt, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, exists := prompts[name]; exists {
+			return nil, fmt.Errorf("invalid prompts directory: duplicate prompt %s", name)
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		}
+
+		p, err := parseFile(name, data, filepath.Ext(entry.Name()) == TemplateExt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt file %s: %w", path, err)
+		}
+		prompts[name] = p
+	}
+	return prompts, nil
+}
+
+// Parse parses a plain text prompt file. The file may start with
+// front-matter of "key: value" lines between two "---" lines. The only
+// supported key is description.
+func Parse(name string, data []byte) (Prompt, error) {
+	return parseFile(name, data, false)
+}
+
+// ParseTemplate parses a prompt file like Parse, but its text is a template.
+// The front-matter may also have the optional key, a comma separated list of
+// variables the template refers to but does not require. Templates that do
+// not parse or refer to unknown fields are rejected.
+func ParseTemplate(name string, data []byte) (Prompt, error) {
+	return parseFile(name, data, true)
+}
+
+// parseFile parses a prompt file, as a template if isTemplate is set.
+func parseFile(name string, data []byte, isTemplate bool) (Prompt, error) {
+	p := Prompt{Name: name}
+	var optional []string
+
+	text := strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	if rest, ok := strings.CutPrefix(text, frontMatterDelimiter+"\n"); ok {
+		header, body, ok := strings.Cut(rest, "\n"+frontMatterDelimiter)
+		if !ok {
+			return Prompt{}, errors.New("front-matter is not closed")
+		}
+		if err := p.parseFrontMatter(header, &optional); err != nil {
+			return Prompt{}, err
+		}
+		if len(optional) > 0 && !isTemplate {
+			return Prompt{}, fmt.Errorf("the optional front-matter key requires a %s file", TemplateExt)
+		}
+		text = strings.TrimSpace(body)
+	}
+
+	if text == "" {
+		return Prompt{}, errors.New("prompt is empty")
+	}
+	p.Text = text
+	if isTemplate {
+		if err := p.compile(optional); err != nil {
+			return Prompt{}, err
+		}
+	}
+	return p, nil
+}
+
+// parseFrontMatter applies the "key: value" lines of a front-matter header.
+func (p *Prompt) parseFrontMatter(header string, optional *[]string) error {
+	for _, line := range strings.Split(header, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("expected key: value in front-matter, got %q", line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch strings.TrimSpace(key) {
+		case "description":
+			p.Description = value
+		case "optional":
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					*optional = append(*optional, name)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown front-matter key %q", strings.TrimSpace(key))
+		}
+	}
+	return nil
+}
+
+// System returns the default system prompt.
+func (l *Library) System() Prompt {
+	return l.current.Load().system
+}
+
+// Get returns the named prompt.
+func (l *Library) Get(name string) (Prompt, bool) {
+	p, ok := l.current.Load().prompts[name]
+	return p, ok
+}
+
+// List returns all named prompts sorted by name.
+func (l *Library) List() []Prompt {
+	current := l.current.Load().prompts
+	prompts := make([]Prompt, 0, len(current))
+	for _, p := range current {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+	return prompts
+}
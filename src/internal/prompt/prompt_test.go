@@ -0,0 +1,354 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePromptFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+}
+
+func TestParse_FrontMatter(t *testing.T) {
+	p, err := Parse("support", []byte("---\ndescription: Customer support persona\n---\n\nYou are a support agent.\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Name != "support" {
+		t.Errorf("expected name support, got %q", p.Name)
+	}
+	if p.Description != "Customer support persona" {
+		t.Errorf("expected description, got %q", p.Description)
+	}
+	if p.Text != "You are a support agent." {
+		t.Errorf("expected trimmed text, got %q", p.Text)
+	}
+}
+
+func TestParse_WithoutFrontMatter(t *testing.T) {
+	p, err := Parse("plain", []byte("  You are terse.  \n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Description != "" {
+		t.Errorf("expected no description, got %q", p.Description)
+	}
+	if p.Text != "You are terse." {
+		t.Errorf("expected trimmed text, got %q", p.Text)
+	}
+}
+
+func TestParse_CRLF(t *testing.T) {
+	p, err := Parse("windows", []byte("---\r\ndescription: 'Quoted'\r\n---\r\nHello\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Description != "Quoted" || p.Text != "Hello" {
+		t.Errorf("unexpected prompt: %+v", p)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "   \n", "prompt is empty"},
+		{"only front-matter", "---\ndescription: x\n---\n", "prompt is empty"},
+		{"unclosed", "---\ndescription: x\nYou are terse.", "not closed"},
+		{"unknown key", "---\nauthor: me\n---\ntext", `unknown front-matter key "author"`},
+		{"malformed", "---\njust text\n---\ntext", "expected key: value"},
+		{"optional without template", "---\noptional: tier\n---\ntext", "requires a .tmpl file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("p", []byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "support.md", "---\ndescription: Support\n---\nYou help customers.")
+	writePromptFile(t, dir, "coder.txt", "You write Go.")
+	writePromptFile(t, dir, ".hidden", "ignored")
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := Load("", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list := lib.List()
+	if len(list) != 2 || list[0].Name != "coder" || list[1].Name != "support" {
+		t.Fatalf("expected prompts coder and support, got %+v", list)
+	}
+
+	p, ok := lib.Get("support")
+	if !ok {
+		t.Fatal("expected support prompt")
+	}
+	if p.Text != "You help customers." || p.Description != "Support" {
+		t.Errorf("unexpected prompt: %+v", p)
+	}
+	if _, ok := lib.Get("hidden"); ok {
+		t.Error("expected hidden file to be ignored")
+	}
+}
+
+func TestLoad_DuplicateName(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "support.md", "a")
+	writePromptFile(t, dir, "support.txt", "b")
+
+	_, err := Load("", dir)
+	if err == nil || !strings.Contains(err.Error(), "duplicate prompt support") {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "empty.md", "")
+
+	_, err := Load("", dir)
+	if err == nil || !strings.Contains(err.Error(), "empty.md") {
+		t.Errorf("expected error naming the file, got %v", err)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	if _, err := Load("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestLoad_SystemPrompt(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "system.txt", "  You are helpful.\n")
+
+	lib, err := Load(filepath.Join(dir, "system.txt"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lib.System().Text != "You are helpful." {
+		t.Errorf("expected trimmed system prompt, got %q", lib.System().Text)
+	}
+	if len(lib.List()) != 0 {
+		t.Errorf("expected no named prompts, got %+v", lib.List())
+	}
+}
+
+func TestLoad_SystemPromptWithLiteralBraces(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "system.txt", "Reply with {{ and }} escaped.")
+
+	lib, err := Load(filepath.Join(dir, "system.txt"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text, err := lib.System().Render(NewData("claude", nil))
+	if err != nil || text != "Reply with {{ and }} escaped." {
+		t.Errorf("expected system prompt as plain text, got %q, %v", text, err)
+	}
+}
+
+func TestLoad_Templates(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "system.tmpl", "You answer for {{.Vars.product}}.")
+	promptsDir := filepath.Join(dir, "prompts")
+	if err := os.Mkdir(promptsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePromptFile(t, promptsDir, "support.tmpl", "You support {{.Vars.product}} users.")
+	writePromptFile(t, promptsDir, "legacy.md", "Reply in {{ mustache }} syntax.")
+
+	lib, err := Load(filepath.Join(dir, "system.tmpl"), promptsDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := NewData("claude", map[string]string{"product": "Acme"})
+	if text, err := lib.System().Render(data); err != nil || text != "You answer for Acme." {
+		t.Errorf("expected rendered system prompt, got %q, %v", text, err)
+	}
+	support, _ := lib.Get("support")
+	if text, err := support.Render(data); err != nil || text != "You support Acme users." {
+		t.Errorf("expected rendered support prompt, got %q, %v", text, err)
+	}
+	legacy, _ := lib.Get("legacy")
+	if text, err := legacy.Render(data); err != nil || text != "Reply in {{ mustache }} syntax." {
+		t.Errorf("expected legacy prompt as plain text, got %q, %v", text, err)
+	}
+}
+
+func TestLoad_InvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "system.tmpl", "Today is {{.Today}}")
+
+	_, err := Load(filepath.Join(dir, "system.tmpl"), "")
+	if err == nil || !strings.Contains(err.Error(), "invalid system prompt file") {
+		t.Errorf("expected template error, got %v", err)
+	}
+
+	promptsDir := filepath.Join(dir, "prompts")
+	if err := os.Mkdir(promptsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePromptFile(t, promptsDir, "support.tmpl", "Hello {{.Vars.name")
+
+	_, err = Load("", promptsDir)
+	if err == nil || !strings.Contains(err.Error(), "support.tmpl") {
+		t.Errorf("expected template error naming the file, got %v", err)
+	}
+}
+
+func TestLoad_EmptySystemPrompt(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "system.txt", "\n")
+
+	_, err := Load(filepath.Join(dir, "system.txt"), "")
+	if err == nil || !strings.Contains(err.Error(), "system prompt file is empty") {
+		t.Errorf("expected empty error, got %v", err)
+	}
+}
+
+func TestLibrary_Reload(t *testing.T) {
+	dir := t.TempDir()
+	promptsDir := filepath.Join(dir, "prompts")
+	if err := os.Mkdir(promptsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePromptFile(t, dir, "system.txt", "Version 1")
+	writePromptFile(t, promptsDir, "support.md", "Support 1")
+
+	lib, err := Load(filepath.Join(dir, "system.txt"), promptsDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	writePromptFile(t, dir, "system.txt", "Version 2")
+	writePromptFile(t, promptsDir, "support.md", "Support 2")
+	if err := lib.Reload(); err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if p, _ := lib.Get("support"); lib.System().Text != "Version 2" || p.Text != "Support 2" {
+		t.Errorf("expected reloaded prompts, got %q and %q", lib.System().Text, p.Text)
+	}
+}
+
+func TestLibrary_ReloadKeepsPreviousOnError(t *testing.T) {
+	dir := t.TempDir()
+	promptsDir := filepath.Join(dir, "prompts")
+	if err := os.Mkdir(promptsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePromptFile(t, dir, "system.txt", "Version 1")
+	writePromptFile(t, promptsDir, "support.md", "Support 1")
+
+	lib, err := Load(filepath.Join(dir, "system.txt"), promptsDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A valid system prompt must not be applied while a named prompt is invalid
+	writePromptFile(t, dir, "system.txt", "Version 2")
+	writePromptFile(t, promptsDir, "support.md", "   ")
+	if err := lib.Reload(); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if p, _ := lib.Get("support"); lib.System().Text != "Version 1" || p.Text != "Support 1" {
+		t.Errorf("expected previous prompts, got %q and %q", lib.System().Text, p.Text)
+	}
+
+	writePromptFile(t, dir, "system.txt", "")
+	writePromptFile(t, promptsDir, "support.md", "Support 2")
+	if err := lib.Reload(); err == nil {
+		t.Fatal("expected reload error for empty system prompt")
+	}
+	if lib.System().Text != "Version 1" {
+		t.Errorf("expected previous system prompt, got %q", lib.System().Text)
+	}
+}
+
+func TestLibrary_ReloadKeepsPreviousOnTemplateError(t *testing.T) {
+	dir := t.TempDir()
+	promptsDir := filepath.Join(dir, "prompts")
+	if err := os.Mkdir(promptsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePromptFile(t, dir, "system.tmpl", "You answer for {{.Vars.product}}.")
+	writePromptFile(t, promptsDir, "support.tmpl", "You support {{.Vars.product}} users.")
+
+	lib, err := Load(filepath.Join(dir, "system.tmpl"), promptsDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := NewData("claude", map[string]string{"product": "Acme"})
+	tests := []struct {
+		name    string
+		system  string
+		support string
+	}{
+		{"system syntax error", "You answer for {{.Vars.product}.", "You support {{.Vars.product}} users."},
+		{"named unknown field", "You answer for {{.Vars.product}}.", "Today is {{.Today}}."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writePromptFile(t, dir, "system.tmpl", tt.system)
+			writePromptFile(t, promptsDir, "support.tmpl", tt.support)
+			if err := lib.Reload(); err == nil {
+				t.Fatal("expected reload error")
+			}
+
+			if text, err := lib.System().Render(data); err != nil || text != "You answer for Acme." {
+				t.Errorf("expected previous system prompt, got %q, %v", text, err)
+			}
+			support, _ := lib.Get("support")
+			if text, err := support.Render(data); err != nil || text != "You support Acme users." {
+				t.Errorf("expected previous support prompt, got %q, %v", text, err)
+			}
+		})
+	}
+}
+
+func TestLibrary_RunReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "system.txt")
+	writePromptFile(t, dir, "system.txt", "Version 1")
+
+	lib, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go lib.Run(ctx, 10*time.Millisecond)
+
+	writePromptFile(t, dir, "system.txt", "Version 2 is longer")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for lib.System().Text != "Version 2 is longer" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected system prompt to be reloaded, got %q", lib.System().Text)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,157 @@
+package prompt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// dateLayout is the layout of Data.Date.
+const dateLayout = "2006-01-02"
+
+// Data is the data system prompts are rendered with. Templates refer to
+// request variables as {{.Vars.name}}.
+type Data struct {
+	// Vars are the variables sent with the request.
+	Vars map[string]string
+	// Provider is the name of the requested provider.
+	Provider string
+	// Now is the time of the request, and Date its day as YYYY-MM-DD.
+	Now  time.Time
+	Date string
+}
+
+// NewData returns the data for a request to the named provider at the
+// current time.
+func NewData(providerName string, vars map[string]string) Data {
+	now := time.Now()
+	return Data{
+		Vars:     vars,
+		Provider: providerName,
+		Now:      now,
+		Date:     now.Format(dateLayout),
+	}
+}
+
+// MissingVariablesError is returned by Render if the request lacks variables
+// the prompt requires.
+type MissingVariablesError struct {
+	Names []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return "missing variables: " + strings.Join(e.Names, ", ")
+}
+
+// compile parses the text of p as a template and derives the variables it
+// requires: every request variable it refers to, except the optional ones.
+// The template is executed once to reject references to unknown fields.
+func (p *Prompt) compile(optional []string) error {
+	tmpl, err := template.New(p.Name).Option("missingkey=zero").Parse(p.Text)
+	if err != nil {
+		return err
+	}
+
+	referenced := make(map[string]bool)
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectVars(t.Tree.Root, referenced)
+		}
+	}
+
+	vars := make(map[string]string, len(referenced))
+	for name := range referenced {
+		vars[name] = name
+		if !slices.Contains(optional, name) {
+			p.Variables = append(p.Variables, name)
+		}
+	}
+	slices.Sort(p.Variables)
+
+	if err := tmpl.Execute(&strings.Builder{}, NewData("", vars)); err != nil {
+		return err
+	}
+
+	p.tmpl = tmpl
+	return nil
+}
+
+// Render renders the prompt with data. It returns a MissingVariablesError
+// listing all required variables data lacks.
+func (p Prompt) Render(data Data) (string, error) {
+	var missing []string
+	for _, name := range p.Variables {
+		if _, ok := data.Vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", &MissingVariablesError{Names: missing}
+	}
+
+	if p.tmpl == nil {
+		return p.Text, nil
+	}
+
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// collectVars adds the request variables referred to as .Vars.name or
+// $.Vars.name below node to vars.
+func collectVars(node parse.Node, vars map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectVars(child, vars)
+		}
+	case *parse.ActionNode:
+		collectVars(n.Pipe, vars)
+	case *parse.IfNode:
+		collectBranchVars(&n.BranchNode, vars)
+	case *parse.RangeNode:
+		collectBranchVars(&n.BranchNode, vars)
+	case *parse.WithNode:
+		collectBranchVars(&n.BranchNode, vars)
+	case *parse.TemplateNode:
+		collectVars(n.Pipe, vars)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectVars(cmd, vars)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectVars(arg, vars)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Vars" {
+			vars[n.Ident[1]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "Vars" {
+			vars[n.Ident[2]] = true
+		}
+	case *parse.ChainNode:
+		collectVars(n.Node, vars)
+	}
+}
+
+// collectBranchVars adds the request variables of an if, range or with
+// block to vars.
+func collectBranchVars(n *parse.BranchNode, vars map[string]bool) {
+	collectVars(n.Pipe, vars)
+	collectVars(n.List, vars)
+	collectVars(n.ElseList, vars)
+}
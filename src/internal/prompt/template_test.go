@@ -0,0 +1,116 @@
+package prompt
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_Variables(t *testing.T) {
+	p, err := ParseTemplate("support", []byte(`---
+optional: tier
+---
+You support {{.Vars.product}} users in {{$.Vars.locale}}.
+{{if .Vars.tier}}The user has the {{.Vars.tier}} plan.{{end}}
+{{with .Vars.product}}{{.}}{{end}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !slices.Equal(p.Variables, []string{"locale", "product"}) {
+		t.Errorf("expected required variables locale and product, got %v", p.Variables)
+	}
+}
+
+func TestParse_InvalidTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"syntax", "Hello {{.Vars.name"},
+		{"unknown field", "Today is {{.Today}}"},
+		{"unknown function", "{{shout .Vars.name}}"},
+		{"unknown template", `{{template "footer"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTemplate("p", []byte(tt.content)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	p, err := ParseTemplate("support", []byte(`---
+optional: tier
+---
+You support {{.Vars.product}} users on {{.Provider}} as of {{.Date}}.{{if .Vars.tier}} Plan: {{.Vars.tier}}.{{end}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := Data{
+		Vars:     map[string]string{"product": "Acme"},
+		Provider: "claude",
+		Now:      time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Date:     "2025-03-01",
+	}
+	text, err := p.Render(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "You support Acme users on claude as of 2025-03-01." {
+		t.Errorf("unexpected text: %q", text)
+	}
+
+	data.Vars["tier"] = "pro"
+	text, _ = p.Render(data)
+	if !strings.HasSuffix(text, "Plan: pro.") {
+		t.Errorf("expected optional variable to be rendered, got %q", text)
+	}
+}
+
+func TestRender_MissingVariables(t *testing.T) {
+	p, err := ParseTemplate("support", []byte("{{.Vars.product}} {{.Vars.locale}} {{.Vars.tier}}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = p.Render(NewData("claude", map[string]string{"locale": "de"}))
+	var missing *MissingVariablesError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingVariablesError, got %v", err)
+	}
+	if !slices.Equal(missing.Names, []string{"product", "tier"}) {
+		t.Errorf("expected missing product and tier, got %v", missing.Names)
+	}
+}
+
+func TestRender_PlainText(t *testing.T) {
+	p, err := Parse("plain", []byte("You are terse."))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	text, err := p.Render(NewData("claude", nil))
+	if err != nil || text != "You are terse." {
+		t.Errorf("expected plain text, got %q, %v", text, err)
+	}
+	if len(p.Variables) != 0 {
+		t.Errorf("expected no variables, got %v", p.Variables)
+	}
+}
+
+func TestNewData(t *testing.T) {
+	data := NewData("gemini", map[string]string{"a": "b"})
+	if data.Provider != "gemini" || data.Vars["a"] != "b" {
+		t.Errorf("unexpected data: %+v", data)
+	}
+	if data.Date != data.Now.Format("2006-01-02") {
+		t.Errorf("expected date of now, got %q", data.Date)
+	}
+}
@@ -1,46 +1,215 @@
 package provider
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
+	"fmt"
 	"strings"
 )
 
+// claudeJSONSchema is the schema of plain text responses, which Claude
+// reports as structured_output.response.
 const claudeJSONSchema = `{"type":"object","properties":{"response":{"type":"string"}},"required":["response"]}`
 
 // ClaudeClient implements Generator using the Claude CLI.
-type ClaudeClient struct{}
+type ClaudeClient struct {
+	model string
+}
 
 // NewClaudeClient creates a new Claude CLI client.
 func NewClaudeClient() *ClaudeClient {
 	return &ClaudeClient{}
 }
 
+// WithModel returns a copy of the client that passes --model to the Claude CLI.
+func (c *ClaudeClient) WithModel(model string) Generator {
+	return &ClaudeClient{model: model}
+}
+
+// Probe checks the Claude CLI installation. Authentication is detected from
+// an API key or the credentials file; credentials stored in the macOS
+// keychain are reported as unknown.
+func (c *ClaudeClient) Probe(ctx context.Context) Status {
+	return probeCLI(ctx, "claude", []string{"--version"},
+		envOrFileAuth([]string{"ANTHROPIC_API_KEY", "CLAUDE_CODE_OAUTH_TOKEN"}, []string{".claude/.credentials.json"}))
+}
+
 // Generate calls the Claude CLI with a system prompt and user prompt.
-func (c *ClaudeClient) Generate(systemPrompt, userPrompt string) (string, error) {
-	cmd := exec.Command("claude",
+func (c *ClaudeClient) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	result, _, err := c.GenerateTurn(ctx, systemPrompt, userPrompt, "")
+	return result, err
+}
+
+// GenerateTurn calls the Claude CLI, resuming the given Claude session if
+// sessionID is set.
+func (c *ClaudeClient) GenerateTurn(ctx context.Context, systemPrompt, userPrompt, sessionID string) (string, string, error) {
+	stdout, nextSessionID, err := c.runTurn(ctx, systemPrompt, userPrompt, sessionID, claudeJSONSchema)
+	if err != nil {
+		return "", "", err
+	}
+
+	result, err := parseClaudeResponse(stdout)
+	if err != nil {
+		return "", "", err
+	}
+	return result, nextSessionID, nil
+}
+
+// GenerateWithUsage calls the Claude CLI and reports the token usage of the
+// run.
+func (c *ClaudeClient) GenerateWithUsage(ctx context.Context, systemPrompt, userPrompt string) (string, Usage, error) {
+	stdout, _, err := c.runTurn(ctx, systemPrompt, userPrompt, "", claudeJSONSchema)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	result, err := parseClaudeResponse(stdout)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return result, parseClaudeUsage(stdout), nil
+}
+
+// GenerateJSON calls the Claude CLI with the given JSON Schema and returns
+// the structured output.
+func (c *ClaudeClient) GenerateJSON(ctx context.Context, systemPrompt, userPrompt string, schema json.RawMessage) (string, error) {
+	stdout, _, err := c.runTurn(ctx, systemPrompt, userPrompt, "", string(schema))
+	if err != nil {
+		return "", err
+	}
+	return parseClaudeStructuredOutput(stdout)
+}
+
+// runTurn runs a single turn constrained to schema and returns the CLI's
+// output and the session ID to resume.
+func (c *ClaudeClient) runTurn(ctx context.Context, systemPrompt, userPrompt, sessionID, schema string) ([]byte, string, error) {
+	args := []string{
 		"-p", userPrompt,
 		"--append-system-prompt", systemPrompt,
 		"--output-format", "json",
-		"--json-schema", claudeJSONSchema,
-	)
+		"--json-schema", schema,
+	}
+	if c.model != "" {
+		args = append(args, "--model", c.model)
+	}
+	if sessionID != "" {
+		args = append(args, "--resume", sessionID)
+	}
+
+	stdout, err := runCommand(ctx, "claude", args...)
+	if cliErr := parseClaudeError(stdout); cliErr != nil {
+		return nil, "", cliErr
+	}
+	if err != nil {
+		return nil, "", err
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	nextSessionID := parseClaudeSessionID(stdout)
+	if nextSessionID == "" {
+		nextSessionID = sessionID
+	}
+
+	return stdout, nextSessionID, nil
+}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+// GenerateStream calls the Claude CLI with stream-json output and reports
+// text deltas as they arrive.
+func (c *ClaudeClient) GenerateStream(ctx context.Context, systemPrompt, userPrompt string, onDelta func(string)) (string, error) {
+	args := []string{
+		"-p", userPrompt,
+		"--append-system-prompt", systemPrompt,
+		"--output-format", "stream-json",
+		"--include-partial-messages",
+		"--verbose",
+	}
+	if c.model != "" {
+		args = append(args, "--model", c.model)
 	}
 
-	result, err := parseClaudeResponse(stdout.Bytes())
+	stdout, err := streamCommand(ctx, func(line []byte) {
+		if delta := claudeStreamDelta(line); delta != "" {
+			onDelta(delta)
+		}
+	}, "claude", args...)
+	if cliErr := parseClaudeError(stdout); cliErr != nil {
+		return "", cliErr
+	}
 	if err != nil {
 		return "", err
 	}
 
-	return result, nil
+	return parseClaudeStreamResponse(stdout)
+}
+
+// claudeStreamEvent represents a single NDJSON event from Claude's stream-json output.
+type claudeStreamEvent struct {
+	Type  string `json:"type"`
+	Event *struct {
+		Type  string `json:"type"`
+		Delta *struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta,omitempty"`
+	} `json:"event,omitempty"`
+	Result string `json:"result,omitempty"`
+}
+
+// claudeStreamDelta returns the text delta carried by a stream-json line, if any.
+func claudeStreamDelta(line []byte) string {
+	var event claudeStreamEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return ""
+	}
+
+	// Partial messages arrive as: {"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"..."}}}
+	if event.Type == "stream_event" && event.Event != nil && event.Event.Type == "content_block_delta" &&
+		event.Event.Delta != nil && event.Event.Delta.Type == "text_delta" {
+		return event.Event.Delta.Text
+	}
+
+	return ""
+}
+
+// parseClaudeStreamResponse extracts the final response from Claude's stream-json output.
+func parseClaudeStreamResponse(data []byte) (string, error) {
+	// The last event is: {"type":"result","result":"...", ...}
+	var result string
+	var deltas strings.Builder
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var event claudeStreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+
+		if event.Type == "result" && event.Result != "" {
+			result = event.Result
+		}
+		deltas.WriteString(claudeStreamDelta(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrParsing, err)
+	}
+
+	if trimmed := strings.TrimSpace(result); trimmed != "" {
+		return trimmed, nil
+	}
+
+	// Fallback: use the concatenated deltas if no result event was emitted
+	if trimmed := strings.TrimSpace(deltas.String()); trimmed != "" {
+		return trimmed, nil
+	}
+
+	return "", ErrParsing
 }
 
 // parseClaudeResponse extracts the response from Claude's JSON output.
@@ -64,3 +233,97 @@ func parseClaudeResponse(data []byte) (string, error) {
 
 	return "", ErrParsing
 }
+
+// parseClaudeStructuredOutput extracts the JSON response of a run with a
+// caller-supplied JSON Schema from Claude's JSON output.
+func parseClaudeStructuredOutput(data []byte) (string, error) {
+	// Claude returns: {"structured_output": {...}, "result": "...", ...}
+	var response struct {
+		StructuredOutput json.RawMessage `json:"structured_output"`
+		Result           string          `json:"result"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return "", ErrParsing
+	}
+
+	if len(response.StructuredOutput) > 0 && string(response.StructuredOutput) != "null" {
+		return string(response.StructuredOutput), nil
+	}
+
+	// Fallback: the result text may still hold the JSON response
+	if trimmed := strings.TrimSpace(response.Result); trimmed != "" {
+		return trimmed, nil
+	}
+
+	return "", ErrParsing
+}
+
+// claudeResult is the final result event of Claude's json and stream-json
+// output.
+type claudeResult struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	IsError bool   `json:"is_error"`
+	Result  string `json:"result"`
+}
+
+// parseClaudeError returns the error reported by the result event of Claude's
+// output, or nil if there is none.
+func parseClaudeError(data []byte) error {
+	// Errors are reported as: {"type":"result","subtype":"success","is_error":true,"result":"Invalid API key · Please run /login"}
+	var result claudeResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			var event claudeResult
+			if err := json.Unmarshal(scanner.Bytes(), &event); err == nil && event.Type == "result" {
+				result = event
+			}
+		}
+	}
+
+	if !result.IsError {
+		return nil
+	}
+
+	message := result.Result
+	if message == "" {
+		message = result.Subtype
+	}
+	return newCLIError(message)
+}
+
+// parseClaudeSessionID extracts the session ID from Claude's JSON output.
+func parseClaudeSessionID(data []byte) string {
+	var response struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return ""
+	}
+	return response.SessionID
+}
+
+// parseClaudeUsage extracts the token usage from Claude's JSON output. Input
+// tokens include those written to and read from the prompt cache.
+func parseClaudeUsage(data []byte) Usage {
+	// Claude reports: {"usage": {"input_tokens": 4, "cache_creation_input_tokens": 0, "cache_read_input_tokens": 0, "output_tokens": 12}, ...}
+	var response struct {
+		Usage struct {
+			InputTokens              int `json:"input_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+			OutputTokens             int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Usage{}
+	}
+
+	u := response.Usage
+	return Usage{
+		InputTokens:  u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+		OutputTokens: u.OutputTokens,
+	}
+}
@@ -91,3 +91,163 @@ func TestParseClaudeResponse_FullResponse(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, result)
 	}
 }
+
+func TestParseClaudeStructuredOutput(t *testing.T) {
+	input := `{"type":"result","result":"","structured_output":{"name":"Ada","tags":["math"]}}`
+
+	result, err := parseClaudeStructuredOutput([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"name":"Ada","tags":["math"]}`
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestParseClaudeStructuredOutput_ResultFallback(t *testing.T) {
+	input := `{"type":"result","result":" {\"name\":\"Ada\"} ","structured_output":null}`
+
+	result, err := parseClaudeStructuredOutput([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result != `{"name":"Ada"}` {
+		t.Errorf("expected result text, got %q", result)
+	}
+}
+
+func TestParseClaudeStructuredOutput_Empty(t *testing.T) {
+	for _, input := range []string{``, `not json`, `{"type":"result","result":""}`} {
+		if _, err := parseClaudeStructuredOutput([]byte(input)); err != ErrParsing {
+			t.Errorf("expected ErrParsing for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestClaudeStreamDelta(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "text delta",
+			input:    `{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}}`,
+			expected: "Hel",
+		},
+		{
+			name:     "message start",
+			input:    `{"type":"stream_event","event":{"type":"message_start"}}`,
+			expected: "",
+		},
+		{
+			name:     "assistant message",
+			input:    `{"type":"assistant","message":{"content":[{"type":"text","text":"Hello"}]}}`,
+			expected: "",
+		},
+		{
+			name:     "invalid json",
+			input:    `not json`,
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := claudeStreamDelta([]byte(tc.input)); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseClaudeStreamResponse_ResultEvent(t *testing.T) {
+	input := `{"type":"system","subtype":"init","session_id":"abc123"}
+{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"Hello"}}}
+{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":", world!"}}}
+{"type":"result","subtype":"success","result":"Hello, world!","session_id":"abc123"}`
+
+	result, err := parseClaudeStreamResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result != "Hello, world!" {
+		t.Errorf("expected %q, got %q", "Hello, world!", result)
+	}
+}
+
+func TestParseClaudeStreamResponse_DeltasWithoutResult(t *testing.T) {
+	input := `{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"Hello"}}}
+{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":", world!"}}}`
+
+	result, err := parseClaudeStreamResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result != "Hello, world!" {
+		t.Errorf("expected %q, got %q", "Hello, world!", result)
+	}
+}
+
+func TestParseClaudeStreamResponse_Empty(t *testing.T) {
+	_, err := parseClaudeStreamResponse([]byte(`{"type":"system","subtype":"init"}`))
+	if err != ErrParsing {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestParseClaudeSessionID(t *testing.T) {
+	input := `{"type":"result","subtype":"success","structured_output":{"response":"Hi"},"session_id":"abc123"}`
+
+	if got := parseClaudeSessionID([]byte(input)); got != "abc123" {
+		t.Errorf("expected %q, got %q", "abc123", got)
+	}
+
+	if got := parseClaudeSessionID([]byte(`not json`)); got != "" {
+		t.Errorf("expected empty session ID for raw text, got %q", got)
+	}
+}
+
+func TestParseClaudeUsage(t *testing.T) {
+	input := `{"type":"result","structured_output":{"response":"Hi"},"usage":{"input_tokens":4,"cache_creation_input_tokens":100,"cache_read_input_tokens":20,"output_tokens":12}}`
+
+	if got := parseClaudeUsage([]byte(input)); got != (Usage{InputTokens: 124, OutputTokens: 12}) {
+		t.Errorf("unexpected usage %+v", got)
+	}
+
+	if got := parseClaudeUsage([]byte(`not json`)); got != (Usage{}) {
+		t.Errorf("expected no usage for raw text, got %+v", got)
+	}
+}
+
+func TestParseClaudeError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ErrorCode
+	}{
+		{"json auth", `{"type":"result","subtype":"success","is_error":true,"result":"Invalid API key · Please run /login"}`, CodeAuthRequired},
+		{"json usage limit", `{"type":"result","subtype":"success","is_error":true,"result":"Claude AI usage limit reached|1760000000"}`, CodeQuotaExhausted},
+		{"stream", `{"type":"system","subtype":"init"}
+{"type":"result","subtype":"error_during_execution","is_error":true,"result":"API Error: 429 rate_limit_error"}`, CodeRateLimited},
+		{"subtype only", `{"type":"result","subtype":"error_max_turns","is_error":true}`, CodeCLIFailure},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parseClaudeError([]byte(tc.input))
+			if got := Classify(err); got == nil || got.Code != tc.want {
+				t.Errorf("expected %s, got %v", tc.want, err)
+			}
+		})
+	}
+
+	if err := parseClaudeError([]byte(`{"type":"result","subtype":"success","is_error":false,"result":"Hi"}`)); err != nil {
+		t.Errorf("expected no error for a successful result, got %v", err)
+	}
+}
@@ -3,43 +3,116 @@ package provider
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
+	"fmt"
 	"strings"
 )
 
 // CodexClient implements Generator using the Codex CLI.
-type CodexClient struct{}
+type CodexClient struct {
+	model string
+}
 
 // NewCodexClient creates a new Codex CLI client.
 func NewCodexClient() *CodexClient {
 	return &CodexClient{}
 }
 
+// WithModel returns a copy of the client that passes --model to the Codex CLI.
+func (c *CodexClient) WithModel(model string) Generator {
+	return &CodexClient{model: model}
+}
+
+// execArgs returns the arguments of "codex exec" up to the prompt.
+func (c *CodexClient) execArgs() []string {
+	args := []string{"exec", "--json"}
+	if c.model != "" {
+		args = append(args, "--model", c.model)
+	}
+	return args
+}
+
+// Probe checks the Codex CLI installation. Authentication is checked with
+// "codex login status".
+func (c *CodexClient) Probe(ctx context.Context) Status {
+	return probeCLI(ctx, "codex", []string{"--version"}, commandAuth("codex", "login", "status"))
+}
+
 // Generate calls the Codex CLI with a system prompt and user prompt.
-func (c *CodexClient) Generate(systemPrompt, userPrompt string) (string, error) {
-	prompt := systemPrompt + "\n\n" + userPrompt
+func (c *CodexClient) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	result, _, err := c.GenerateTurn(ctx, systemPrompt, userPrompt, "")
+	return result, err
+}
 
-	cmd := exec.Command("codex", "exec",
-		prompt,
-		"--json",
-	)
+// GenerateTurn calls the Codex CLI, resuming the given Codex thread if
+// sessionID is set. The system prompt is only sent with the first turn, as a
+// resumed thread already contains it.
+func (c *CodexClient) GenerateTurn(ctx context.Context, systemPrompt, userPrompt, sessionID string) (string, string, error) {
+	result, threadID, _, err := c.runTurn(ctx, systemPrompt, userPrompt, sessionID)
+	return result, threadID, err
+}
+
+// GenerateWithUsage calls the Codex CLI and reports the token usage of the
+// run.
+func (c *CodexClient) GenerateWithUsage(ctx context.Context, systemPrompt, userPrompt string) (string, Usage, error) {
+	result, _, stdout, err := c.runTurn(ctx, systemPrompt, userPrompt, "")
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return result, parseCodexUsage(stdout), nil
+}
+
+// runTurn runs a single turn and also returns the CLI's output.
+func (c *CodexClient) runTurn(ctx context.Context, systemPrompt, userPrompt, sessionID string) (string, string, []byte, error) {
+	args := append(c.execArgs(), systemPrompt+"\n\n"+userPrompt)
+	if sessionID != "" {
+		args = append(c.execArgs(), "resume", sessionID, userPrompt)
+	}
+
+	stdout, err := runCommand(ctx, "codex", args...)
+	if cliErr := parseCodexError(stdout); cliErr != nil {
+		return "", "", nil, cliErr
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	result, err := parseCodexResponse(stdout)
+	if err != nil {
+		return "", "", nil, err
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	threadID := parseCodexThreadID(stdout)
+	if threadID == "" {
+		threadID = sessionID
 	}
 
-	result, err := parseCodexResponse(stdout.Bytes())
+	return result, threadID, stdout, nil
+}
+
+// GenerateStream calls the Codex CLI and reports each agent message as soon
+// as Codex emits it.
+func (c *CodexClient) GenerateStream(ctx context.Context, systemPrompt, userPrompt string, onDelta func(string)) (string, error) {
+	prompt := systemPrompt + "\n\n" + userPrompt
+
+	stdout, err := streamCommand(ctx, func(line []byte) {
+		var event codexEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return
+		}
+		if text := event.text(); text != "" {
+			onDelta(text)
+		}
+	}, "codex", append(c.execArgs(), prompt)...)
+	if cliErr := parseCodexError(stdout); cliErr != nil {
+		return "", cliErr
+	}
 	if err != nil {
 		return "", err
 	}
 
-	return result, nil
+	return parseCodexResponse(stdout)
 }
 
 // codexEvent represents a single NDJSON event from Codex.
@@ -54,6 +127,27 @@ type codexEvent struct {
 		Text string `json:"text"`
 	} `json:"item,omitempty"`
 	Response string `json:"response,omitempty"`
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// text returns the assistant text carried by the event, if any.
+func (e codexEvent) text() string {
+	// Check for direct response field
+	if e.Response != "" {
+		return e.Response
+	}
+
+	// Check for message events with assistant role (alternative format)
+	if e.Message != nil && e.Message.Role == "assistant" && e.Message.Content != "" {
+		return e.Message.Content
+	}
+
+	// Check for item.completed events with agent_message type (actual Codex CLI format)
+	if e.Type == "item.completed" && e.Item != nil && e.Item.Type == "agent_message" && e.Item.Text != "" {
+		return e.Item.Text
+	}
+
+	return ""
 }
 
 // parseCodexResponse extracts the response from Codex's NDJSON output.
@@ -64,6 +158,7 @@ func parseCodexResponse(data []byte) (string, error) {
 	var lastContent string
 
 	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
@@ -75,21 +170,13 @@ func parseCodexResponse(data []byte) (string, error) {
 			continue
 		}
 
-		// Check for item.completed events with agent_message type (actual Codex CLI format)
-		if event.Type == "item.completed" && event.Item != nil && event.Item.Type == "agent_message" && event.Item.Text != "" {
-			lastContent = event.Item.Text
-		}
-
-		// Check for message events with assistant role (alternative format)
-		if event.Message != nil && event.Message.Role == "assistant" && event.Message.Content != "" {
-			lastContent = event.Message.Content
-		}
-
-		// Check for direct response field
-		if event.Response != "" {
-			lastContent = event.Response
+		if text := event.text(); text != "" {
+			lastContent = text
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrParsing, err)
+	}
 
 	if lastContent != "" {
 		return CleanResponse(lastContent), nil
@@ -103,3 +190,89 @@ func parseCodexResponse(data []byte) (string, error) {
 
 	return "", ErrParsing
 }
+
+// parseCodexError returns the error that made a Codex turn fail, or nil if
+// there is none.
+func parseCodexError(data []byte) error {
+	// A failed turn ends with: {"type":"turn.failed","error":{"message":"..."}}
+	// Codex also emits {"type":"error","message":"..."} events, some of them
+	// for errors it recovers from, so these only count if the turn did not
+	// complete afterwards.
+	var message string
+	var failed bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var event struct {
+			Type    string `json:"type"`
+			Message any    `json:"message"`
+			Error   *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "turn.failed":
+			if event.Error != nil {
+				message = event.Error.Message
+			}
+			failed = true
+		case "error":
+			if text, ok := event.Message.(string); ok {
+				message = text
+			}
+			failed = true
+		case "turn.completed":
+			failed = false
+		}
+	}
+
+	if !failed {
+		return nil
+	}
+	return newCLIError(message)
+}
+
+// parseCodexThreadID extracts the thread ID from Codex's NDJSON output.
+func parseCodexThreadID(data []byte) string {
+	// The first event is: {"type":"thread.started","thread_id":"..."}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var event codexEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Type == "thread.started" && event.ThreadID != "" {
+			return event.ThreadID
+		}
+	}
+	return ""
+}
+
+// parseCodexUsage sums the token usage of the completed turns in Codex's
+// output. Codex counts cached input tokens as part of the input tokens.
+func parseCodexUsage(data []byte) Usage {
+	// A completed turn reports: {"type":"turn.completed","usage":{"input_tokens":10,"cached_input_tokens":0,"output_tokens":5}}
+	var usage Usage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var event struct {
+			Type  string `json:"type"`
+			Usage *Usage `json:"usage"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Type == "turn.completed" && event.Usage != nil {
+			usage.InputTokens += event.Usage.InputTokens
+			usage.OutputTokens += event.Usage.OutputTokens
+		}
+	}
+	return usage
+}
@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -142,3 +144,69 @@ func TestParseCodexResponse_InvalidJSON(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, sql)
 	}
 }
+
+func TestParseCodexResponse_LongLine(t *testing.T) {
+	// Lines longer than bufio.Scanner's default limit of 64 KiB
+	text := strings.Repeat("a", 100*1024)
+	input := `{"type":"item.completed","item":{"type":"agent_message","text":"` + text + `"}}`
+
+	got, err := parseCodexResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != text {
+		t.Errorf("expected %d characters, got %d", len(text), len(got))
+	}
+
+	_, err = parseCodexResponse([]byte(strings.Repeat("a", maxLineSize+1)))
+	if !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing for a line over the limit, got %v", err)
+	}
+}
+
+func TestParseCodexThreadID(t *testing.T) {
+	input := `{"type":"thread.started","thread_id":"thread_abc123"}
+{"type":"turn.started"}
+{"type":"item.completed","item":{"id":"item_0","type":"agent_message","text":"Hi"}}`
+
+	if got := parseCodexThreadID([]byte(input)); got != "thread_abc123" {
+		t.Errorf("expected %q, got %q", "thread_abc123", got)
+	}
+
+	if got := parseCodexThreadID([]byte(`{"type":"turn.started"}`)); got != "" {
+		t.Errorf("expected empty thread ID, got %q", got)
+	}
+}
+
+func TestParseCodexUsage(t *testing.T) {
+	input := `{"type":"thread.started","thread_id":"t1"}
+{"type":"turn.started"}
+{"type":"item.completed","item":{"type":"agent_message","text":"Hi"}}
+{"type":"turn.completed","usage":{"input_tokens":2000,"cached_input_tokens":1500,"output_tokens":30}}`
+
+	if got := parseCodexUsage([]byte(input)); got != (Usage{InputTokens: 2000, OutputTokens: 30}) {
+		t.Errorf("unexpected usage %+v", got)
+	}
+}
+
+func TestParseCodexError(t *testing.T) {
+	input := `{"type":"thread.started","thread_id":"t1"}
+{"type":"turn.started"}
+{"type":"error","message":"stream disconnected; retrying 1/5"}
+{"type":"turn.failed","error":{"message":"You've hit your usage limit."}}`
+
+	err := parseCodexError([]byte(input))
+	if got := Classify(err); got == nil || got.Code != CodeQuotaExhausted {
+		t.Fatalf("expected quota_exhausted, got %v", err)
+	}
+}
+
+func TestParseCodexError_RecoveredError(t *testing.T) {
+	input := `{"type":"error","message":"stream disconnected; retrying 1/5"}
+{"type":"item.completed","item":{"type":"agent_message","text":"Hello"}}
+{"type":"turn.completed"}`
+
+	if err := parseCodexError([]byte(input)); err != nil {
+		t.Errorf("expected no error for a completed turn, got %v", err)
+	}
+}
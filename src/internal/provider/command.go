@@ -0,0 +1,280 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Prompt modes of a CommandSpec.
+const (
+	PromptModeArgv  = "argv"
+	PromptModeStdin = "stdin"
+)
+
+// Output formats of an OutputSpec.
+const (
+	OutputFormatText   = "text"
+	OutputFormatJSON   = "json"
+	OutputFormatNDJSON = "ndjson"
+)
+
+// Placeholders replaced in CommandSpec arguments.
+const (
+	placeholderSystem = "{{system}}"
+	placeholderUser   = "{{user}}"
+	placeholderModel  = "{{model}}"
+)
+
+// CommandSpec declares a provider backed by an arbitrary CLI.
+type CommandSpec struct {
+	// Name is the provider name used in requests.
+	Name string `json:"name"`
+	// Description is the human-readable name shown by GET /providers.
+	Description string `json:"description,omitempty"`
+	// Command is the CLI binary to run.
+	Command string `json:"command"`
+	// Args is the argument template. "{{system}}" and "{{user}}" are
+	// replaced with the system and user prompt.
+	Args []string `json:"args,omitempty"`
+	// ModelArgs are appended to Args when a model is selected. "{{model}}"
+	// is replaced with the model name.
+	ModelArgs []string `json:"model_args,omitempty"`
+	// VersionArgs are the arguments that print the CLI version. Defaults to
+	// "--version".
+	VersionArgs []string `json:"version_args,omitempty"`
+	// PromptMode is "argv" (default) to pass the prompts through the
+	// placeholders in Args, or "stdin" to write the system prompt and user
+	// prompt to the CLI's standard input.
+	PromptMode string `json:"prompt_mode,omitempty"`
+	// Output describes how to extract the response from stdout.
+	Output OutputSpec `json:"output"`
+}
+
+// OutputSpec describes how to extract the response from a CLI's stdout.
+type OutputSpec struct {
+	// Format is "text" (default), "json" or "ndjson".
+	Format string `json:"format,omitempty"`
+	// Path is the dot-separated path of the response field, e.g.
+	// "result" or "choices.0.message.content". Required for json and ndjson.
+	Path string `json:"path,omitempty"`
+	// MatchField and MatchValue restrict ndjson parsing to events whose
+	// MatchField equals MatchValue. MatchField defaults to "type".
+	MatchField string `json:"match_field,omitempty"`
+	MatchValue string `json:"match_value,omitempty"`
+}
+
+// Validate checks the spec and fills in defaults.
+func (s *CommandSpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("provider name is required")
+	}
+	if s.Command == "" {
+		return fmt.Errorf("provider %s: command is required", s.Name)
+	}
+
+	switch s.PromptMode {
+	case "":
+		s.PromptMode = PromptModeArgv
+		fallthrough
+	case PromptModeArgv:
+		if !containsPlaceholder(s.Args, placeholderUser) {
+			return fmt.Errorf("provider %s: args must contain %s in %s prompt mode", s.Name, placeholderUser, PromptModeArgv)
+		}
+	case PromptModeStdin:
+	default:
+		return fmt.Errorf("provider %s: unknown prompt mode %q", s.Name, s.PromptMode)
+	}
+
+	if len(s.ModelArgs) > 0 && !containsPlaceholder(s.ModelArgs, placeholderModel) {
+		return fmt.Errorf("provider %s: model_args must contain %s", s.Name, placeholderModel)
+	}
+
+	switch s.Output.Format {
+	case "":
+		s.Output.Format = OutputFormatText
+	case OutputFormatText:
+	case OutputFormatJSON, OutputFormatNDJSON:
+		if s.Output.Path == "" {
+			return fmt.Errorf("provider %s: output path is required for %s output", s.Name, s.Output.Format)
+		}
+	default:
+		return fmt.Errorf("provider %s: unknown output format %q", s.Name, s.Output.Format)
+	}
+
+	if s.Output.Format == OutputFormatNDJSON && s.Output.MatchField == "" {
+		s.Output.MatchField = "type"
+	}
+
+	return nil
+}
+
+// containsPlaceholder reports whether any argument contains the placeholder.
+func containsPlaceholder(args []string, placeholder string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandClient implements Generator for a CLI declared by a CommandSpec.
+type CommandClient struct {
+	spec  CommandSpec
+	model string
+}
+
+// NewCommandClient creates a client for a validated CommandSpec. The client
+// only implements ModelSelector if the spec has model arguments.
+func NewCommandClient(spec CommandSpec) Generator {
+	client := &CommandClient{spec: spec}
+	if len(spec.ModelArgs) == 0 {
+		return client
+	}
+	return commandModelClient{client}
+}
+
+// Description returns the human-readable name of the provider.
+func (c *CommandClient) Description() string {
+	return c.spec.Description
+}
+
+// commandModelClient is a CommandClient whose spec has model arguments.
+type commandModelClient struct {
+	*CommandClient
+}
+
+// WithModel returns a copy of the client that appends the spec's model
+// arguments.
+func (c commandModelClient) WithModel(model string) Generator {
+	return commandModelClient{&CommandClient{spec: c.spec, model: model}}
+}
+
+// Probe checks the CLI installation. Authentication cannot be determined for
+// command providers.
+func (c *CommandClient) Probe(ctx context.Context) Status {
+	versionArgs := c.spec.VersionArgs
+	if len(versionArgs) == 0 {
+		versionArgs = []string{"--version"}
+	}
+	return probeCLI(ctx, c.spec.Command, versionArgs, nil)
+}
+
+// Generate runs the CLI with a system prompt and user prompt.
+func (c *CommandClient) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	replacer := strings.NewReplacer(
+		placeholderSystem, systemPrompt,
+		placeholderUser, userPrompt,
+		placeholderModel, c.model,
+	)
+
+	args := make([]string, 0, len(c.spec.Args)+len(c.spec.ModelArgs))
+	for _, arg := range c.spec.Args {
+		args = append(args, replacer.Replace(arg))
+	}
+	if c.model != "" {
+		for _, arg := range c.spec.ModelArgs {
+			args = append(args, replacer.Replace(arg))
+		}
+	}
+
+	var input io.Reader
+	if c.spec.PromptMode == PromptModeStdin {
+		input = strings.NewReader(systemPrompt + "\n\n" + userPrompt)
+	}
+
+	stdout, err := runCommandWithInput(ctx, input, c.spec.Command, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return parseCommandOutput(c.spec.Output, stdout)
+}
+
+// parseCommandOutput extracts the response from stdout as described by spec.
+func parseCommandOutput(spec OutputSpec, data []byte) (string, error) {
+	var result string
+
+	switch spec.Format {
+	case OutputFormatJSON:
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrParsing, err)
+		}
+		result, _ = lookupPath(doc, spec.Path)
+
+	case OutputFormatNDJSON:
+		// Use the last matching event, like the Codex and OpenCode parsers
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			var event any
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				continue
+			}
+			if spec.MatchValue != "" {
+				if value, _ := lookupPath(event, spec.MatchField); value != spec.MatchValue {
+					continue
+				}
+			}
+			if text, ok := lookupPath(event, spec.Path); ok && text != "" {
+				result = text
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrParsing, err)
+		}
+
+	default:
+		result = string(data)
+	}
+
+	if trimmed := CleanResponse(result); trimmed != "" {
+		return trimmed, nil
+	}
+
+	return "", ErrParsing
+}
+
+// lookupPath resolves a dot-separated path in a decoded JSON document.
+// Numeric segments index into arrays. Non-string leaves are returned as JSON.
+func lookupPath(doc any, path string) (string, bool) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[segment]
+			if !ok {
+				return "", false
+			}
+			current = value
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			current = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch value := current.(type) {
+	case string:
+		return value, true
+	case nil:
+		return "", false
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(encoded), true
+	}
+}
@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+func TestCommandSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    CommandSpec
+		wantErr bool
+	}{
+		{"argv", CommandSpec{Name: "x", Command: "x", Args: []string{"-p", "{{user}}"}}, false},
+		{"stdin", CommandSpec{Name: "x", Command: "x", PromptMode: PromptModeStdin}, false},
+		{"missing name", CommandSpec{Command: "x", Args: []string{"{{user}}"}}, true},
+		{"missing command", CommandSpec{Name: "x", Args: []string{"{{user}}"}}, true},
+		{"argv without user placeholder", CommandSpec{Name: "x", Command: "x", Args: []string{"-p"}}, true},
+		{"unknown prompt mode", CommandSpec{Name: "x", Command: "x", PromptMode: "file"}, true},
+		{"model args without placeholder", CommandSpec{Name: "x", Command: "x", PromptMode: PromptModeStdin, ModelArgs: []string{"--model"}}, true},
+		{"json without path", CommandSpec{Name: "x", Command: "x", PromptMode: PromptModeStdin, Output: OutputSpec{Format: OutputFormatJSON}}, true},
+		{"unknown format", CommandSpec{Name: "x", Command: "x", PromptMode: PromptModeStdin, Output: OutputSpec{Format: "xml"}}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.spec.Validate()
+			if tc.wantErr && err == nil {
+				t.Error("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCommandSpecValidate_Defaults(t *testing.T) {
+	spec := CommandSpec{Name: "x", Command: "x", Args: []string{"{{user}}"}, Output: OutputSpec{Format: OutputFormatNDJSON, Path: "content"}}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if spec.PromptMode != PromptModeArgv {
+		t.Errorf("expected default prompt mode %q, got %q", PromptModeArgv, spec.PromptMode)
+	}
+	if spec.Output.MatchField != "type" {
+		t.Errorf("expected default match field 'type', got %q", spec.Output.MatchField)
+	}
+}
+
+func TestParseCommandOutput_Text(t *testing.T) {
+	result, err := parseCommandOutput(OutputSpec{Format: OutputFormatText}, []byte("  Hello, world!\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Hello, world!" {
+		t.Errorf("expected %q, got %q", "Hello, world!", result)
+	}
+}
+
+func TestParseCommandOutput_JSONPath(t *testing.T) {
+	input := `{"choices":[{"message":{"content":"Paris"}}]}`
+
+	result, err := parseCommandOutput(OutputSpec{Format: OutputFormatJSON, Path: "choices.0.message.content"}, []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "Paris" {
+		t.Errorf("expected %q, got %q", "Paris", result)
+	}
+}
+
+func TestParseCommandOutput_JSONMissingPath(t *testing.T) {
+	_, err := parseCommandOutput(OutputSpec{Format: OutputFormatJSON, Path: "result"}, []byte(`{"other":"x"}`))
+	if err != ErrParsing {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestParseCommandOutput_NDJSONLastMatch(t *testing.T) {
+	input := `{"type":"text","part":{"text":"first"}}
+{"type":"tool","part":{"text":"ignored"}}
+not json
+{"type":"text","part":{"text":"last"}}
+{"type":"step_finish"}`
+
+	spec := OutputSpec{Format: OutputFormatNDJSON, Path: "part.text", MatchField: "type", MatchValue: "text"}
+	result, err := parseCommandOutput(spec, []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "last" {
+		t.Errorf("expected %q, got %q", "last", result)
+	}
+}
+
+func TestParseCommandOutput_Empty(t *testing.T) {
+	_, err := parseCommandOutput(OutputSpec{Format: OutputFormatText}, []byte("  "))
+	if err != ErrParsing {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestLookupPath_NonString(t *testing.T) {
+	doc := map[string]any{"count": float64(3), "obj": map[string]any{"a": true}}
+
+	if got, ok := lookupPath(doc, "count"); !ok || got != "3" {
+		t.Errorf("expected %q, got %q", "3", got)
+	}
+	if got, ok := lookupPath(doc, "obj"); !ok || got != `{"a":true}` {
+		t.Errorf("expected %q, got %q", `{"a":true}`, got)
+	}
+	if _, ok := lookupPath(doc, "count.x"); ok {
+		t.Error("expected lookup through a number to fail")
+	}
+}
+
+func TestNewCommandClient_ModelSelector(t *testing.T) {
+	spec := CommandSpec{Name: "echo", Command: "echo", Args: []string{"{{user}}"}}
+	if _, ok := NewCommandClient(spec).(ModelSelector); ok {
+		t.Error("expected no model selection without model args")
+	}
+
+	spec.ModelArgs = []string{"--model", "{{model}}"}
+	if _, ok := NewCommandClient(spec).(ModelSelector); !ok {
+		t.Error("expected model selection with model args")
+	}
+}
+
+func TestCommandClient_Generate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	tests := []struct {
+		name     string
+		spec     CommandSpec
+		model    string
+		expected string
+	}{
+		{
+			name:     "argv",
+			spec:     CommandSpec{Name: "echo", Command: "sh", Args: []string{"-c", `echo "$0|$1"`, "{{system}}", "{{user}}"}},
+			expected: "Be brief.|Hello",
+		},
+		{
+			name:     "stdin",
+			spec:     CommandSpec{Name: "cat", Command: "cat", PromptMode: PromptModeStdin},
+			expected: "Be brief.\n\nHello",
+		},
+		{
+			name: "model args",
+			spec: CommandSpec{
+				Name:      "echo",
+				Command:   "sh",
+				Args:      []string{"-c", `echo "$@"`, "sh", "{{user}}"},
+				ModelArgs: []string{"--model", "{{model}}"},
+			},
+			model:    "small",
+			expected: "Hello --model small",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.spec.Validate(); err != nil {
+				t.Fatalf("invalid spec: %v", err)
+			}
+
+			client := NewCommandClient(tc.spec)
+			if tc.model != "" {
+				client = client.(ModelSelector).WithModel(tc.model)
+			}
+
+			result, err := client.Generate(context.Background(), "Be brief.", "Hello")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
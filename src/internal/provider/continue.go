@@ -1,40 +1,52 @@
 package provider
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
 	"strings"
 )
 
 // ContinueClient implements Generator using the Continue CLI (cn).
-type ContinueClient struct{}
+type ContinueClient struct {
+	model string
+}
 
 // NewContinueClient creates a new Continue CLI client.
 func NewContinueClient() *ContinueClient {
 	return &ContinueClient{}
 }
 
+// WithModel returns a copy of the client that passes --model to the Continue CLI.
+func (c *ContinueClient) WithModel(model string) Generator {
+	return &ContinueClient{model: model}
+}
+
+// Probe checks the Continue CLI installation. Authentication is detected
+// from an API key or the stored login.
+func (c *ContinueClient) Probe(ctx context.Context) Status {
+	return probeCLI(ctx, "cn", []string{"--version"},
+		envOrFileAuth([]string{"CONTINUE_API_KEY"}, []string{".continue/auth.json"}))
+}
+
 // Generate calls the Continue CLI with a system prompt and user prompt.
-func (c *ContinueClient) Generate(systemPrompt, userPrompt string) (string, error) {
+func (c *ContinueClient) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
 	prompt := systemPrompt + "\n\n" + userPrompt
 
-	cmd := exec.Command("cn",
+	args := []string{
 		"-p", prompt,
 		"--format", "json",
 		"--silent",
-	)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	}
+	if c.model != "" {
+		args = append(args, "--model", c.model)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	stdout, err := runCommand(ctx, "cn", args...)
+	if err != nil {
+		return "", err
 	}
 
-	result, err := parseContinueResponse(stdout.Bytes())
+	result, err := parseContinueResponse(stdout)
 	if err != nil {
 		return "", err
 	}
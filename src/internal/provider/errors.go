@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrorCode classifies why a provider failed.
+type ErrorCode string
+
+const (
+	CodeRateLimited    ErrorCode = "rate_limited"
+	CodeAuthRequired   ErrorCode = "auth_required"
+	CodeQuotaExhausted ErrorCode = "quota_exhausted"
+	CodeTimeout        ErrorCode = "timeout"
+	CodeBinaryNotFound ErrorCode = "binary_not_found"
+	CodeParseFailure   ErrorCode = "parse_failure"
+	CodeCLIFailure     ErrorCode = "cli_failure"
+)
+
+// ErrorCodes lists all error codes.
+var ErrorCodes = []ErrorCode{
+	CodeRateLimited,
+	CodeAuthRequired,
+	CodeQuotaExhausted,
+	CodeTimeout,
+	CodeBinaryNotFound,
+	CodeParseFailure,
+	CodeCLIFailure,
+}
+
+// Error is a classified provider failure. It wraps ErrCLIExecution,
+// ErrParsing or, for timeouts, context.DeadlineExceeded.
+type Error struct {
+	Code ErrorCode
+	// Detail is the message reported by the CLI, if any. It is meant for
+	// logs rather than for clients.
+	Detail string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%v (%s)", e.Err, e.Code)
+	}
+	return fmt.Sprintf("%v (%s): %s", e.Err, e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the same request may succeed when sent again
+// later.
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case CodeRateLimited, CodeTimeout, CodeParseFailure:
+		return true
+	default:
+		return false
+	}
+}
+
+// Classify returns err as an *Error. Errors that are not classified yet are
+// reported as parse or CLI failures. It returns nil if err is nil.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr
+	}
+
+	switch {
+	case errors.Is(err, ErrParsing):
+		return &Error{Code: CodeParseFailure, Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: CodeTimeout, Err: err}
+	default:
+		return &Error{Code: CodeCLIFailure, Err: err}
+	}
+}
+
+// newCLIError classifies an error message printed by a CLI.
+func newCLIError(message string) *Error {
+	message = strings.TrimSpace(message)
+	return &Error{
+		Code:   classifyMessage(message),
+		Detail: message,
+		Err:    ErrCLIExecution,
+	}
+}
+
+// Substrings and HTTP statuses of CLI error messages, checked in order, as
+// quota errors often also mention rate limits.
+var messageCodes = []struct {
+	code     ErrorCode
+	status   string
+	patterns []string
+}{
+	{CodeQuotaExhausted, "", []string{"quota", "usage limit", "credit balance", "billing", "insufficient_quota"}},
+	{CodeRateLimited, "429", []string{"rate limit", "rate_limit", "ratelimit", "too many requests", "resource_exhausted", "overloaded"}},
+	{CodeAuthRequired, "401", []string{"unauthorized", "unauthenticated", "authentication", "not logged in", "please run /login", "login required", "invalid api key", "api key not valid", "token has expired", "token expired"}},
+	{CodeTimeout, "", []string{"timed out", "timeout", "deadline exceeded"}},
+}
+
+// statusPattern matches an HTTP status mentioned in an error message, such as
+// "status 429", "HTTP/1.1 401" or "API Error: 429", but not other numbers
+// containing the same digits.
+var statusPattern = regexp.MustCompile(`\b(?:status|http(?:/\d(?:\.\d)?)?|code|error)\b[\s:=]*(\d{3})\b`)
+
+// classifyMessage derives an error code from a CLI error message.
+func classifyMessage(message string) ErrorCode {
+	message = strings.ToLower(message)
+	var statuses []string
+	for _, match := range statusPattern.FindAllStringSubmatch(message, -1) {
+		statuses = append(statuses, match[1])
+	}
+
+	for _, entry := range messageCodes {
+		if entry.status != "" && slices.Contains(statuses, entry.status) {
+			return entry.code
+		}
+		for _, pattern := range entry.patterns {
+			if strings.Contains(message, pattern) {
+				return entry.code
+			}
+		}
+	}
+	return CodeCLIFailure
+}
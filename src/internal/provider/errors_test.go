@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClassifyMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		want    ErrorCode
+	}{
+		{"API Error: 429 Too Many Requests", CodeRateLimited},
+		{"Request failed with status code 429", CodeRateLimited},
+		{"got HTTP/1.1 401 from the API", CodeAuthRequired},
+		{"Error: 401", CodeAuthRequired},
+		{"wrote 429 lines before crashing", CodeCLIFailure},
+		{"failed to open file 1401.txt", CodeCLIFailure},
+		{"exit status 4290", CodeCLIFailure},
+		{"Rate limit reached for requests", CodeRateLimited},
+		{"Claude AI usage limit reached|1760000000", CodeQuotaExhausted},
+		{"Quota exceeded for quota metric 'Gemini 2.5 Pro Requests'", CodeQuotaExhausted},
+		{"Invalid API key · Please run /login", CodeAuthRequired},
+		{"OAuth token has expired", CodeAuthRequired},
+		{"request timed out", CodeTimeout},
+		{"segmentation fault", CodeCLIFailure},
+		{"", CodeCLIFailure},
+	}
+
+	for _, tc := range tests {
+		if got := classifyMessage(tc.message); got != tc.want {
+			t.Errorf("classifyMessage(%q) = %s, want %s", tc.message, got, tc.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"classified", newCLIError("rate limit exceeded"), CodeRateLimited},
+		{"wrapped", errors.Join(errors.New("context"), newCLIError("not logged in")), CodeAuthRequired},
+		{"parsing", ErrParsing, CodeParseFailure},
+		{"deadline", context.DeadlineExceeded, CodeTimeout},
+		{"unknown", errors.New("boom"), CodeCLIFailure},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Classify(tc.err); got.Code != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got.Code)
+			}
+		})
+	}
+
+	if Classify(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+}
+
+func TestError_WrapsSentinels(t *testing.T) {
+	err := error(newCLIError("rate limit exceeded"))
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Error("expected CLI errors to wrap ErrCLIExecution")
+	}
+	if !Classify(err).Retryable() {
+		t.Error("expected rate limit errors to be retryable")
+	}
+	if Classify(newCLIError("not logged in")).Retryable() {
+		t.Error("expected auth errors not to be retryable")
+	}
+}
@@ -1,44 +1,76 @@
 package provider
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
+	"fmt"
 	"strings"
 )
 
 // GeminiClient implements Generator using the Gemini CLI.
-type GeminiClient struct{}
+type GeminiClient struct {
+	model string
+}
 
 // NewGeminiClient creates a new Gemini CLI client.
 func NewGeminiClient() *GeminiClient {
 	return &GeminiClient{}
 }
 
+// WithModel returns a copy of the client that passes --model to the Gemini CLI.
+func (g *GeminiClient) WithModel(model string) Generator {
+	return &GeminiClient{model: model}
+}
+
+// Probe checks the Gemini CLI installation. Authentication is detected from
+// an API key or cached OAuth credentials.
+func (g *GeminiClient) Probe(ctx context.Context) Status {
+	return probeCLI(ctx, "gemini", []string{"--version"},
+		envOrFileAuth([]string{"GEMINI_API_KEY", "GOOGLE_API_KEY"}, []string{".gemini/oauth_creds.json"}))
+}
+
 // Generate calls the Gemini CLI with a system prompt and user prompt.
-func (g *GeminiClient) Generate(systemPrompt, userPrompt string) (string, error) {
+func (g *GeminiClient) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	result, _, err := g.run(ctx, systemPrompt, userPrompt)
+	return result, err
+}
+
+// GenerateWithUsage calls the Gemini CLI and reports the token usage of the
+// run.
+func (g *GeminiClient) GenerateWithUsage(ctx context.Context, systemPrompt, userPrompt string) (string, Usage, error) {
+	result, stdout, err := g.run(ctx, systemPrompt, userPrompt)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return result, parseGeminiUsage(stdout), nil
+}
+
+// run calls the Gemini CLI and also returns its output.
+func (g *GeminiClient) run(ctx context.Context, systemPrompt, userPrompt string) (string, []byte, error) {
 	prompt := systemPrompt + "\n\n" + userPrompt
 
-	cmd := exec.Command("gemini",
+	args := []string{
 		"-p", prompt,
 		"--output-format", "json",
-	)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	}
+	if g.model != "" {
+		args = append(args, "--model", g.model)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	stdout, err := runCommand(ctx, "gemini", args...)
+	if cliErr := parseGeminiError(stdout); cliErr != nil {
+		return "", nil, cliErr
+	}
+	if err != nil {
+		return "", nil, err
 	}
 
-	result, err := parseGeminiResponse(stdout.Bytes())
+	result, err := parseGeminiResponse(stdout)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return result, nil
+	return result, stdout, nil
 }
 
 // parseGeminiResponse extracts the response from Gemini's JSON output.
@@ -60,3 +92,52 @@ func parseGeminiResponse(data []byte) (string, error) {
 
 	return "", ErrParsing
 }
+
+// parseGeminiError returns the error object of Gemini's JSON output, or nil
+// if there is none.
+func parseGeminiError(data []byte) error {
+	// Gemini reports errors as: {"error": {"type": "...", "message": "...", "code": 429}}
+	var response struct {
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil || response.Error == nil {
+		return nil
+	}
+
+	cliErr := newCLIError(response.Error.Message)
+	if cliErr.Code == CodeCLIFailure {
+		// Fall back to the error type and HTTP status code
+		cliErr.Code = classifyMessage(fmt.Sprintf("%s status %v", response.Error.Type, response.Error.Code))
+	}
+	return cliErr
+}
+
+// parseGeminiUsage sums the token usage of all models in Gemini's JSON
+// output.
+func parseGeminiUsage(data []byte) Usage {
+	// Gemini reports: {"stats": {"models": {"gemini-2.5-pro": {"tokens": {"prompt": 10, "candidates": 5, ...}}}}, ...}
+	var response struct {
+		Stats struct {
+			Models map[string]struct {
+				Tokens struct {
+					Prompt     int `json:"prompt"`
+					Candidates int `json:"candidates"`
+				} `json:"tokens"`
+			} `json:"models"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Usage{}
+	}
+
+	var usage Usage
+	for _, model := range response.Stats.Models {
+		usage.InputTokens += model.Tokens.Prompt
+		usage.OutputTokens += model.Tokens.Candidates
+	}
+	return usage
+}
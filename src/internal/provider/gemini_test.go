@@ -63,3 +63,35 @@ func TestParseGeminiResponse_WhitespaceOnly(t *testing.T) {
 		t.Errorf("expected ErrParsing, got %v", err)
 	}
 }
+
+func TestParseGeminiUsage(t *testing.T) {
+	input := `{"response":"Hi","stats":{"models":{"gemini-2.5-pro":{"tokens":{"prompt":100,"candidates":10,"total":110}},"gemini-2.5-flash":{"tokens":{"prompt":20,"candidates":2,"total":22}}}}}`
+
+	if got := parseGeminiUsage([]byte(input)); got != (Usage{InputTokens: 120, OutputTokens: 12}) {
+		t.Errorf("unexpected usage %+v", got)
+	}
+}
+
+func TestParseGeminiError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ErrorCode
+	}{
+		{"auth", `{"error":{"type":"FatalAuthenticationError","message":"Please set an Auth method","code":41}}`, CodeAuthRequired},
+		{"status code", `{"error":{"type":"ApiError","message":"Request failed","code":429}}`, CodeRateLimited},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parseGeminiError([]byte(tc.input))
+			if got := Classify(err); got == nil || got.Code != tc.want {
+				t.Errorf("expected %s, got %v", tc.want, err)
+			}
+		})
+	}
+
+	if err := parseGeminiError([]byte(`{"response":"Hi"}`)); err != nil {
+		t.Errorf("expected no error for a response, got %v", err)
+	}
+}
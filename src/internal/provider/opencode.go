@@ -3,43 +3,96 @@ package provider
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
+	"fmt"
 	"strings"
 )
 
 // OpenCodeClient implements Generator using the OpenCode CLI.
-type OpenCodeClient struct{}
+type OpenCodeClient struct {
+	model string
+}
 
 // NewOpenCodeClient creates a new OpenCode CLI client.
 func NewOpenCodeClient() *OpenCodeClient {
 	return &OpenCodeClient{}
 }
 
+// WithModel returns a copy of the client that passes --model to the OpenCode
+// CLI. OpenCode models are given as provider/model, e.g. anthropic/claude-sonnet-4-5.
+func (c *OpenCodeClient) WithModel(model string) Generator {
+	return &OpenCodeClient{model: model}
+}
+
+// runArgs returns the arguments of "opencode run" for the given prompt.
+func (c *OpenCodeClient) runArgs(prompt string) []string {
+	args := []string{"run", prompt, "--format", "json"}
+	if c.model != "" {
+		args = append(args, "--model", c.model)
+	}
+	return args
+}
+
+// Probe checks the OpenCode CLI installation. Authentication is detected
+// from the stored provider credentials.
+func (c *OpenCodeClient) Probe(ctx context.Context) Status {
+	return probeCLI(ctx, "opencode", []string{"--version"},
+		envOrFileAuth(nil, []string{".local/share/opencode/auth.json"}))
+}
+
 // Generate calls the OpenCode CLI with a system prompt and user prompt.
-func (c *OpenCodeClient) Generate(systemPrompt, userPrompt string) (string, error) {
-	prompt := systemPrompt + "\n\n" + userPrompt
+func (c *OpenCodeClient) Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	result, _, err := c.GenerateTurn(ctx, systemPrompt, userPrompt, "")
+	return result, err
+}
+
+// GenerateTurn calls the OpenCode CLI, continuing the given OpenCode session
+// if sessionID is set. The system prompt is only sent with the first turn, as
+// a continued session already contains it.
+func (c *OpenCodeClient) GenerateTurn(ctx context.Context, systemPrompt, userPrompt, sessionID string) (string, string, error) {
+	args := c.runArgs(systemPrompt + "\n\n" + userPrompt)
+	if sessionID != "" {
+		args = append(c.runArgs(userPrompt), "--session", sessionID)
+	}
 
-	cmd := exec.Command("opencode", "run",
-		prompt,
-		"--format", "json",
-	)
+	stdout, err := runCommand(ctx, "opencode", args...)
+	if err != nil {
+		return "", "", err
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	result, err := parseOpenCodeResponse(stdout)
+	if err != nil {
+		return "", "", err
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	nextSessionID := parseOpenCodeSessionID(stdout)
+	if nextSessionID == "" {
+		nextSessionID = sessionID
 	}
 
-	result, err := parseOpenCodeResponse(stdout.Bytes())
+	return result, nextSessionID, nil
+}
+
+// GenerateStream calls the OpenCode CLI and reports each text event as soon
+// as OpenCode emits it.
+func (c *OpenCodeClient) GenerateStream(ctx context.Context, systemPrompt, userPrompt string, onDelta func(string)) (string, error) {
+	prompt := systemPrompt + "\n\n" + userPrompt
+
+	stdout, err := streamCommand(ctx, func(line []byte) {
+		var event opencodeEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return
+		}
+		if event.Type == "text" && event.Content != "" {
+			onDelta(event.Content)
+		}
+	}, "opencode", c.runArgs(prompt)...)
 	if err != nil {
 		return "", err
 	}
 
-	return result, nil
+	return parseOpenCodeResponse(stdout)
 }
 
 // opencodeEvent represents a single NDJSON event from OpenCode.
@@ -58,6 +111,7 @@ func parseOpenCodeResponse(data []byte) (string, error) {
 	var lastContent string
 
 	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
@@ -74,6 +128,9 @@ func parseOpenCodeResponse(data []byte) (string, error) {
 			lastContent = event.Content
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrParsing, err)
+	}
 
 	if lastContent != "" {
 		return CleanResponse(lastContent), nil
@@ -87,3 +144,19 @@ func parseOpenCodeResponse(data []byte) (string, error) {
 
 	return "", ErrParsing
 }
+
+// parseOpenCodeSessionID extracts the session ID from OpenCode's NDJSON output.
+func parseOpenCodeSessionID(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var event opencodeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.SessionID != "" {
+			return event.SessionID
+		}
+	}
+	return ""
+}
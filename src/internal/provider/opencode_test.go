@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -156,3 +158,38 @@ func TestParseOpenCodeResponse_ComplexSQL(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, sql)
 	}
 }
+
+func TestParseOpenCodeResponse_LongLine(t *testing.T) {
+	// Lines longer than bufio.Scanner's default limit of 64 KiB
+	text := strings.Repeat("a", 100*1024)
+	input := `{"type":"text","content":"` + text + `","sessionID":"ses_abc123"}`
+
+	got, err := parseOpenCodeResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != text {
+		t.Errorf("expected %d characters, got %d", len(text), len(got))
+	}
+	if id := parseOpenCodeSessionID([]byte(input)); id != "ses_abc123" {
+		t.Errorf("expected session ID from long line, got %q", id)
+	}
+
+	_, err = parseOpenCodeResponse([]byte(strings.Repeat("a", maxLineSize+1)))
+	if !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing for a line over the limit, got %v", err)
+	}
+}
+
+func TestParseOpenCodeSessionID(t *testing.T) {
+	input := `{"type":"step_start","timestamp":1234567889}
+{"type":"text","timestamp":1234567890,"sessionID":"ses_abc123","content":"Hi"}`
+
+	if got := parseOpenCodeSessionID([]byte(input)); got != "ses_abc123" {
+		t.Errorf("expected %q, got %q", "ses_abc123", got)
+	}
+
+	if got := parseOpenCodeSessionID([]byte(`{"type":"text","content":"Hi"}`)); got != "" {
+		t.Errorf("expected empty session ID, got %q", got)
+	}
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds each command run while probing a CLI.
+const probeTimeout = 10 * time.Second
+
+// Status describes the installation of a provider's CLI.
+type Status struct {
+	Binary    string `json:"binary"`
+	Installed bool   `json:"installed"`
+	Version   string `json:"version,omitempty"`
+	// Authenticated is nil if authentication could not be determined.
+	Authenticated *bool     `json:"authenticated"`
+	LastChecked   time.Time `json:"last_checked"`
+}
+
+// Prober is implemented by providers that can check their CLI installation.
+type Prober interface {
+	Probe(ctx context.Context) Status
+}
+
+// authCheck reports whether a CLI is authenticated, or nil if unknown.
+type authCheck func(ctx context.Context) *bool
+
+// probeCLI looks up binary in PATH, reads its version and, if it is
+// installed, runs the auth check.
+func probeCLI(ctx context.Context, binary string, versionArgs []string, auth authCheck) Status {
+	status := Status{
+		Binary:      binary,
+		LastChecked: time.Now().UTC(),
+	}
+
+	if _, err := exec.LookPath(binary); err != nil {
+		return status
+	}
+	status.Installed = true
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if stdout, err := runCommand(ctx, binary, versionArgs...); err == nil {
+		status.Version = firstLine(stdout)
+	}
+
+	if auth != nil {
+		status.Authenticated = auth(ctx)
+	}
+
+	return status
+}
+
+// firstLine returns the first non-empty line of the output.
+func firstLine(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// envOrFileAuth returns an auth check that succeeds if any of the
+// environment variables is set or any of the files relative to the user's
+// home directory exists. Otherwise authentication is unknown, as credentials
+// may also live in the OS keychain.
+func envOrFileAuth(envVars []string, homeFiles []string) authCheck {
+	return func(ctx context.Context) *bool {
+		for _, name := range envVars {
+			if os.Getenv(name) != "" {
+				return boolPtr(true)
+			}
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		for _, file := range homeFiles {
+			if _, err := os.Stat(filepath.Join(home, file)); err == nil {
+				return boolPtr(true)
+			}
+		}
+
+		return nil
+	}
+}
+
+// commandAuth returns an auth check that runs a CLI status command and
+// reports whether it exited successfully.
+func commandAuth(binary string, args ...string) authCheck {
+	return func(ctx context.Context) *bool {
+		_, err := runCommand(ctx, binary, args...)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return boolPtr(err == nil)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
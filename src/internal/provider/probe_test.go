@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// installFakeCLI writes an executable shell script to a temp dir and puts
+// that dir in front of PATH.
+func installFakeCLI(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatalf("failed to write fake CLI: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestProbeCLI_NotInstalled(t *testing.T) {
+	status := probeCLI(context.Background(), "definitely-not-installed-cli", []string{"--version"}, nil)
+
+	if status.Installed {
+		t.Error("expected CLI not to be installed")
+	}
+	if status.Binary != "definitely-not-installed-cli" {
+		t.Errorf("unexpected binary: %q", status.Binary)
+	}
+	if status.LastChecked.IsZero() {
+		t.Error("expected last checked time to be set")
+	}
+}
+
+func TestProbeCLI_Installed(t *testing.T) {
+	installFakeCLI(t, "fakecli", `echo ""; echo "fakecli 1.2.3"; echo "extra"`)
+
+	status := probeCLI(context.Background(), "fakecli", []string{"--version"}, func(ctx context.Context) *bool {
+		return boolPtr(true)
+	})
+
+	if !status.Installed {
+		t.Fatal("expected CLI to be installed")
+	}
+	if status.Version != "fakecli 1.2.3" {
+		t.Errorf("expected version %q, got %q", "fakecli 1.2.3", status.Version)
+	}
+	if status.Authenticated == nil || !*status.Authenticated {
+		t.Errorf("expected authenticated, got %v", status.Authenticated)
+	}
+}
+
+func TestCommandAuth(t *testing.T) {
+	installFakeCLI(t, "fakeauth", `[ "$1" = "ok" ]`)
+
+	if got := commandAuth("fakeauth", "ok")(context.Background()); got == nil || !*got {
+		t.Errorf("expected authenticated, got %v", got)
+	}
+	if got := commandAuth("fakeauth", "fail")(context.Background()); got == nil || *got {
+		t.Errorf("expected not authenticated, got %v", got)
+	}
+}
+
+func TestEnvOrFileAuth(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("FAKE_API_KEY", "")
+
+	check := envOrFileAuth([]string{"FAKE_API_KEY"}, []string{".fake/creds.json"})
+
+	if got := check(context.Background()); got != nil {
+		t.Errorf("expected unknown authentication, got %v", *got)
+	}
+
+	t.Setenv("FAKE_API_KEY", "secret")
+	if got := check(context.Background()); got == nil || !*got {
+		t.Errorf("expected authenticated via env, got %v", got)
+	}
+
+	t.Setenv("FAKE_API_KEY", "")
+	os.MkdirAll(filepath.Join(home, ".fake"), 0755)
+	os.WriteFile(filepath.Join(home, ".fake", "creds.json"), []byte("{}"), 0600)
+	if got := check(context.Background()); got == nil || !*got {
+		t.Errorf("expected authenticated via file, got %v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+const (
+	// waitDelay bounds how long Wait blocks on output pipes after the process
+	// tree has been killed.
+	waitDelay = 5 * time.Second
+
+	// maxLineSize is the longest NDJSON line accepted from a streaming CLI.
+	maxLineSize = 10 * 1024 * 1024
+)
+
+// newCommand creates a command bound to ctx. When ctx is done, the whole
+// process tree is killed, as the CLIs spawn node child processes that would
+// otherwise keep running.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd)
+	}
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// runCommand runs a CLI and returns its stdout. If the CLI fails, its stdout
+// is returned along with the error, as some CLIs report errors there.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return runCommandWithInput(ctx, nil, name, args...)
+}
+
+// runCommandWithInput runs a CLI with stdin read from input and returns its
+// stdout. A nil input connects stdin to the null device. Like runCommand, it
+// returns stdout on failure as well.
+func runCommandWithInput(ctx context.Context, input io.Reader, name string, args ...string) ([]byte, error) {
+	cmd := newCommand(ctx, name, args...)
+	cmd.Stdin = input
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), commandError(ctx, err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// streamCommand runs a CLI and calls onLine for every line it writes to
+// stdout while it is still running. It returns the complete stdout, also if
+// the CLI fails.
+func streamCommand(ctx context.Context, onLine func(line []byte), name string, args ...string) ([]byte, error) {
+	cmd := newCommand(ctx, name, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Join(ErrCLIExecution, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, commandError(ctx, err, err.Error())
+	}
+
+	var stdout bytes.Buffer
+	scanner := bufio.NewScanner(pipe)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		stdout.Write(line)
+		stdout.WriteByte('\n')
+		onLine(line)
+	}
+
+	// Keep draining if scanning stopped early so the CLI never blocks on a
+	// full pipe.
+	io.Copy(io.Discard, pipe)
+
+	if err := cmd.Wait(); err != nil {
+		return stdout.Bytes(), commandError(ctx, err, stderr.String())
+	}
+	if err := scanner.Err(); err != nil {
+		return stdout.Bytes(), fmt.Errorf("%w: %v", ErrParsing, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// commandError builds the error returned for a failed CLI run from the run
+// error and the CLI's stderr. Cancellation is reported as the context error
+// rather than as a CLI failure.
+func commandError(ctx context.Context, err error, stderr string) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &Error{Code: CodeTimeout, Err: ctx.Err()}
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, exec.ErrNotFound):
+		return &Error{Code: CodeBinaryNotFound, Detail: err.Error(), Err: ErrCLIExecution}
+	}
+	return newCLIError(stderr)
+}
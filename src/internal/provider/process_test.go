@@ -0,0 +1,89 @@
+//go:build !windows
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunCommand_Success(t *testing.T) {
+	stdout, err := runCommand(context.Background(), "sh", "-c", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(stdout) != "hello\n" {
+		t.Errorf("expected %q, got %q", "hello\n", stdout)
+	}
+}
+
+func TestRunCommand_Failure(t *testing.T) {
+	_, err := runCommand(context.Background(), "sh", "-c", "echo boom >&2; exit 1")
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Fatalf("expected ErrCLIExecution, got %v", err)
+	}
+}
+
+func TestStreamCommand_ReportsLines(t *testing.T) {
+	var lines []string
+	stdout, err := streamCommand(context.Background(), func(line []byte) {
+		lines = append(lines, string(line))
+	}, "sh", "-c", "echo one; echo two")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(lines) != 2 || lines[0] != "one" || lines[1] != "two" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+	if string(stdout) != "one\ntwo\n" {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+}
+
+func TestStreamCommand_Failure(t *testing.T) {
+	_, err := streamCommand(context.Background(), func([]byte) {}, "sh", "-c", "echo partial; exit 1")
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Fatalf("expected ErrCLIExecution, got %v", err)
+	}
+}
+
+func TestRunCommand_CancelKillsProcessTree(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The background child inherits stdout, so Run would block on the pipe
+	// until WaitDelay if only the shell were killed.
+	start := time.Now()
+	_, err := runCommand(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= waitDelay {
+		t.Errorf("expected child processes to be killed promptly, took %v", elapsed)
+	}
+}
+
+func TestRunCommand_BinaryNotFound(t *testing.T) {
+	_, err := runCommand(context.Background(), "local-ai-tool-proxy-missing-cli")
+	if got := Classify(err); got.Code != CodeBinaryNotFound {
+		t.Fatalf("expected binary_not_found, got %v", err)
+	}
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Errorf("expected ErrCLIExecution, got %v", err)
+	}
+}
+
+func TestRunCommand_ClassifiesStderr(t *testing.T) {
+	stdout, err := runCommand(context.Background(), "sh", "-c", "echo partial; echo 'Error: rate limit exceeded' >&2; exit 1")
+	if got := Classify(err); got.Code != CodeRateLimited {
+		t.Fatalf("expected rate_limited, got %v", err)
+	}
+	if string(stdout) != "partial\n" {
+		t.Errorf("expected stdout to be returned on failure, got %q", stdout)
+	}
+}
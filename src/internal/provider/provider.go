@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"regexp"
 	"strings"
@@ -12,8 +14,60 @@ var (
 )
 
 // Generator defines the interface for AI prompt generation providers.
+// Implementations must stop generating and release all resources, including
+// any spawned CLI processes, once ctx is done.
 type Generator interface {
-	Generate(systemPrompt, userPrompt string) (string, error)
+	Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error)
+}
+
+// StreamGenerator is implemented by providers that can report the response
+// incrementally while the CLI is still running. onDelta is called with each
+// new piece of text as it is produced; the complete response is returned once
+// the CLI exits.
+type StreamGenerator interface {
+	Generator
+	GenerateStream(ctx context.Context, systemPrompt, userPrompt string, onDelta func(delta string)) (string, error)
+}
+
+// SessionGenerator is implemented by providers whose CLI can resume a previous
+// conversation natively. sessionID is empty for the first turn of a
+// conversation; the returned session ID must be passed to the next turn.
+type SessionGenerator interface {
+	Generator
+	GenerateTurn(ctx context.Context, systemPrompt, userPrompt, sessionID string) (response, nextSessionID string, err error)
+}
+
+// Usage is the number of tokens a CLI run consumed, as reported by the CLI.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// UsageGenerator is implemented by providers whose CLI reports the token
+// usage of a run.
+type UsageGenerator interface {
+	Generator
+	GenerateWithUsage(ctx context.Context, systemPrompt, userPrompt string) (string, Usage, error)
+}
+
+// SchemaGenerator is implemented by providers whose CLI can constrain the
+// response to a JSON Schema. It returns the JSON text of the response, which
+// callers must still validate.
+type SchemaGenerator interface {
+	Generator
+	GenerateJSON(ctx context.Context, systemPrompt, userPrompt string, schema json.RawMessage) (string, error)
+}
+
+// ModelSelector is implemented by providers whose CLI can select a model.
+type ModelSelector interface {
+	// WithModel returns a copy of the provider that uses the given model.
+	WithModel(model string) Generator
+}
+
+// Describer is implemented by providers that carry their own human-readable
+// description.
+type Describer interface {
+	Description() string
 }
 
 // CleanResponse removes any markdown code blocks or extra formatting from the response.
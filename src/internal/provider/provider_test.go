@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -46,3 +47,45 @@ func TestCleanResponse_RemovesMarkdownCodeBlock(t *testing.T) {
 		})
 	}
 }
+
+func TestWithModel_ReturnsCopy(t *testing.T) {
+	clients := map[string]Generator{
+		"claude":   NewClaudeClient(),
+		"gemini":   NewGeminiClient(),
+		"codex":    NewCodexClient(),
+		"continue": NewContinueClient(),
+		"opencode": NewOpenCodeClient(),
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			selector, ok := client.(ModelSelector)
+			if !ok {
+				t.Fatalf("%s client does not implement ModelSelector", name)
+			}
+
+			withModel := selector.WithModel("some-model")
+			if withModel == client {
+				t.Error("expected WithModel to return a new client")
+			}
+		})
+	}
+}
+
+func TestCodexExecArgs_Model(t *testing.T) {
+	args := NewCodexClient().WithModel("gpt-5-codex").(*CodexClient).execArgs()
+
+	expected := []string{"exec", "--json", "--model", "gpt-5-codex"}
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestOpenCodeRunArgs_Model(t *testing.T) {
+	args := NewOpenCodeClient().WithModel("anthropic/claude-sonnet-4-5").(*OpenCodeClient).runArgs("Hello")
+
+	expected := []string{"run", "Hello", "--format", "json", "--model", "anthropic/claude-sonnet-4-5"}
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
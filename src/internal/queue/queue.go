@@ -0,0 +1,209 @@
+// Package queue bounds concurrent provider runs with a FIFO wait queue.
+package queue
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by Acquire when no more callers may wait.
+var ErrQueueFull = errors.New("queue is full")
+
+// defaultRetryAfter is suggested to rejected callers before any run has
+// completed.
+const defaultRetryAfter = 5 * time.Second
+
+// Stats describes the current load of a Limiter.
+type Stats struct {
+	Active         int `json:"active"`
+	Queued         int `json:"queued"`
+	MaxConcurrency int `json:"max_concurrency"`
+	MaxQueue       int `json:"max_queue"`
+}
+
+// waiter is a caller waiting in the queue.
+type waiter struct {
+	// ready is closed when the waiter has been granted a slot.
+	ready chan struct{}
+	// moved is signalled when the waiter's queue position changes.
+	moved chan struct{}
+}
+
+// Limiter bounds the number of concurrent runs and queues further callers in
+// FIFO order, up to a maximum queue length.
+type Limiter struct {
+	maxActive int
+	maxQueued int
+
+	mu      sync.Mutex
+	active  int
+	queue   []*waiter
+	avgRun  time.Duration
+	hasRuns bool
+}
+
+// NewLimiter creates a limiter that allows maxActive concurrent runs and up
+// to maxQueued waiting callers.
+func NewLimiter(maxActive, maxQueued int) *Limiter {
+	if maxActive < 1 {
+		maxActive = 1
+	}
+	if maxQueued < 0 {
+		maxQueued = 0
+	}
+	return &Limiter{maxActive: maxActive, maxQueued: maxQueued}
+}
+
+// Acquire waits for a free slot and returns a function that releases it.
+// If the caller has to wait, onPosition is called with its 1-based queue
+// position whenever that changes, ending with position 1 before the slot is
+// granted; it runs on the caller's goroutine. Acquire
+// fails with ErrQueueFull if the queue is full, or with the context error if
+// ctx is done first.
+func (l *Limiter) Acquire(ctx context.Context, onPosition func(position int)) (release func(), err error) {
+	l.mu.Lock()
+	if l.active < l.maxActive && len(l.queue) == 0 {
+		l.active++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+	if len(l.queue) >= l.maxQueued {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{
+		ready: make(chan struct{}),
+		moved: make(chan struct{}, 1),
+	}
+	l.queue = append(l.queue, w)
+	position := len(l.queue)
+	l.mu.Unlock()
+
+	if onPosition != nil {
+		onPosition(position)
+	}
+
+	for {
+		select {
+		case <-w.ready:
+			// Slots are only granted to the head of the queue. The move
+			// there may still be pending, as select picks at random
+			if position > 1 && onPosition != nil {
+				onPosition(1)
+			}
+			return l.releaseFunc(), nil
+
+		case <-w.moved:
+			if p := l.position(w); p > 0 {
+				position = p
+				if onPosition != nil {
+					onPosition(position)
+				}
+			}
+
+		case <-ctx.Done():
+			l.mu.Lock()
+			select {
+			case <-w.ready:
+				// The slot was granted concurrently, hand it on
+				l.mu.Unlock()
+				l.releaseFunc()()
+				return nil, ctx.Err()
+			default:
+			}
+			if i := slices.Index(l.queue, w); i >= 0 {
+				l.queue = slices.Delete(l.queue, i, i+1)
+				l.notifyFrom(i)
+			}
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseFunc returns an idempotent function that releases a slot and
+// records how long it was held.
+func (l *Limiter) releaseFunc() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(time.Since(start))
+		})
+	}
+}
+
+// release hands the slot to the first waiter, or frees it.
+func (l *Limiter) release(held time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Exponentially weighted moving average of run durations
+	if l.hasRuns {
+		l.avgRun = (l.avgRun*4 + held) / 5
+	} else {
+		l.avgRun = held
+		l.hasRuns = true
+	}
+
+	if len(l.queue) == 0 {
+		l.active--
+		return
+	}
+
+	next := l.queue[0]
+	l.queue = l.queue[1:]
+	close(next.ready)
+	l.notifyFrom(0)
+}
+
+// notifyFrom signals all waiters from index i onwards that their position
+// changed. The caller must hold l.mu.
+func (l *Limiter) notifyFrom(i int) {
+	for _, w := range l.queue[i:] {
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// position returns the 1-based queue position of w, or 0 if it is not queued.
+func (l *Limiter) position(w *waiter) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Index(l.queue, w) + 1
+}
+
+// Stats returns the current load.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{
+		Active:         l.active,
+		Queued:         len(l.queue),
+		MaxConcurrency: l.maxActive,
+		MaxQueue:       l.maxQueued,
+	}
+}
+
+// RetryAfter estimates when a rejected caller should try again, based on the
+// average run duration and the current queue length. It is at least one
+// second.
+func (l *Limiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.hasRuns {
+		return defaultRetryAfter
+	}
+
+	waves := float64(len(l.queue)+1) / float64(l.maxActive)
+	estimate := time.Duration(math.Ceil(waves * float64(l.avgRun)))
+	return max(estimate.Round(time.Second), time.Second)
+}
@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForQueued polls until the limiter has n queued callers.
+func waitForQueued(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for l.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d queued callers, have %d", n, l.Stats().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiter_AcquireWithinLimit(t *testing.T) {
+	l := NewLimiter(2, 0)
+
+	release1, err := l.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release2, err := l.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stats := l.Stats(); stats.Active != 2 || stats.Queued != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	release1()
+	release2()
+	release2()
+
+	if stats := l.Stats(); stats.Active != 0 {
+		t.Errorf("expected no active runs after release, got %+v", stats)
+	}
+}
+
+func TestLimiter_QueueFull(t *testing.T) {
+	l := NewLimiter(1, 1)
+
+	release, _ := l.Acquire(context.Background(), nil)
+	defer release()
+
+	go l.Acquire(context.Background(), nil)
+	waitForQueued(t, l, 1)
+
+	if _, err := l.Acquire(context.Background(), nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestLimiter_FIFOOrderAndPositions(t *testing.T) {
+	l := NewLimiter(1, 3)
+
+	release, _ := l.Acquire(context.Background(), nil)
+
+	var mu sync.Mutex
+	var order []int
+	positions := make([][]int, 3)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := l.Acquire(context.Background(), func(position int) {
+				positions[i] = append(positions[i], position)
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			r()
+		}()
+		waitForQueued(t, l, i+1)
+	}
+
+	release()
+	wg.Wait()
+
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Errorf("expected FIFO order, got %v", order)
+	}
+	if positions[2][0] != 3 {
+		t.Errorf("expected last caller to start at position 3, got %v", positions[2])
+	}
+	if last := positions[2][len(positions[2])-1]; last != 1 {
+		t.Errorf("expected last caller to move up to position 1, got %v", positions[2])
+	}
+}
+
+func TestLimiter_CancelWhileQueued(t *testing.T) {
+	l := NewLimiter(1, 2)
+
+	release, _ := l.Acquire(context.Background(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, nil)
+		errs <- err
+	}()
+	waitForQueued(t, l, 1)
+
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if stats := l.Stats(); stats.Queued != 0 {
+		t.Errorf("expected cancelled caller to leave the queue, got %+v", stats)
+	}
+
+	release()
+	if stats := l.Stats(); stats.Active != 0 {
+		t.Errorf("expected slot to be freed, got %+v", stats)
+	}
+}
+
+func TestLimiter_RetryAfter(t *testing.T) {
+	l := NewLimiter(1, 1)
+
+	if got := l.RetryAfter(); got != defaultRetryAfter {
+		t.Errorf("expected default retry after %v, got %v", defaultRetryAfter, got)
+	}
+
+	release, _ := l.Acquire(context.Background(), nil)
+	release()
+
+	if got := l.RetryAfter(); got < time.Second {
+		t.Errorf("expected retry after of at least one second, got %v", got)
+	}
+}
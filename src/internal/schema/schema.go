@@ -0,0 +1,507 @@
+// Package schema validates JSON values against a subset of JSON Schema.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxErrors bounds the errors reported by Validate, which are fed back to
+// the provider when its output is repaired.
+const maxErrors = 20
+
+// maxDepth bounds the nesting of schemas applied to a value, so that cyclic
+// references fail instead of recursing forever.
+const maxDepth = 64
+
+// Schema is a compiled JSON Schema. It supports the keywords commonly used
+// to describe structured output: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf,
+// anyOf, oneOf, not and local $ref pointers such as "#/$defs/name". Compile
+// rejects the other assertion keywords, such as uniqueItems, so that values
+// are never reported valid without being checked. Annotations, such as
+// format or description, are ignored.
+type Schema struct {
+	raw      json.RawMessage
+	root     any
+	patterns map[string]*regexp.Regexp
+	// refs are the $ref targets checked by Compile, which also stops
+	// cyclic references from being checked forever.
+	refs map[string]bool
+}
+
+// ValidationError is returned by Validate if a value does not match the
+// schema. Each error is prefixed with the path of the offending value.
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+// Compile parses a JSON Schema and checks that its keywords are
+// well-formed.
+func Compile(data []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	s := &Schema{raw: json.RawMessage(data), root: root, patterns: make(map[string]*regexp.Regexp), refs: make(map[string]bool)}
+	if err := s.check(root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Raw returns the schema as it was compiled.
+func (s *Schema) Raw() json.RawMessage {
+	return s.raw
+}
+
+// Validate parses data as JSON and validates it against the schema. It
+// returns a ValidationError listing the mismatches, which includes the
+// syntax error if data is not JSON.
+func (s *Schema) Validate(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &ValidationError{Errors: []string{"invalid JSON: " + err.Error()}}
+	}
+
+	var errs []string
+	s.validate(s.root, v, "$", 0, &errs)
+	if len(errs) > 0 {
+		if len(errs) > maxErrors {
+			errs = append(errs[:maxErrors], fmt.Sprintf("and %d more", len(errs)-maxErrors))
+		}
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// check reports malformed keywords of the schema node at ptr and compiles
+// its patterns.
+func (s *Schema) check(node any, ptr string) error {
+	if _, ok := node.(bool); ok {
+		return nil
+	}
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or a boolean", ptr)
+	}
+
+	for _, key := range unsupportedKeywords {
+		if _, ok := obj[key]; ok {
+			return fmt.Errorf("%s/%s: unsupported keyword", ptr, key)
+		}
+	}
+
+	if t, ok := obj["type"]; ok {
+		names, ok := typeNames(t)
+		if !ok {
+			return fmt.Errorf("%s/type: must be a string or an array of strings", ptr)
+		}
+		for _, name := range names {
+			if !knownTypes[name] {
+				return fmt.Errorf("%s/type: unknown type %q", ptr, name)
+			}
+		}
+	}
+
+	if e, ok := obj["enum"]; ok {
+		if _, ok := e.([]any); !ok {
+			return fmt.Errorf("%s/enum: must be an array", ptr)
+		}
+	}
+
+	if r, ok := obj["required"]; ok {
+		names, ok := r.([]any)
+		if !ok {
+			return fmt.Errorf("%s/required: must be an array of strings", ptr)
+		}
+		for _, name := range names {
+			if _, ok := name.(string); !ok {
+				return fmt.Errorf("%s/required: must be an array of strings", ptr)
+			}
+		}
+	}
+
+	for _, key := range []string{"minItems", "maxItems", "minLength", "maxLength", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"} {
+		if n, ok := obj[key]; ok {
+			if _, ok := n.(float64); !ok {
+				return fmt.Errorf("%s/%s: must be a number", ptr, key)
+			}
+		}
+	}
+
+	if p, ok := obj["pattern"]; ok {
+		pattern, ok := p.(string)
+		if !ok {
+			return fmt.Errorf("%s/pattern: must be a string", ptr)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s/pattern: %w", ptr, err)
+		}
+		s.patterns[pattern] = re
+	}
+
+	if r, ok := obj["$ref"]; ok {
+		ref, ok := r.(string)
+		if !ok {
+			return fmt.Errorf("%s/$ref: must be a string", ptr)
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s/$ref: %w", ptr, err)
+		}
+		if !s.refs[ref] {
+			s.refs[ref] = true
+			if err := s.check(target, ref); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range []string{"properties", "$defs", "definitions"} {
+		children, ok := obj[key]
+		if !ok {
+			continue
+		}
+		m, ok := children.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s/%s: must be an object", ptr, key)
+		}
+		for name, child := range m {
+			if err := s.check(child, ptr+"/"+key+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range []string{"additionalProperties", "items", "not"} {
+		if child, ok := obj[key]; ok {
+			if err := s.check(child, ptr+"/"+key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		children, ok := obj[key]
+		if !ok {
+			continue
+		}
+		list, ok := children.([]any)
+		if !ok || len(list) == 0 {
+			return fmt.Errorf("%s/%s: must be a non-empty array", ptr, key)
+		}
+		for i, child := range list {
+			if err := s.check(child, ptr+"/"+key+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the schema node a local $ref points to.
+func (s *Schema) resolve(ref string) (any, error) {
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("only local references are supported, got %q", ref)
+	}
+
+	node := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable reference %q", ref)
+		}
+		if node, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("unresolvable reference %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// unsupportedKeywords are the JSON Schema assertion and applicator keywords
+// Validate does not implement.
+var unsupportedKeywords = []string{
+	"patternProperties", "propertyNames", "minProperties", "maxProperties",
+	"dependentRequired", "dependentSchemas", "dependencies",
+	"unevaluatedProperties", "unevaluatedItems",
+	"prefixItems", "additionalItems", "contains", "minContains", "maxContains", "uniqueItems",
+	"multipleOf", "if", "then", "else",
+	"$dynamicRef", "$recursiveRef",
+}
+
+// knownTypes are the JSON Schema type names.
+var knownTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// typeNames returns the type names of a type keyword.
+func typeNames(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		names := make([]string, 0, len(t))
+		for _, name := range t {
+			s, ok := name.(string)
+			if !ok {
+				return nil, false
+			}
+			names = append(names, s)
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+// validate appends the mismatches of v against the schema node to errs.
+func (s *Schema) validate(node, v any, path string, depth int, errs *[]string) {
+	if depth > maxDepth {
+		*errs = append(*errs, path+": schema nesting is too deep")
+		return
+	}
+	if b, ok := node.(bool); ok {
+		if !b {
+			*errs = append(*errs, path+": no value is allowed")
+		}
+		return
+	}
+	obj, ok := node.(map[string]any)
+	if !ok {
+		*errs = append(*errs, path+": invalid schema")
+		return
+	}
+
+	if ref, ok := obj["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %v", path, err))
+			return
+		}
+		s.validate(target, v, path, depth+1, errs)
+	}
+
+	if t, ok := obj["type"]; ok {
+		names, _ := typeNames(t)
+		if !matchesType(names, v) {
+			*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(names, " or "), typeOf(v)))
+			return
+		}
+	}
+
+	if e, ok := obj["enum"].([]any); ok && !containsValue(e, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: must be one of %s", path, encode(e)))
+	}
+	if c, ok := obj["const"]; ok && !equal(c, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: must be %s", path, encode(c)))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		s.validateObject(obj, v, path, depth, errs)
+	case []any:
+		s.validateArray(obj, v, path, depth, errs)
+	case string:
+		s.validateString(obj, v, path, errs)
+	case float64:
+		validateNumber(obj, v, path, errs)
+	}
+
+	if list, ok := obj["allOf"].([]any); ok {
+		for _, child := range list {
+			s.validate(child, v, path, depth+1, errs)
+		}
+	}
+	if list, ok := obj["anyOf"].([]any); ok && s.countMatches(list, v, depth+1) == 0 {
+		*errs = append(*errs, path+": must match at least one schema of anyOf")
+	}
+	if list, ok := obj["oneOf"].([]any); ok {
+		if n := s.countMatches(list, v, depth+1); n != 1 {
+			*errs = append(*errs, fmt.Sprintf("%s: must match exactly one schema of oneOf, matches %d", path, n))
+		}
+	}
+	if not, ok := obj["not"]; ok && s.matches(not, v, depth+1) {
+		*errs = append(*errs, path+": must not match the schema of not")
+	}
+}
+
+// validateObject validates the properties of an object.
+func (s *Schema) validateObject(obj map[string]any, v map[string]any, path string, depth int, errs *[]string) {
+	if required, ok := obj["required"].([]any); ok {
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	properties, _ := obj["properties"].(map[string]any)
+	additional, hasAdditional := obj["additionalProperties"]
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		childPath := path + "." + name
+		if child, ok := properties[name]; ok {
+			s.validate(child, v[name], childPath, depth+1, errs)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if b, ok := additional.(bool); ok && !b {
+			*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, name))
+			continue
+		}
+		s.validate(additional, v[name], childPath, depth+1, errs)
+	}
+}
+
+// validateArray validates the length and items of an array.
+func (s *Schema) validateArray(obj map[string]any, v []any, path string, depth int, errs *[]string) {
+	if n, ok := obj["minItems"].(float64); ok && float64(len(v)) < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at least %v items, has %d", path, n, len(v)))
+	}
+	if n, ok := obj["maxItems"].(float64); ok && float64(len(v)) > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at most %v items, has %d", path, n, len(v)))
+	}
+	if items, ok := obj["items"]; ok {
+		for i, item := range v {
+			s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth+1, errs)
+		}
+	}
+}
+
+// validateString validates the length and pattern of a string.
+func (s *Schema) validateString(obj map[string]any, v string, path string, errs *[]string) {
+	length := float64(len([]rune(v)))
+	if n, ok := obj["minLength"].(float64); ok && length < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be at least %v characters long", path, n))
+	}
+	if n, ok := obj["maxLength"].(float64); ok && length > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be at most %v characters long", path, n))
+	}
+	if pattern, ok := obj["pattern"].(string); ok {
+		// Patterns outside the nodes checked by Compile are compiled here
+		re := s.patterns[pattern]
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				*errs = append(*errs, fmt.Sprintf("%s: invalid pattern %q", path, pattern))
+				return
+			}
+		}
+		if !re.MatchString(v) {
+			*errs = append(*errs, fmt.Sprintf("%s: must match the pattern %q", path, pattern))
+		}
+	}
+}
+
+// validateNumber validates the range of a number.
+func validateNumber(obj map[string]any, v float64, path string, errs *[]string) {
+	if n, ok := obj["minimum"].(float64); ok && v < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be at least %v", path, n))
+	}
+	if n, ok := obj["maximum"].(float64); ok && v > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be at most %v", path, n))
+	}
+	if n, ok := obj["exclusiveMinimum"].(float64); ok && v <= n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be greater than %v", path, n))
+	}
+	if n, ok := obj["exclusiveMaximum"].(float64); ok && v >= n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be less than %v", path, n))
+	}
+}
+
+// matches reports whether v matches the schema node.
+func (s *Schema) matches(node, v any, depth int) bool {
+	var errs []string
+	s.validate(node, v, "$", depth, &errs)
+	return len(errs) == 0
+}
+
+// countMatches returns the number of schema nodes of list v matches.
+func (s *Schema) countMatches(list []any, v any, depth int) int {
+	n := 0
+	for _, node := range list {
+		if s.matches(node, v, depth) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesType reports whether v is of one of the named types.
+func matchesType(names []string, v any) bool {
+	actual := typeOf(v)
+	for _, name := range names {
+		if name == actual || name == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type name of a decoded JSON value. Numbers
+// without a fractional part are integers.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// containsValue reports whether list contains a value equal to v.
+func containsValue(list []any, v any) bool {
+	for _, item := range list {
+		if equal(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal reports whether two decoded JSON values are equal.
+func equal(a, b any) bool {
+	return encode(a) == encode(b)
+}
+
+// encode returns the JSON encoding of a decoded JSON value. Object keys are
+// sorted, so equal values have equal encodings.
+func encode(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
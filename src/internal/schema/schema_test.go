@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"address": {"$ref": "#/$defs/address"}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"address": {
+			"type": "object",
+			"properties": {"city": {"type": "string"}},
+			"required": ["city"]
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(personSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		value  string
+		errors []string
+	}{
+		{"valid", `{"name":"Ada","age":36,"email":"ada@example.com","role":"admin","tags":["a"],"address":{"city":"London"}}`, nil},
+		{"missing required", `{"name":"Ada"}`, []string{`$: missing required property "age"`}},
+		{"wrong type", `{"name":"Ada","age":36.5}`, []string{"$.age: expected integer, got number"}},
+		{"minimum", `{"name":"Ada","age":-1}`, []string{"$.age: must be at least 0"}},
+		{"min length", `{"name":"","age":1}`, []string{"$.name: must be at least 1 characters long"}},
+		{"pattern", `{"name":"Ada","age":1,"email":"ada"}`, []string{`$.email: must match the pattern "^[^@]+@[^@]+$"`}},
+		{"enum", `{"name":"Ada","age":1,"role":"root"}`, []string{`$.role: must be one of ["admin","user"]`}},
+		{"items", `{"name":"Ada","age":1,"tags":["a",2]}`, []string{"$.tags[1]: expected string, got integer"}},
+		{"max items", `{"name":"Ada","age":1,"tags":["a","b","c"]}`, []string{"$.tags: must have at most 2 items, has 3"}},
+		{"additional property", `{"name":"Ada","age":1,"extra":true}`, []string{`$: unexpected property "extra"`}},
+		{"reference", `{"name":"Ada","age":1,"address":{}}`, []string{`$.address: missing required property "city"`}},
+		{"not an object", `["Ada"]`, []string{"$: expected object, got array"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate([]byte(tt.value))
+			if tt.errors == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if !slices.Equal(invalid.Errors, tt.errors) {
+				t.Errorf("expected %q, got %q", tt.errors, invalid.Errors)
+			}
+		})
+	}
+}
+
+func TestValidate_InvalidJSON(t *testing.T) {
+	s, err := Compile([]byte(`{"type":"object"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = s.Validate([]byte(`{"name":`))
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || !strings.HasPrefix(invalid.Errors[0], "invalid JSON") {
+		t.Errorf("expected invalid JSON error, got %v", err)
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	s, err := Compile([]byte(`{
+		"oneOf": [{"type": "string"}, {"type": "number", "exclusiveMaximum": 10}],
+		"not": {"const": "forbidden"}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, valid := range []string{`"text"`, `3`} {
+		if err := s.Validate([]byte(valid)); err != nil {
+			t.Errorf("expected %s to be valid, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{`true`, `10`, `"forbidden"`} {
+		if err := s.Validate([]byte(invalid)); err == nil {
+			t.Errorf("expected %s to be invalid", invalid)
+		}
+	}
+}
+
+func TestValidate_CyclicReference(t *testing.T) {
+	s, err := Compile([]byte(`{"$ref": "#"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Validate([]byte(`{}`)); err == nil {
+		t.Error("expected error for cyclic reference")
+	}
+}
+
+func TestValidate_ReferencedPattern(t *testing.T) {
+	s, err := Compile([]byte(`{"$ref": "#/x", "x": {"pattern": "^a"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := s.Validate([]byte(`"abc"`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.Validate([]byte(`"bc"`)); err == nil {
+		t.Error("expected pattern mismatch")
+	}
+}
+
+func TestValidate_ReferenceCycle(t *testing.T) {
+	s, err := Compile([]byte(`{"$ref": "#/$defs/a", "$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Validate([]byte(`1`)); err == nil {
+		t.Error("expected error for cyclic reference")
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"not JSON", `{"type":`},
+		{"not an object", `"object"`},
+		{"unknown type", `{"type": "text"}`},
+		{"invalid required", `{"required": "name"}`},
+		{"invalid pattern", `{"pattern": "("}`},
+		{"remote reference", `{"$ref": "https://example.com/schema.json"}`},
+		{"unresolvable reference", `{"$ref": "#/$defs/missing"}`},
+		{"invalid nested schema", `{"properties": {"name": {"type": 1}}}`},
+		{"empty anyOf", `{"anyOf": []}`},
+		{"reference to a number", `{"$ref": "#/x", "x": 5}`},
+		{"reference to a type", `{"$ref": "#/properties/a/type", "properties": {"a": {"type": "string"}}}`},
+		{"reference to an invalid schema", `{"$ref": "#/x", "x": {"type": "text"}}`},
+		{"reference to an invalid pattern", `{"$ref": "#/x", "x": {"pattern": "("}}`},
+		{"unsupported keyword", `{"type": "array", "uniqueItems": true}`},
+		{"nested unsupported keyword", `{"properties": {"tags": {"type": "object", "patternProperties": {"^x-": {"type": "string"}}}}}`},
+		{"unsupported conditional", `{"if": {"required": ["a"]}, "then": {"required": ["b"]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile([]byte(tt.schema)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message roles.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// DefaultIdleTTL is how long sessions are kept without a new turn by default.
+const DefaultIdleTTL = 24 * time.Hour
+
+// Message is a single turn in a conversation.
+type Message struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session is a multi-turn conversation with a single provider.
+type Session struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model,omitempty"`
+	Messages  []Message `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// ProviderSessionID is the CLI's own conversation ID, used to resume the
+	// conversation natively instead of replaying the transcript.
+	ProviderSessionID string `json:"-"`
+}
+
+// clone returns a copy of the session that does not share its messages.
+func (s Session) clone() Session {
+	s.Messages = append([]Message(nil), s.Messages...)
+	return s
+}
+
+// entry holds a session together with the lock that serializes its turns.
+type entry struct {
+	turn    sync.Mutex
+	session Session
+	// updates is the number of running or waiting updates, which keep the
+	// session from being evicted.
+	updates int
+}
+
+// Store keeps sessions in memory. Sessions are removed once they have not
+// been updated for longer than the idle TTL.
+type Store struct {
+	mu       sync.RWMutex
+	sessions map[string]*entry
+	idleTTL  time.Duration
+	now      func() time.Time
+}
+
+// NewStore creates an empty session store that evicts sessions idle for
+// longer than idleTTL.
+func NewStore(idleTTL time.Duration) *Store {
+	return &Store{
+		sessions: make(map[string]*entry),
+		idleTTL:  idleTTL,
+		now:      time.Now,
+	}
+}
+
+// Create starts a new, empty session for the given provider and model.
+func (s *Store) Create(provider, model string) Session {
+	now := s.now().UTC()
+	sess := Session{
+		ID:        newID(),
+		Provider:  provider,
+		Model:     model,
+		Messages:  []Message{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.mu.Lock()
+	s.prune()
+	s.sessions[sess.ID] = &entry{session: sess}
+	s.mu.Unlock()
+
+	return sess.clone()
+}
+
+// Get returns a copy of the session with the given ID.
+func (s *Store) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	e, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	return e.session.clone(), true
+}
+
+// Delete removes the session with the given ID. It returns false if the
+// session does not exist.
+func (s *Store) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[id]; !ok {
+		return false
+	}
+	delete(s.sessions, id)
+	return true
+}
+
+// Update runs fn on a copy of the session and stores the result if fn returns
+// nil. Updates of the same session are serialized, so fn may run a whole
+// conversation turn without racing concurrent turns. Reads via Get are not
+// blocked while fn runs.
+func (s *Store) Update(id string, fn func(*Session) error) error {
+	s.mu.Lock()
+	s.prune()
+	e, ok := s.sessions[id]
+	if ok {
+		e.updates++
+	}
+	s.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	defer func() {
+		s.mu.Lock()
+		e.updates--
+		s.mu.Unlock()
+	}()
+
+	e.turn.Lock()
+	defer e.turn.Unlock()
+
+	s.mu.RLock()
+	sess := e.session.clone()
+	s.mu.RUnlock()
+
+	if err := fn(&sess); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The session may have been deleted while fn was running
+	if _, ok := s.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	sess.UpdatedAt = s.now().UTC()
+	e.session = sess
+	return nil
+}
+
+// prune removes sessions that have not been updated for longer than the idle
+// TTL, unless an update is running. The caller must hold s.mu for writing.
+func (s *Store) prune() {
+	cutoff := s.now().Add(-s.idleTTL)
+	for id, e := range s.sessions {
+		if e.updates == 0 && e.session.UpdatedAt.Before(cutoff) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// FormatTranscript renders previous messages and a new user prompt into a
+// single prompt, for providers that cannot resume a conversation natively.
+func FormatTranscript(messages []Message, userPrompt string) string {
+	if len(messages) == 0 {
+		return userPrompt
+	}
+
+	var b strings.Builder
+	b.WriteString("Conversation so far:\n\n")
+	for _, m := range messages {
+		switch m.Role {
+		case RoleAssistant:
+			b.WriteString("Assistant: ")
+		default:
+			b.WriteString("User: ")
+		}
+		b.WriteString(m.Content)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Reply to the following new message from the user:\n\n")
+	b.WriteString(userPrompt)
+
+	return b.String()
+}
+
+// newID returns a random 128-bit hex encoded session ID.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,197 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStore_CreateAndGet(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+
+	created := store.Create("claude", "")
+	if created.ID == "" {
+		t.Fatal("expected session ID to be set")
+	}
+	if created.Provider != "claude" {
+		t.Errorf("expected provider claude, got %s", created.Provider)
+	}
+
+	got, ok := store.Get(created.ID)
+	if !ok {
+		t.Fatal("expected session to exist")
+	}
+	if got.ID != created.ID {
+		t.Errorf("expected ID %s, got %s", created.ID, got.ID)
+	}
+	if len(got.Messages) != 0 {
+		t.Errorf("expected no messages, got %d", len(got.Messages))
+	}
+}
+
+func TestStore_UniqueIDs(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+
+	a := store.Create("claude", "")
+	b := store.Create("claude", "")
+	if a.ID == b.ID {
+		t.Errorf("expected unique IDs, got %s twice", a.ID)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+	sess := store.Create("claude", "")
+
+	if !store.Delete(sess.ID) {
+		t.Fatal("expected delete to succeed")
+	}
+	if _, ok := store.Get(sess.ID); ok {
+		t.Error("expected session to be gone after delete")
+	}
+	if store.Delete(sess.ID) {
+		t.Error("expected second delete to fail")
+	}
+}
+
+func TestStore_Update(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+	sess := store.Create("claude", "")
+
+	err := store.Update(sess.ID, func(s *Session) error {
+		s.Messages = append(s.Messages, Message{Role: RoleUser, Content: "Hello"})
+		s.ProviderSessionID = "native-123"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := store.Get(sess.ID)
+	if len(got.Messages) != 1 || got.Messages[0].Content != "Hello" {
+		t.Errorf("unexpected messages: %+v", got.Messages)
+	}
+	if got.ProviderSessionID != "native-123" {
+		t.Errorf("expected provider session ID native-123, got %q", got.ProviderSessionID)
+	}
+}
+
+func TestStore_UpdateErrorDiscardsChanges(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+	sess := store.Create("claude", "")
+
+	boom := errors.New("boom")
+	err := store.Update(sess.ID, func(s *Session) error {
+		s.Messages = append(s.Messages, Message{Role: RoleUser, Content: "Hello"})
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+
+	got, _ := store.Get(sess.ID)
+	if len(got.Messages) != 0 {
+		t.Errorf("expected changes to be discarded, got %+v", got.Messages)
+	}
+}
+
+func TestStore_UpdateNotFound(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+
+	err := store.Update("missing", func(s *Session) error { return nil })
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_UpdateSerializesTurns(t *testing.T) {
+	store := NewStore(DefaultIdleTTL)
+	sess := store.Create("claude", "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Update(sess.ID, func(s *Session) error {
+				s.Messages = append(s.Messages, Message{Role: RoleUser, Content: "turn"})
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	got, _ := store.Get(sess.ID)
+	if len(got.Messages) != 20 {
+		t.Errorf("expected 20 messages, got %d", len(got.Messages))
+	}
+}
+
+func TestStore_IdleEviction(t *testing.T) {
+	store := NewStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	idle := store.Create("claude", "")
+	active := store.Create("claude", "")
+
+	now = now.Add(50 * time.Minute)
+	if err := store.Update(active.ID, func(*Session) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = now.Add(20 * time.Minute)
+	if _, ok := store.Get(idle.ID); ok {
+		t.Error("expected idle session to be evicted")
+	}
+	if _, ok := store.Get(active.ID); !ok {
+		t.Error("expected recently updated session to be kept")
+	}
+	if err := store.Update(idle.ID, func(*Session) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for evicted session, got %v", err)
+	}
+}
+
+func TestStore_NoEvictionDuringUpdate(t *testing.T) {
+	store := NewStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	sess := store.Create("claude", "")
+	err := store.Update(sess.ID, func(s *Session) error {
+		// The turn outlives the idle TTL
+		now = now.Add(2 * time.Hour)
+		store.Create("claude", "")
+		s.Messages = append(s.Messages, Message{Role: RoleUser, Content: "Hi"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected running turn to keep the session, got %v", err)
+	}
+	if got, ok := store.Get(sess.ID); !ok || len(got.Messages) != 1 {
+		t.Errorf("expected updated session, got %+v, %v", got, ok)
+	}
+}
+
+func TestFormatTranscript(t *testing.T) {
+	if got := FormatTranscript(nil, "Hello"); got != "Hello" {
+		t.Errorf("expected plain prompt without history, got %q", got)
+	}
+
+	messages := []Message{
+		{Role: RoleUser, Content: "What is 2+2?"},
+		{Role: RoleAssistant, Content: "4"},
+	}
+	got := FormatTranscript(messages, "And times 3?")
+
+	for _, expected := range []string{"User: What is 2+2?", "Assistant: 4", "And times 3?"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected transcript to contain %q, got %q", expected, got)
+		}
+	}
+	if !strings.HasSuffix(got, "And times 3?") {
+		t.Errorf("expected transcript to end with the new prompt, got %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+// Package webhook delivers signed webhook requests with retries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the timestamp and the
+// request body, formatted as "sha256=<hex>".
+const SignatureHeader = "X-Proxy-Signature-256"
+
+// TimestampHeader carries the time of the delivery attempt in Unix seconds.
+const TimestampHeader = "X-Proxy-Timestamp"
+
+// DefaultTolerance is the maximum age of a delivery receivers should accept,
+// which stops replays of captured requests.
+const DefaultTolerance = 5 * time.Minute
+
+// DefaultAttempts is the number of delivery attempts by default.
+const DefaultAttempts = 5
+
+const (
+	defaultBaseDelay = time.Second
+	defaultMaxDelay  = time.Minute
+	requestTimeout   = 10 * time.Second
+)
+
+// Sender posts JSON payloads to webhook URLs, signed with a shared secret.
+type Sender struct {
+	client    *http.Client
+	secret    []byte
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+// Option configures optional Sender behavior.
+type Option func(*Sender)
+
+// WithAttempts sets the number of delivery attempts, including the first.
+func WithAttempts(n int) Option {
+	return func(s *Sender) {
+		s.attempts = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry, which doubles with
+// every further retry up to max.
+func WithBackoff(base, max time.Duration) Option {
+	return func(s *Sender) {
+		s.baseDelay = base
+		s.maxDelay = max
+	}
+}
+
+// NewSender creates a Sender that signs deliveries with secret.
+func NewSender(secret string, opts ...Option) *Sender {
+	s := &Sender{
+		client:    &http.Client{Timeout: requestTimeout},
+		secret:    []byte(secret),
+		attempts:  DefaultAttempts,
+		baseDelay: defaultBaseDelay,
+		maxDelay:  defaultMaxDelay,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Sign returns the signature header value of a delivery of body at
+// timestamp for secret. The signed message is timestamp + "." + body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery of body.
+// Deliveries whose timestamp differs from now by more than tolerance are
+// rejected.
+func Verify(secret []byte, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	timestamp := header.Get(TimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return errors.New("timestamp outside the tolerance window")
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Deliver posts body to url until it is accepted with a 2xx status. Network
+// errors, 429 and 5xx responses are retried with exponential backoff; other
+// responses are not. It returns the error of the last attempt.
+func (s *Sender) Deliver(ctx context.Context, url string, body []byte) error {
+	delay := s.baseDelay
+	var err error
+	for attempt := 1; attempt <= s.attempts; attempt++ {
+		var retry bool
+		retry, err = s.post(ctx, url, body)
+		if err == nil || !retry || attempt == s.attempts {
+			break
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay = min(delay*2, s.maxDelay)
+	}
+	return err
+}
+
+// post makes a single delivery attempt and reports whether a failure may be
+// retried.
+func (s *Sender) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "local-ai-tool-proxy")
+	// Every attempt is signed with a fresh timestamp, so that retries are
+	// not rejected as too old
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
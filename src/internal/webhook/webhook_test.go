@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeliver(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sender := NewSender("secret")
+	if err := sender.Deliver(context.Background(), server.URL, []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(body) != `{"id":"1"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+	if err := Verify([]byte("secret"), header, body, time.Now(), DefaultTolerance); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got := Sign([]byte("secret"), "1700000000", []byte(`{"id":"1"}`)); got != want {
+		t.Errorf("expected signature %q, got %q", want, got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Unix(1700000000, 0)
+	signed := func(timestamp string) http.Header {
+		header := http.Header{}
+		header.Set(TimestampHeader, timestamp)
+		header.Set(SignatureHeader, Sign([]byte("secret"), timestamp, body))
+		return header
+	}
+	tampered := signed("1700000000")
+	tampered.Set(TimestampHeader, "1700000001")
+
+	tests := []struct {
+		name   string
+		header http.Header
+		valid  bool
+	}{
+		{"valid", signed("1700000000"), true},
+		{"within tolerance", signed("1699999800"), true},
+		{"too old", signed("1699999000"), false},
+		{"in the future", signed("1700001000"), false},
+		{"tampered timestamp", tampered, false},
+		{"missing timestamp", http.Header{SignatureHeader: {Sign([]byte("secret"), "", body)}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify([]byte("secret"), tt.header, body, now, DefaultTolerance)
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestDeliver_RetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		times = append(times, time.Now())
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender("secret", WithBackoff(20*time.Millisecond, time.Second))
+	if err := sender.Deliver(context.Background(), server.URL, []byte(`{}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+	if first, second := times[1].Sub(times[0]), times[2].Sub(times[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Errorf("expected doubling delays, got %v and %v", first, second)
+	}
+}
+
+func TestDeliver_GivesUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		calls  int32
+	}{
+		{"retryable status", http.StatusInternalServerError, 3},
+		{"rate limited", http.StatusTooManyRequests, 3},
+		{"permanent status", http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sender := NewSender("secret", WithAttempts(3), WithBackoff(time.Millisecond, time.Millisecond))
+			if err := sender.Deliver(context.Background(), server.URL, []byte(`{}`)); err == nil {
+				t.Error("expected an error")
+			}
+			if calls.Load() != tt.calls {
+				t.Errorf("expected %d attempts, got %d", tt.calls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestDeliver_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	sender := NewSender("secret", WithBackoff(time.Hour, time.Hour))
+	start := time.Now()
+	if err := sender.Deliver(ctx, server.URL, []byte(`{}`)); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected the backoff to stop when the context is done")
+	}
+}
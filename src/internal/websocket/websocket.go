@@ -0,0 +1,368 @@
+// Package websocket implements the server side of the subset of the
+// WebSocket protocol (RFC 6455) needed to exchange text messages: the opening
+// handshake, framing with fragmentation, ping and pong with an idle timeout,
+// and the closing handshake.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxMessageSize is the maximum size of a message read from the peer.
+const MaxMessageSize = 1 << 20
+
+// writeTimeout bounds writing a single frame, so that a peer that stopped
+// reading cannot block its writers forever.
+const writeTimeout = 10 * time.Second
+
+// IdleTimeout is the time after which a connection that received no frames,
+// not even pongs, is closed. Connections are pinged at half this interval, so
+// that live peers keep them open without sending messages.
+const IdleTimeout = time.Minute
+
+// acceptGUID is appended to the handshake key to derive the accept key.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	// ErrBadHandshake is returned by Upgrade for requests that are not a
+	// valid WebSocket opening handshake.
+	ErrBadHandshake = errors.New("bad websocket handshake")
+	// ErrClosed is returned by ReadMessage once the peer closed the
+	// connection, and by writes on a closed connection.
+	ErrClosed = errors.New("websocket closed")
+	// ErrIdleTimeout is returned by ReadMessage if the connection was
+	// closed after receiving nothing for IdleTimeout.
+	ErrIdleTimeout = errors.New("websocket idle timeout")
+)
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+)
+
+// Conn is the server side of a WebSocket connection. ReadMessage must not be
+// called concurrently; writes are safe for concurrent use.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	idleTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closed    bool
+	// done is closed with the connection and stops the keepalive pings.
+	done chan struct{}
+}
+
+// protocolError is a violation of the protocol by the peer, reported with
+// its close code.
+type protocolError struct {
+	code   int
+	reason string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("websocket: %s", e.reason)
+}
+
+// Upgrade performs the opening handshake for r and takes over its
+// connection. Nothing has been written to w if an error is returned.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	return upgrade(w, r, IdleTimeout)
+}
+
+// upgrade is Upgrade with a custom idle timeout.
+func upgrade(w http.ResponseWriter, r *http.Request, idleTimeout time.Duration) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, fmt.Errorf("%w: method must be GET", ErrBadHandshake)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, fmt.Errorf("%w: missing upgrade headers", ErrBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("%w: unsupported version", ErrBadHandshake)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("%w: invalid key", ErrBadHandshake)
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server's read and write timeouts must not end the connection
+	conn.SetDeadline(time.Time{})
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Conn{conn: conn, br: brw.Reader, idleTimeout: idleTimeout, done: make(chan struct{})}
+	go c.keepAlive()
+	return c, nil
+}
+
+// keepAlive pings the peer until the connection is closed.
+func (c *Conn) keepAlive() {
+	ticker := time.NewTicker(c.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ReadMessage reads the next text message. Pings are answered while waiting.
+// It returns ErrClosed once the peer closed the connection, and
+// ErrIdleTimeout if the peer sent nothing for the idle timeout; protocol
+// violations close the connection with the matching status code.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	var fragmented bool
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, c.fail(err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.CloseWithCode(code, "")
+			return nil, ErrClosed
+		case opBinary:
+			return nil, c.fail(&protocolError{CloseUnsupportedData, "binary messages are not supported"})
+		case opText:
+			if fragmented {
+				return nil, c.fail(&protocolError{CloseProtocolError, "expected continuation frame"})
+			}
+			fragmented = true
+		case opContinuation:
+			if !fragmented {
+				return nil, c.fail(&protocolError{CloseProtocolError, "unexpected continuation frame"})
+			}
+		default:
+			return nil, c.fail(&protocolError{CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode)})
+		}
+
+		if len(message)+len(payload) > MaxMessageSize {
+			return nil, c.fail(&protocolError{CloseMessageTooBig, "message too big"})
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if !utf8.Valid(message) {
+				return nil, c.fail(&protocolError{CloseInvalidPayload, "invalid UTF-8 in text message"})
+			}
+			return message, nil
+		}
+	}
+}
+
+// WriteMessage writes data as a single text message.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Close closes the connection with a normal closure.
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormal, "")
+}
+
+// CloseWithCode sends a close frame with code and reason and closes the
+// connection. The peer's close frame is not awaited.
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	err := ErrClosed
+	c.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+		c.writeFrame(opClose, payload)
+
+		c.writeMu.Lock()
+		c.closed = true
+		c.writeMu.Unlock()
+		err = c.conn.Close()
+		close(c.done)
+	})
+	return err
+}
+
+// fail closes the connection after a read error, with the status code of
+// protocol violations, and returns the error to report.
+func (c *Conn) fail(err error) error {
+	var perr *protocolError
+	if errors.As(err, &perr) {
+		c.CloseWithCode(perr.code, perr.reason)
+		return err
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		c.CloseWithCode(CloseGoingAway, "idle timeout")
+		return ErrIdleTimeout
+	}
+	c.conn.Close()
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+// readFrame reads a single frame and unmasks its payload.
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &protocolError{CloseProtocolError, "reserved bits set"}
+	}
+
+	// Clients must mask their frames
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &protocolError{CloseProtocolError, "invalid frame masking"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, &protocolError{CloseProtocolError, "invalid control frame"}
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, &protocolError{CloseMessageTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	maskBytes(mask, payload)
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes payload as a single final frame.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// maskBytes applies the masking key to b in place; masking and unmasking are
+// the same operation.
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+// acceptKey derives the Sec-WebSocket-Accept value for a handshake key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether the comma-separated header name contains
+// token, ignoring case.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobilg/local-ai-tool-proxy/src/internal/websocket/websockettest"
+)
+
+// newEchoServer starts a server that echoes text messages, closing the
+// connection after the idle timeout. The error that ended each connection is
+// sent to errs if it is not nil.
+func newEchoServer(t *testing.T, idleTimeout time.Duration, errs chan<- error) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r, idleTimeout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				if errs != nil {
+					errs <- err
+				}
+				return
+			}
+			conn.WriteMessage(msg)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dial(t *testing.T, server *httptest.Server) *websockettest.Conn {
+	t.Helper()
+	conn, err := websockettest.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// writeRawFrame writes a masked client frame with explicit FIN bit.
+func writeRawFrame(t *testing.T, c *websockettest.Conn, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	if err := c.WriteFrame(fin, opcode, payload); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	conn := dial(t, newEchoServer(t, IdleTimeout, nil))
+
+	for _, msg := range []string{"hello", strings.Repeat("a", 200), strings.Repeat("b", 70000)} {
+		if err := conn.WriteMessage([]byte(msg)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(got) != msg {
+			t.Errorf("expected echo of %d bytes, got %d bytes", len(msg), len(got))
+		}
+	}
+}
+
+func TestFragmentedMessageWithPing(t *testing.T) {
+	conn := dial(t, newEchoServer(t, IdleTimeout, nil))
+
+	writeRawFrame(t, conn, false, opText, []byte("hel"))
+	writeRawFrame(t, conn, true, opPing, []byte("p"))
+	writeRawFrame(t, conn, true, opContinuation, []byte("lo"))
+
+	// The pong precedes the echo and is skipped by ReadMessage
+	got, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("expected hello, got %q", got)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames func(t *testing.T, c *websockettest.Conn)
+		code   int
+	}{
+		{"binary", func(t *testing.T, c *websockettest.Conn) {
+			writeRawFrame(t, c, true, opBinary, []byte{0xFF})
+		}, CloseUnsupportedData},
+		{"invalid UTF-8", func(t *testing.T, c *websockettest.Conn) {
+			writeRawFrame(t, c, true, opText, []byte{0xFF, 0xFE})
+		}, CloseInvalidPayload},
+		{"unexpected continuation", func(t *testing.T, c *websockettest.Conn) {
+			writeRawFrame(t, c, true, opContinuation, []byte("x"))
+		}, CloseProtocolError},
+		{"unmasked", func(t *testing.T, c *websockettest.Conn) {
+			c.WriteRaw([]byte{0x81, 0x01, 'x'})
+		}, CloseProtocolError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dial(t, newEchoServer(t, IdleTimeout, nil))
+			tt.frames(t, conn)
+
+			fin, opcode, payload, err := conn.ReadFrame()
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !fin || opcode != opClose || len(payload) < 2 {
+				t.Fatalf("expected close frame, got opcode %d", opcode)
+			}
+			if code := int(binary.BigEndian.Uint16(payload)); code != tt.code {
+				t.Errorf("expected close code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		errs <- conn.WriteMessage([]byte("late"))
+	}))
+	defer server.Close()
+
+	conn := dial(t, server)
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if _, err := conn.ReadMessage(); !errors.Is(err, websockettest.ErrClosed) {
+		t.Errorf("expected close frame, got %v", err)
+	}
+}
+
+func TestPeerClose(t *testing.T) {
+	conn := dial(t, newEchoServer(t, IdleTimeout, nil))
+
+	writeRawFrame(t, conn, true, opClose, binary.BigEndian.AppendUint16(nil, CloseGoingAway))
+
+	// The server echoes the close frame
+	if _, err := conn.ReadMessage(); !errors.Is(err, websockettest.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	conn := dial(t, newEchoServer(t, 100*time.Millisecond, errs))
+
+	// Pings are not answered, so the server closes the connection
+	for {
+		_, opcode, payload, err := conn.ReadFrame()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if opcode == opPing {
+			continue
+		}
+		if opcode != opClose || int(binary.BigEndian.Uint16(payload)) != CloseGoingAway {
+			t.Fatalf("expected going away close frame, got opcode %d", opcode)
+		}
+		break
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrIdleTimeout) {
+			t.Errorf("expected ErrIdleTimeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the server to report the idle timeout")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	conn := dial(t, newEchoServer(t, 100*time.Millisecond, nil))
+
+	messages := make(chan string, 1)
+	go func() {
+		// ReadMessage answers the server's pings
+		msg, _ := conn.ReadMessage()
+		messages <- string(msg)
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	if err := conn.WriteMessage([]byte("still there")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if msg := <-messages; msg != "still there" {
+		t.Errorf("expected echo after idle period, got %q", msg)
+	}
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	server := newEchoServer(t, IdleTimeout, nil)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "too-short")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid key, got %d", resp.StatusCode)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", got)
+	}
+}
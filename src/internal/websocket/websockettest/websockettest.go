@@ -0,0 +1,229 @@
+// Package websockettest provides a minimal WebSocket client for testing
+// WebSocket servers. It does not depend on package websocket, so that its
+// tests can use it too.
+package websockettest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// acceptGUID is appended to the handshake key to derive the accept key.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes.
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// ErrClosed is returned by ReadMessage once the server sent a close frame,
+// and by writes on a closed connection.
+var ErrClosed = errors.New("websocket closed")
+
+// Conn is a client connection. ReadMessage and ReadFrame must not be called
+// concurrently; writes are safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// Dial opens a connection to a ws:// URL. header is sent with the handshake,
+// e.g. to set the Origin.
+func Dial(rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Host:       u.Host,
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("bad websocket handshake: status %d", resp.StatusCode)
+	}
+
+	return &Conn{conn: conn, br: br}, nil
+}
+
+// ReadMessage reads the next text message. Pings are answered while waiting.
+// It returns ErrClosed once the server closed the connection.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case OpPing:
+			if err := c.WriteFrame(true, OpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			c.Close()
+			return nil, ErrClosed
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// ReadFrame reads a single unmasked frame.
+func (c *Conn) ReadFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[1]&0x80 != 0 {
+		return false, 0, nil, errors.New("masked server frame")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes data as a single text message.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.WriteFrame(true, OpText, data)
+}
+
+// WriteFrame writes payload as a single masked frame, which is final if fin
+// is set.
+func (c *Conn) WriteFrame(fin bool, opcode byte, payload []byte) error {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	start := len(frame)
+	frame = append(frame, payload...)
+	for i := range frame[start:] {
+		frame[start+i] ^= mask[i%4]
+	}
+	return c.WriteRaw(frame)
+}
+
+// WriteRaw writes b to the connection as is, e.g. to send invalid frames.
+func (c *Conn) WriteRaw(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Close sends a normal closure and closes the connection.
+func (c *Conn) Close() error {
+	c.WriteFrame(true, OpClose, binary.BigEndian.AppendUint16(nil, 1000))
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}